@@ -4,11 +4,16 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"math"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"swing-trader/internal/types"
 	"swing-trader/pkg/backtesting"
 	"swing-trader/pkg/data"
+	"swing-trader/pkg/export"
 	"swing-trader/pkg/visualization"
 	"time"
 )
@@ -17,33 +22,180 @@ func main() {
 	// Define command line flags
 	var (
 		dataPath       = flag.String("data", "", "Path to CSV file with historical stock data")
+		duplicateDates = flag.String("duplicate-dates", "last", "Rows sharing a date: keep the last or first in file order with a warning, or error")
 		startDate      = flag.String("start", "", "Start date for backtest (YYYY-MM-DD)")
 		endDate        = flag.String("end", "", "End date for backtest (YYYY-MM-DD)")
 		initialCapital = flag.Float64("capital", 10000.0, "Initial capital for backtesting")
+		strategyName   = flag.String("strategy", "bb_rsi", "Signal logic: bb_rsi (Bollinger Bands + RSI), ma_crossover or voting")
+		fastMA         = flag.Int("fast-ma", 50, "Fast SMA period for the ma_crossover strategy")
+		slowMA         = flag.Int("slow-ma", 200, "Slow SMA period for the ma_crossover strategy")
+		voteRSI        = flag.Float64("vote-rsi", 1.0, "Weight of the RSI vote for the voting strategy (0 leaves it out)")
+		votePercentB   = flag.Float64("vote-percent-b", 1.0, "Weight of the Bollinger %B vote for the voting strategy (0 leaves it out)")
+		voteStoch      = flag.Float64("vote-stochastic", 1.0, "Weight of the stochastic oscillator vote for the voting strategy (0 leaves it out)")
+		voteCCI        = flag.Float64("vote-cci", 1.0, "Weight of the CCI vote for the voting strategy (0 leaves it out)")
+		voteThreshold  = flag.Float64("vote-threshold", 0.5, "Weighted vote from 0 to 1 at which the voting strategy buys, selling at its negative")
+		stochPeriod    = flag.Int("stochastic-period", 14, "Stochastic oscillator period for the voting strategy")
+		cciPeriod      = flag.Int("cci-period", 20, "CCI period for the voting strategy")
 		buyThreshold   = flag.Float64("buy-rsi", 30.0, "RSI threshold for buying (oversold)")
 		sellThreshold  = flag.Float64("sell-rsi", 70.0, "RSI threshold for selling (overbought)")
 		stopLoss       = flag.Float64("stop-loss", 0.05, "Stop loss percentage (e.g., 0.05 for 5%)")
 		takeProfit     = flag.Float64("take-profit", 0.10, "Take profit percentage (e.g., 0.10 for 10%)")
+		targetR        = flag.Float64("target-r", 0.0, "Final take profit in multiples of the entry-to-stop risk (0 uses -take-profit)")
+		partialTargetR = flag.Float64("partial-target-r", 0.0, "Take a partial profit at this multiple of the entry-to-stop risk (0 disables)")
+		partialFrac    = flag.Float64("partial-fraction", 0.5, "Fraction of the position closed at the partial target")
+		tpLadder       = flag.String("tp-ladder", "", "Take-profit ladder as gain:fraction pairs replacing -take-profit (e.g., 0.05:0.25,0.10:0.25,0.20:0.5)")
+		stopMode       = flag.String("stop-mode", "percent", "Stop loss placement (percent, atr or equity)")
+		stopEquityPct  = flag.Float64("stop-equity", 0.01, "Equity lost when stopped out for the equity stop mode (e.g., 0.01 for 1%)")
+		atrPeriod      = flag.Int("atr-period", 14, "ATR period for the atr stop mode")
+		atrMultiplier  = flag.Float64("atr-multiplier", 2.0, "Stop distance in multiples of ATR for the atr stop mode")
+		stopDelay      = flag.Int("stop-delay", 0, "Bars after entry, counting the entry bar, before the stop loss is checked")
 		positionSize   = flag.Float64("position-size", 0.02, "Position size as percentage of capital (e.g., 0.02 for 2%)")
 		maxDrawdown    = flag.Float64("max-drawdown", 0.20, "Maximum drawdown percentage (e.g., 0.20 for 20%)")
+		minShares      = flag.Int64("min-shares", 0, "Minimum shares per trade after sizing (0 for no floor)")
+		maxShares      = flag.Int64("max-shares", 0, "Maximum shares per trade after sizing (0 for no ceiling)")
+		sizingMode     = flag.String("sizing-mode", "risk", "Position sizing mode (risk or vol_target)")
+		targetVol      = flag.Float64("target-vol", 0.15, "Annualized volatility target for vol_target sizing (e.g., 0.15 for 15%)")
+		volLookback    = flag.Int("vol-lookback", 20, "Bars of returns used for realized volatility in vol_target sizing")
+		avgDownStep    = flag.Float64("average-down-step", 0.0, "Add to a losing position each time price falls this far below the first entry (e.g., 0.03 for 3%)")
+		strengthSizing = flag.Bool("strength-sizing", false, "Scale the risked fraction between -strength-min and -strength-max by signal strength")
+		strengthMin    = flag.Float64("strength-min", 0.01, "Fraction of capital risked on the weakest signal with strength sizing")
+		strengthMax    = flag.Float64("strength-max", 0.03, "Fraction of capital risked on the strongest signal with strength sizing")
+		maxDailyLoss   = flag.Float64("max-daily-loss", 0.0, "Stop new entries for the day once realized losses reach this fraction of initial capital (0 disables)")
+		maxAvgDowns    = flag.Int("max-average-downs", 0, "Maximum number of adds per position (0 disables averaging down)")
 		tradeFee       = flag.Float64("trade-fee", 0.001, "Trade fee percentage (e.g., 0.001 for 0.1%)")
+		makerFee       = flag.Float64("maker-fee", 0, "Fee rate on limit-order fills, negative for a rebate (e.g., -0.0002)")
+		takerFee       = flag.Float64("taker-fee", 0, "Fee rate on market fills; with -maker-fee replaces -trade-fee when either is set")
+		feeSchedule    = flag.String("fee-schedule", "", "Tiered trade fees as notional:rate pairs by cumulative traded notional (e.g., 0:0.001,100000:0.0005)")
 		slippage       = flag.Float64("slippage", 0.001, "Slippage percentage (e.g., 0.001 for 0.1%)")
+		slipModel      = flag.String("slippage-model", "fixed", "Slippage model (fixed, or volume to add spread and volume impact)")
+		spreadFactor   = flag.Float64("spread-factor", 0.1, "Share of the bar's high-low range taken as the bid-ask spread by the volume slippage model")
+		volumeImpact   = flag.Float64("volume-impact", 0.1, "Volume slippage model impact per unit of order size over bar volume")
+		participation  = flag.Float64("max-volume-participation", 0, "Largest share of a bar's volume an entry fills, spreading larger orders over the following bars (0 fills at once)")
+		cashFlowsFlag  = flag.String("cash-flows", "", "Deposits and withdrawals as date:amount pairs, negative to withdraw (e.g., 2023-06-01:5000,2024-01-02:-2000)")
+		monthlyDeposit = flag.Float64("monthly-deposit", 0, "Amount added at the start of each month after the first, negative to withdraw (0 disables)")
+		blackouts      = flag.String("blackouts", "", "Date ranges with no new entries as start:end pairs (e.g., 2023-01-30:2023-02-02,2023-04-25:2023-04-27)")
+		flattenBlack   = flag.Bool("flatten-in-blackout", false, "Also close open positions when a blackout starts")
+		settlement     = flag.Int("settlement-days", 0, "Bars before sale proceeds can fund new buys (e.g., 2 for T+2)")
+		gapFill        = flag.Bool("gap-fill", true, "Fill stops and targets at the open when a bar gaps through them")
+		reentryStop    = flag.Bool("reentry-after-stop", true, "Allow re-entry after a stop-out while the BUY condition persists")
+		allowShorts    = flag.Bool("allow-shorts", false, "Open a short on a SELL signal with no long position open, covered by the next BUY")
+		maxPositions   = flag.Int("max-positions", 1, "Positions open at once, each entry signal adding one while capital allows")
+		returnType     = flag.String("return-type", "simple", "Equity-curve return calculation for risk metrics (simple or log)")
+		riskFreeRate   = flag.Float64("risk-free-rate", 0, "Annual risk-free rate for the Sharpe and Sortino ratios (e.g., 0.04 for 4%)")
+		baselineRuns   = flag.Int("baseline-runs", 0, "Random-entry backtests to compare the strategy against (0 disables)")
+		baselineSeed   = flag.Int64("baseline-seed", 1, "Seed for the random-entry baseline")
+		bootstrapRuns  = flag.Int("bootstrap-runs", 0, "Backtests on block-bootstrapped prices to compare the strategy against (0 disables)")
+		bootstrapBlock = flag.Int("bootstrap-block", 20, "Consecutive bars resampled together by the block bootstrap")
+		bootstrapSeed  = flag.Int64("bootstrap-seed", 1, "Seed for the block bootstrap")
+		rollingWindow  = flag.Int("rolling-window", 0, "Months in each window of a report of separate backtests per period, e.g. 12 for calendar years (0 disables)")
+		rollingStep    = flag.Int("rolling-step", 0, "Months between the starts of rolling windows (0 uses the window length)")
+		capacity       = flag.String("capacity", "", "Comma-separated initial capitals to re-run the backtest at, reporting return vs capital (e.g., 10000,100000,1000000)")
+		flatWeekEnd    = flag.Bool("flat-week-end", false, "Close all positions on the last bar of each week")
+		flatMonthEnd   = flag.Bool("flat-month-end", false, "Close all positions on the last bar of each month")
+		roundToCents   = flag.Bool("round-cents", false, "Round cash movements, trade P&L and final capital to whole cents")
+		skipLeading    = flag.Int("skip-leading", 0, "Leading bars excluded from trade execution")
+		skipTrailing   = flag.Int("skip-trailing", 0, "Trailing bars excluded from trade execution, closing positions on the bar before them")
+		maxPositionAge = flag.Int("max-position-age", 0, "Force-close with a warning any position open this many calendar days (0 disables)")
+		equityFilter   = flag.Int("equity-filter", 0, "Suspend new entries while equity is below its moving average over this many bars (0 disables)")
+		perfFee        = flag.Float64("performance-fee", 0, "Fee on gains above the high-water mark at each fee period end, e.g. 0.2 for 20% (0 disables)")
+		perfFeePeriod  = flag.String("performance-fee-period", "annual", "How often the performance fee is charged: monthly, quarterly or annual")
+		drawdownBasis  = flag.String("drawdown-basis", "close", "Max drawdown from capital after trade closes (close) or equity at each bar's low (intrabar)")
+		betaWindow     = flag.Int("beta-window", 63, "Bars of returns for the rolling beta and correlation to buy-and-hold")
+		minAnnualize   = flag.Int("min-annualize-days", 30, "Shortest backtest span in calendar days to annualize the return over")
+		barCalendar    = flag.String("bar-calendar", "auto", "Bar cadence for annualizing volatility: auto, business (252 bars a year) or continuous (365)")
 		rsiPeriod      = flag.Int("rsi-period", 14, "RSI calculation period")
+		rsiSmoothing   = flag.String("rsi-smoothing", "wilder", "RSI smoothing method: wilder, ema or sma")
 		bbPeriod       = flag.Int("bb-period", 20, "Bollinger Bands calculation period")
 		bbStdDev       = flag.Float64("bb-stddev", 2.0, "Bollinger Bands standard deviation multiplier")
+		entryTrigger   = flag.String("entry-trigger", "close", "Lower band breach for entries (close or touch)")
+		rsiExitLevel   = flag.Float64("rsi-exit", 0, "After an entry, sell when RSI crosses back above this level (e.g., 50, 0 disables)")
+		exitUpperBand  = flag.Bool("exit-upper-band", false, "After an entry, sell at the upper Bollinger Band once the price reaches it")
+		trendFilter    = flag.Int("trend-filter", 0, "Only buy when the close is above its SMA over this many bars (e.g., 200, 0 disables)")
+		maxEntryGap    = flag.Float64("max-entry-gap", 0, "Skip a BUY when the bar opened more than this fraction from the prior close (e.g., 0.03 for 3%, 0 disables)")
+		confirmBars    = flag.Int("confirm-bars", 0, "Additional consecutive bars the BUY condition must hold before acting")
+		signalPriority = flag.String("signal-priority", "buy", "Signal that wins when BUY and SELL conditions both hold (buy or sell)")
 		generateCharts = flag.Bool("charts", false, "Generate HTML charts for visualization")
 		chartOutput    = flag.String("chart-output", "charts", "Directory to save chart files")
+		chartMaxPoints = flag.Int("chart-max-points", 0, "Merge bars so the price chart shows at most this many candles, keeping highs and lows (0 shows every bar)")
+		positionLog    = flag.Bool("position-log", false, "Print open positions marked to market with the cash at every signal or trade event")
+		influxDest     = flag.String("influx", "", "InfluxDB write URL or file to send the result metrics to as line protocol, token from INFLUX_TOKEN")
+		influxMeasure  = flag.String("influx-measurement", "backtest", "Measurement name for the InfluxDB line protocol export")
+		logLevel       = flag.String("log-level", "info", "Log level for structured events (debug, info, warn, error)")
+		summaryLine    = flag.Bool("summary-line", false, "Print only one comma-separated line of key results for scripting")
 	)
 	flag.Parse()
 
+	// Progress messages are dropped when only the summary line is wanted
+	progress := func(format string, args ...interface{}) {
+		if !*summaryLine {
+			fmt.Printf(format, args...)
+		}
+	}
+
+	// Structured events go to stderr so the report on stdout stays unchanged
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		log.Fatalf("Invalid log level: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	slog.SetDefault(logger)
+
+	// The library falls back to the default on an unknown mode, so catch typos here
+	modes := []struct {
+		name, value string
+		allowed     []string
+	}{
+		{"duplicate-dates", *duplicateDates, []string{"last", "first", "error"}},
+		{"strategy", *strategyName, []string{"bb_rsi", "ma_crossover", "voting"}},
+		{"stop-mode", *stopMode, []string{"percent", "atr", "equity"}},
+		{"sizing-mode", *sizingMode, []string{"risk", "vol_target"}},
+		{"slippage-model", *slipModel, []string{"fixed", "volume"}},
+		{"return-type", *returnType, []string{"simple", "log"}},
+		{"performance-fee-period", *perfFeePeriod, []string{"monthly", "quarterly", "annual"}},
+		{"drawdown-basis", *drawdownBasis, []string{"close", "intrabar"}},
+		{"bar-calendar", *barCalendar, []string{"auto", "business", "continuous"}},
+		{"rsi-smoothing", *rsiSmoothing, []string{"wilder", "ema", "sma"}},
+		{"entry-trigger", *entryTrigger, []string{"close", "touch"}},
+		{"signal-priority", *signalPriority, []string{"buy", "sell"}},
+	}
+	for _, mode := range modes {
+		if err := checkChoice(mode.name, mode.value, mode.allowed); err != nil {
+			log.Fatalf("Invalid flag: %v", err)
+		}
+	}
+
 	// Validate required flags
 	if *dataPath == "" {
 		log.Fatal("Data path is required. Use -data flag to specify CSV file path.")
 	}
 
+	feeTiers, err := parseFeeSchedule(*feeSchedule)
+	if err != nil {
+		log.Fatalf("Invalid fee schedule: %v", err)
+	}
+
+	ladder, err := parseTakeProfitLadder(*tpLadder)
+	if err != nil {
+		log.Fatalf("Invalid take-profit ladder: %v", err)
+	}
+
+	cashFlows, err := parseCashFlows(*cashFlowsFlag)
+	if err != nil {
+		log.Fatalf("Invalid cash flows: %v", err)
+	}
+
+	blackoutRanges, err := parseBlackouts(*blackouts)
+	if err != nil {
+		log.Fatalf("Invalid blackouts: %v", err)
+	}
+
+	capitals, err := parseCapitals(*capacity)
+	if err != nil {
+		log.Fatalf("Invalid capacity capitals: %v", err)
+	}
+
 	// Parse dates
 	var start, end time.Time
-	var err error
 	
 	if *startDate != "" {
 		start, err = time.Parse("2006-01-02", *startDate)
@@ -60,13 +212,18 @@ func main() {
 	}
 
 	// Load stock data
-	fmt.Printf("Loading stock data from %s...\n", *dataPath)
+	progress("Loading stock data from %s...\n", *dataPath)
 	stockData, err := data.LoadStockDataFromCSV(*dataPath)
 	if err != nil {
 		log.Fatalf("Failed to load stock data: %v", err)
 	}
 
-	fmt.Printf("Loaded %d data points\n", len(stockData))
+	stockData, err = data.ResolveDuplicateDates(stockData, *duplicateDates)
+	if err != nil {
+		log.Fatalf("Failed to load stock data: %v", err)
+	}
+
+	progress("Loaded %d data points\n", len(stockData))
 
 	// Filter data by date range if specified
 	if !start.IsZero() || !end.IsZero() {
@@ -77,7 +234,7 @@ func main() {
 			end = stockData[len(stockData)-1].Date
 		}
 		stockData = data.FilterDataByDateRange(stockData, start, end)
-		fmt.Printf("Filtered to %d data points between %s and %s\n", 
+		progress("Filtered to %d data points between %s and %s\n", 
 			len(stockData), start.Format("2006-01-02"), end.Format("2006-01-02"))
 	}
 
@@ -85,45 +242,249 @@ func main() {
 		log.Fatal("No data available for the specified date range")
 	}
 
+	if *monthlyDeposit != 0 {
+		cashFlows = append(cashFlows, monthlyCashFlows(stockData[0].Date, stockData[len(stockData)-1].Date, *monthlyDeposit)...)
+	}
+
 	// Create backtest configuration
 	config := types.BacktestConfig{
-		StockDataPath:  *dataPath,
-		InitialCapital: *initialCapital,
-		TradeFee:       *tradeFee,
-		Slippage:       *slippage,
-		StartDate:      stockData[0].Date,
-		EndDate:        stockData[len(stockData)-1].Date,
+		StockDataPath:          *dataPath,
+		InitialCapital:         *initialCapital,
+		TradeFee:               *tradeFee,
+		Slippage:               *slippage,
+		SlippageModel:          *slipModel,
+		SpreadFactor:           *spreadFactor,
+		VolumeImpact:           *volumeImpact,
+		MaxVolumeParticipation: *participation,
+		Logger:                 logger,
+		PositionLog:            *positionLog,
+		ReturnType:             *returnType,
+		RiskFreeRate:           *riskFreeRate,
+		DisableGapFill:         !*gapFill,
+		FreshEntryAfterStop:    !*reentryStop,
+		AllowShorts:            *allowShorts,
+		MaxOpenPositions:       *maxPositions,
+		MinAnnualizeDays:       *minAnnualize,
+		BarCalendar:            *barCalendar,
+		FeeSchedule:            feeTiers,
+		CashFlows:              cashFlows,
+		MakerFee:               *makerFee,
+		TakerFee:               *takerFee,
+		Blackouts:              blackoutRanges,
+		FlattenInBlackout:      *flattenBlack,
+		SettlementDays:         *settlement,
+		BetaWindow:             *betaWindow,
+		DrawdownBasis:          *drawdownBasis,
+		FlatAtWeekEnd:          *flatWeekEnd,
+		FlatAtMonthEnd:         *flatMonthEnd,
+		SkipLeading:            *skipLeading,
+		SkipTrailing:           *skipTrailing,
+		MaxPositionAgeDays:     *maxPositionAge,
+		EquityFilterPeriod:     *equityFilter,
+		PerformanceFeeRate:     *perfFee,
+		PerformanceFeePeriod:   *perfFeePeriod,
+		RoundToCents:           *roundToCents,
+		StartDate:              stockData[0].Date,
+		EndDate:                stockData[len(stockData)-1].Date,
 		StrategyConfig: types.StrategyConfig{
-			BuyThreshold:   *buyThreshold,
-			SellThreshold:  *sellThreshold,
-			StopLoss:       *stopLoss,
-			TakeProfit:     *takeProfit,
-			InitialCapital: *initialCapital,
-			RSIPeriod:      *rsiPeriod,
-			BBPeriod:       *bbPeriod,
-			BBStdDev:       *bbStdDev,
+			Strategy:            *strategyName,
+			FastMAPeriod:        *fastMA,
+			SlowMAPeriod:        *slowMA,
+			VoteWeights: types.VoteWeights{
+				RSI:        *voteRSI,
+				PercentB:   *votePercentB,
+				Stochastic: *voteStoch,
+				CCI:        *voteCCI,
+			},
+			VoteThreshold:       *voteThreshold,
+			StochasticPeriod:    *stochPeriod,
+			CCIPeriod:           *cciPeriod,
+			BuyThreshold:        *buyThreshold,
+			SellThreshold:       *sellThreshold,
+			StopLoss:            *stopLoss,
+			TakeProfit:          *takeProfit,
+			InitialCapital:      *initialCapital,
+			RSIPeriod:           *rsiPeriod,
+			RSISmoothing:        *rsiSmoothing,
+			BBPeriod:            *bbPeriod,
+			BBStdDev:            *bbStdDev,
+			SignalPriority:      *signalPriority,
+			EntryTrigger:        *entryTrigger,
+			ConfirmBars:         *confirmBars,
+			MaxEntryGapPct:      *maxEntryGap,
+			TrendFilterPeriod:   *trendFilter,
+			RSIExitLevel:        *rsiExitLevel,
+			ExitOnUpperBand:     *exitUpperBand,
+			StopMode:            *stopMode,
+			StopEquityPct:       *stopEquityPct,
+			ATRPeriod:           *atrPeriod,
+			ATRMultiplier:       *atrMultiplier,
+			StopActivationDelay: *stopDelay,
+			TargetR:             *targetR,
+			PartialTargetR:      *partialTargetR,
+			PartialFraction:     *partialFrac,
+			TakeProfitLadder:    ladder,
 		},
 		RiskManagementConfig: types.RiskManagementConfig{
-			MaxDrawdown:  *maxDrawdown,
-			PositionSize: *positionSize,
+			MaxDrawdown:          *maxDrawdown,
+			PositionSize:         *positionSize,
+			MinShares:            *minShares,
+			MaxShares:            *maxShares,
+			SizingMode:           *sizingMode,
+			TargetVolatility:     *targetVol,
+			VolatilityLookback:   *volLookback,
+			AverageDownStep:      *avgDownStep,
+			MaxAverageDowns:      *maxAvgDowns,
+			MaxDailyLoss:         *maxDailyLoss,
+			StrengthScaledSizing: *strengthSizing,
+			MinStrengthFraction:  *strengthMin,
+			MaxStrengthFraction:  *strengthMax,
 		},
 	}
 
 	// Run backtest
-	fmt.Println("Running backtest...")
+	progress("Running backtest...\n")
 	engine := backtesting.NewEngine(config)
 	result, err := engine.Run(stockData)
 	if err != nil {
 		log.Fatalf("Backtest failed: %v", err)
 	}
 
+	// Export the metrics, tagged with the symbol and strategy parameters, if requested
+	if *influxDest != "" {
+		line := export.InfluxLine(*influxMeasure, influxTags(extractStockSymbol(*dataPath), config.StrategyConfig), result)
+		if err := export.WriteInflux(*influxDest, os.Getenv("INFLUX_TOKEN"), line); err != nil {
+			log.Printf("InfluxDB export failed: %v", err)
+		}
+	}
+
+	// A single line replaces the report, baselines and charts
+	if *summaryLine {
+		fmt.Println(formatSummaryLine(result))
+		return
+	}
+
 	// Display results
 	printResults(result)
+	if *positionLog {
+		printPositionLog(result.PositionLog)
+	}
+
+	// Compare against random entries if requested
+	if *baselineRuns > 0 {
+		baseline, err := engine.RunRandomBaseline(stockData, result, *baselineRuns, *baselineSeed)
+		if err != nil {
+			log.Printf("Random baseline skipped: %v", err)
+		} else {
+			printBaseline("Random Entry Baseline", baseline)
+		}
+	}
+
+	// Check the edge survives on resampled prices if requested
+	if *bootstrapRuns > 0 {
+		bootstrap, err := engine.RunBlockBootstrap(stockData, result, *bootstrapRuns, *bootstrapBlock, *bootstrapSeed)
+		if err != nil {
+			log.Printf("Block bootstrap skipped: %v", err)
+		} else {
+			printBaseline("Block Bootstrap", bootstrap)
+		}
+	}
+
+	// Backtest each rolling window separately, if requested
+	if *rollingWindow > 0 {
+		step := *rollingStep
+		if step <= 0 {
+			step = *rollingWindow
+		}
+
+		windows, err := backtesting.RunRollingWindows(config, stockData, *rollingWindow, step)
+		if err != nil {
+			log.Printf("Rolling windows skipped: %v", err)
+		} else {
+			printRollingWindows(windows)
+		}
+	}
+
+	// Re-run at each capital level to see where market impact erodes the return, if requested
+	var capacityPoints []types.CapacityPoint
+	if len(capitals) > 0 {
+		capacityPoints, err = backtesting.RunCapacityAnalysis(config, capitals, stockData)
+		if err != nil {
+			log.Printf("Capacity analysis skipped: %v", err)
+		} else {
+			printCapacity(capacityPoints)
+		}
+	}
 
 	// Generate charts if requested
 	if *generateCharts {
-		generateVisualizationCharts(stockData, result, *chartOutput, *dataPath)
+		generateVisualizationCharts(stockData, result, capacityPoints, engine.WarmUpBars(), *chartMaxPoints, *chartOutput, *dataPath)
+	}
+}
+
+// printRollingWindows displays the key results of each rolling window, one line each
+func printRollingWindows(windows []types.WindowResult) {
+	fmt.Println("\nRolling Windows:")
+	for _, window := range windows {
+		result := window.Result
+		fmt.Printf("  %s to %s  return %7.2f%%  max DD %6.2f%%  Sharpe %5.2f  trades %d\n",
+			window.Start.Format("2006-01-02"), window.End.AddDate(0, 0, -1).Format("2006-01-02"),
+			result.TotalReturn, result.MaxDrawdown, result.SharpeRatio, result.TotalTrades)
+	}
+}
+
+// printCapacity displays the return at each capital level of the capacity analysis
+func printCapacity(points []types.CapacityPoint) {
+	fmt.Println("\nCapacity Analysis:")
+	for _, point := range points {
+		fmt.Printf("  $%-16.2f return %7.2f%%  P&L $%.2f  trades %d\n",
+			point.InitialCapital, point.TotalReturn, point.TotalProfitLoss, point.TotalTrades)
+	}
+}
+
+// printPositionLog prints each snapshot of the open positions, one line per event and one
+// indented line per position
+func printPositionLog(snapshots []types.PositionSnapshot) {
+	fmt.Println("\nPosition Log:")
+	for _, snapshot := range snapshots {
+		fmt.Printf("  %s  price %.2f  cash $%.2f  equity $%.2f\n",
+			snapshot.Date.Format("2006-01-02"), snapshot.Price, snapshot.Cash, snapshot.Equity)
+		for _, position := range snapshot.Positions {
+			fmt.Printf("    %s  %d @ %.2f  value $%.2f  unrealized $%.2f\n",
+				position.TradeID, position.Quantity, position.EntryPrice, position.MarketValue, position.UnrealizedPnL)
+		}
+	}
+}
+
+// formatSummaryLine returns the key results as one comma-separated line: total return,
+// annualized return, Sharpe ratio, max drawdown (all percentages except Sharpe), total
+// trades, win rate and final capital. The annualized return is empty when the period is
+// too short to annualize.
+func formatSummaryLine(result *types.BacktestResult) string {
+	annualized := ""
+	if result.AnnualizedReturnValid {
+		annualized = strconv.FormatFloat(result.AnnualizedReturn, 'f', 4, 64)
 	}
+
+	return strings.Join([]string{
+		strconv.FormatFloat(result.TotalReturn, 'f', 4, 64),
+		annualized,
+		strconv.FormatFloat(result.SharpeRatio, 'f', 4, 64),
+		strconv.FormatFloat(result.MaxDrawdown, 'f', 4, 64),
+		strconv.FormatInt(result.TotalTrades, 10),
+		strconv.FormatFloat(result.WinRate, 'f', 4, 64),
+		strconv.FormatFloat(result.FinalCapital, 'f', 2, 64),
+	}, ",")
+}
+
+// printBaseline displays how the strategy compares with random entries or resampled prices
+func printBaseline(title string, baseline *types.BaselineResult) {
+	fmt.Printf("\n%s:\n", title)
+	fmt.Printf("  Runs:               %d\n", len(baseline.Returns))
+	fmt.Printf("  Mean Return:        %.2f%%\n", baseline.Mean)
+	fmt.Printf("  Median Return:      %.2f%%\n", baseline.Median)
+	fmt.Printf("  Strategy Return:    %.2f%%\n", baseline.StrategyReturn)
+	fmt.Printf("  Runs Beaten:        %.1f%%\n", baseline.Percentile)
 }
 
 // printResults displays the backtest results in a formatted way
@@ -142,7 +503,21 @@ func printResults(result *types.BacktestResult) {
 	fmt.Printf("  Final Capital:      $%.2f\n", result.FinalCapital)
 	fmt.Printf("  Total P&L:          $%.2f\n", result.TotalProfitLoss)
 	fmt.Printf("  Total Return:       %.2f%%\n", result.TotalReturn)
-	fmt.Printf("  Annualized Return:  %.2f%%\n", result.AnnualizedReturn)
+	if result.AnnualizedReturnValid {
+		fmt.Printf("  Annualized Return:  %.2f%%\n", result.AnnualizedReturn)
+	} else {
+		fmt.Println("  Annualized Return:  n/a (period too short to annualize)")
+	}
+	if result.NetCashFlows != 0 {
+		fmt.Printf("  Net Cash Flows:     $%.2f\n", result.NetCashFlows)
+		fmt.Printf("  Money-Weighted:     %.2f%%\n", result.MoneyWeightedReturn)
+	}
+	if result.PerformanceFees > 0 {
+		fmt.Printf("  Performance Fees:   $%.2f\n", result.PerformanceFees)
+	}
+	if result.CostDragPct > 0 {
+		fmt.Printf("  Cost Drag:          %.1f%% of gross P&L\n", result.CostDragPct)
+	}
 	
 	fmt.Println("\nTrade Statistics:")
 	fmt.Printf("  Total Trades:       %d\n", result.TotalTrades)
@@ -150,15 +525,52 @@ func printResults(result *types.BacktestResult) {
 	fmt.Printf("  Losing Trades:      %d\n", result.LosingTrades)
 	fmt.Printf("  Win Rate:           %.1f%%\n", result.WinRate)
 	
+	fmt.Printf("  Time in Market:     %.1f%%\n", result.TimeInMarketPct)
+	
 	if result.AverageWin > 0 {
 		fmt.Printf("  Average Win:        $%.2f\n", result.AverageWin)
 	}
 	if result.AverageLoss > 0 {
 		fmt.Printf("  Average Loss:       $%.2f\n", result.AverageLoss)
 	}
+	if result.WinningTrades > 0 {
+		fmt.Printf("  Avg Bars to Win:    %.1f\n", result.AvgBarsToWin)
+	}
+	if result.LosingTrades > 0 {
+		fmt.Printf("  Avg Bars to Loss:   %.1f\n", result.AvgBarsToLoss)
+	}
+	if result.ProfitFactor == math.MaxFloat64 {
+		fmt.Printf("  Profit Factor:      no losses\n")
+	} else if result.TotalTrades > 0 {
+		fmt.Printf("  Profit Factor:      %.2f\n", result.ProfitFactor)
+	}
+	if result.TotalTrades > 0 {
+		fmt.Printf("  Expectancy:         $%.2f per trade\n", result.Expectancy)
+	}
 	
+	if len(result.PnLByTag) > 1 {
+		fmt.Println("\nP&L by Tag:")
+		tags := make([]string, 0, len(result.PnLByTag))
+		for tag := range result.PnLByTag {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		for _, tag := range tags {
+			fmt.Printf("  %-19s $%.2f\n", tag+":", result.PnLByTag[tag])
+		}
+	}
+
 	fmt.Println("\nRisk Metrics:")
 	fmt.Printf("  Max Drawdown:       %.2f%%\n", result.MaxDrawdown)
+	fmt.Printf("  Max DD Duration:    %.0f days\n", result.MaxDrawdownDuration.Hours()/24)
+	fmt.Printf("  Relative Drawdown:  %.2f%%\n", result.RelativeMaxDrawdown)
+	fmt.Printf("  Volatility:         %.2f%%\n", result.Volatility)
+	fmt.Printf("  Sharpe Ratio:       %.2f\n", result.SharpeRatio)
+	fmt.Printf("  Sortino Ratio:      %.2f\n", result.SortinoRatio)
+	if n := len(result.RollingBeta); n > 0 {
+		fmt.Printf("  Rolling Beta:       %.2f\n", result.RollingBeta[n-1])
+		fmt.Printf("  Rolling Corr.:      %.2f\n", result.RollingCorrelation[n-1])
+	}
 	
 	if len(result.Trades) > 0 {
 		fmt.Println("\nRecent Trades:")
@@ -195,7 +607,7 @@ func printResults(result *types.BacktestResult) {
 }
 
 // generateVisualizationCharts creates HTML charts for the backtest results
-func generateVisualizationCharts(stockData []types.StockData, result *types.BacktestResult, outputDir, dataPath string) {
+func generateVisualizationCharts(stockData []types.StockData, result *types.BacktestResult, capacity []types.CapacityPoint, warmUpBars, maxPoints int, outputDir, dataPath string) {
 	// Create output directory if it doesn't exist
 	err := os.MkdirAll(outputDir, 0755)
 	if err != nil {
@@ -208,9 +620,12 @@ func generateVisualizationCharts(stockData []types.StockData, result *types.Back
 
 	fmt.Println("\nGenerating visualization charts...")
 
-	// Generate K-Line chart with trade markers
+	// Generate K-Line chart with trade markers, downsampled for long histories. The backtest
+	// itself always runs on every bar.
+	chartData, barsPerPoint := visualization.DownsampleOHLC(stockData, maxPoints)
+	chartWarmUp := (warmUpBars + barsPerPoint - 1) / barsPerPoint
 	klineFile := fmt.Sprintf("%s/%s_price_chart.html", outputDir, stockSymbol)
-	err = visualization.GenerateKLineChartWithTrades(stockData, result.Trades, stockSymbol, klineFile)
+	err = visualization.GenerateKLineChartWithTrades(chartData, result.Trades, chartWarmUp, stockSymbol, klineFile)
 	if err != nil {
 		log.Printf("Failed to generate K-Line chart: %v", err)
 	} else {
@@ -219,13 +634,43 @@ func generateVisualizationCharts(stockData []types.StockData, result *types.Back
 
 	// Generate account balance chart
 	balanceFile := fmt.Sprintf("%s/%s_balance_chart.html", outputDir, stockSymbol)
-	err = visualization.GenerateAccountBalanceChart(stockData, result.Trades, result.InitialCapital, stockSymbol, balanceFile)
+	err = visualization.GenerateAccountBalanceChart(stockData, result.EquityCurve, stockSymbol, balanceFile)
 	if err != nil {
 		log.Printf("Failed to generate balance chart: %v", err)
 	} else {
 		fmt.Printf("✓ Generated balance chart: %s\n", balanceFile)
 	}
 
+	// Generate trade return histogram
+	histogramFile := fmt.Sprintf("%s/%s_returns_chart.html", outputDir, stockSymbol)
+	distribution := backtesting.CalculateReturnDistribution(result.Trades, 20)
+	err = visualization.GenerateReturnHistogramChart(distribution, stockSymbol, histogramFile)
+	if err != nil {
+		log.Printf("Failed to generate return histogram: %v", err)
+	} else {
+		fmt.Printf("✓ Generated return histogram: %s\n", histogramFile)
+	}
+
+	// Generate trade-by-trade equity waterfall
+	waterfallFile := fmt.Sprintf("%s/%s_waterfall_chart.html", outputDir, stockSymbol)
+	err = visualization.GenerateEquityWaterfallChart(result, stockSymbol, waterfallFile)
+	if err != nil {
+		log.Printf("Failed to generate equity waterfall: %v", err)
+	} else {
+		fmt.Printf("✓ Generated equity waterfall: %s\n", waterfallFile)
+	}
+
+	// Generate the return vs capital chart of a capacity analysis
+	if len(capacity) > 0 {
+		capacityFile := fmt.Sprintf("%s/%s_capacity_chart.html", outputDir, stockSymbol)
+		err = visualization.GenerateCapacityChart(capacity, stockSymbol, capacityFile)
+		if err != nil {
+			log.Printf("Failed to generate capacity chart: %v", err)
+		} else {
+			fmt.Printf("✓ Generated capacity chart: %s\n", capacityFile)
+		}
+	}
+
 	fmt.Println("\nVisualization charts generated successfully!")
 	fmt.Printf("Open the HTML files in your browser to view the interactive charts.\n")
 }
@@ -245,3 +690,198 @@ func extractStockSymbol(dataPath string) string {
 	}
 	return strings.ToUpper(name)
 }
+
+// influxTags returns the tags identifying a backtest in the InfluxDB export: the symbol, the
+// strategy, its stop and take profit, and the parameters of that strategy's signal logic
+func influxTags(symbol string, config types.StrategyConfig) map[string]string {
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+	name := config.Strategy
+	if name == "" {
+		name = "bb_rsi"
+	}
+	tags := map[string]string{
+		"symbol":      symbol,
+		"strategy":    name,
+		"stop_loss":   format(config.StopLoss),
+		"take_profit": format(config.TakeProfit),
+	}
+
+	switch name {
+	case "ma_crossover":
+		tags["fast_ma"] = strconv.Itoa(config.FastMAPeriod)
+		tags["slow_ma"] = strconv.Itoa(config.SlowMAPeriod)
+	case "voting":
+		tags["vote_rsi"] = format(config.VoteWeights.RSI)
+		tags["vote_percent_b"] = format(config.VoteWeights.PercentB)
+		tags["vote_stochastic"] = format(config.VoteWeights.Stochastic)
+		tags["vote_cci"] = format(config.VoteWeights.CCI)
+		tags["vote_threshold"] = format(config.VoteThreshold)
+		tags["rsi_period"] = strconv.Itoa(config.RSIPeriod)
+		tags["bb_period"] = strconv.Itoa(config.BBPeriod)
+		tags["bb_std_dev"] = format(config.BBStdDev)
+		tags["stochastic_period"] = strconv.Itoa(config.StochasticPeriod)
+		tags["cci_period"] = strconv.Itoa(config.CCIPeriod)
+	default:
+		tags["rsi_period"] = strconv.Itoa(config.RSIPeriod)
+		tags["bb_period"] = strconv.Itoa(config.BBPeriod)
+		tags["bb_std_dev"] = format(config.BBStdDev)
+		tags["buy_threshold"] = format(config.BuyThreshold)
+		tags["sell_threshold"] = format(config.SellThreshold)
+	}
+
+	return tags
+}
+
+// parseFeeSchedule parses comma-separated notional:rate pairs into fee tiers
+func parseFeeSchedule(schedule string) ([]types.FeeTier, error) {
+	if schedule == "" {
+		return nil, nil
+	}
+
+	var tiers []types.FeeTier
+	for _, pair := range strings.Split(schedule, ",") {
+		parts := strings.Split(strings.TrimSpace(pair), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected notional:rate, got %q", pair)
+		}
+
+		notional, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notional in %q: %w", pair, err)
+		}
+		rate, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate in %q: %w", pair, err)
+		}
+
+		tiers = append(tiers, types.FeeTier{MinNotional: notional, Rate: rate})
+	}
+
+	return tiers, nil
+}
+
+// parseTakeProfitLadder parses comma-separated gain:fraction pairs into take-profit ladder levels
+func parseTakeProfitLadder(ladder string) ([]types.TakeProfitLevel, error) {
+	if ladder == "" {
+		return nil, nil
+	}
+
+	var levels []types.TakeProfitLevel
+	for _, pair := range strings.Split(ladder, ",") {
+		parts := strings.Split(strings.TrimSpace(pair), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected gain:fraction, got %q", pair)
+		}
+
+		gain, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gain in %q: %w", pair, err)
+		}
+		fraction, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fraction in %q: %w", pair, err)
+		}
+
+		levels = append(levels, types.TakeProfitLevel{Gain: gain, Fraction: fraction})
+	}
+
+	return levels, nil
+}
+
+// parseCashFlows parses comma-separated date:amount pairs into cash flows
+func parseCashFlows(flows string) ([]types.CashFlow, error) {
+	if flows == "" {
+		return nil, nil
+	}
+
+	var cashFlows []types.CashFlow
+	for _, pair := range strings.Split(flows, ",") {
+		parts := strings.Split(strings.TrimSpace(pair), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected date:amount, got %q", pair)
+		}
+
+		date, err := time.Parse("2006-01-02", parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid date in %q: %w", pair, err)
+		}
+		amount, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount in %q: %w", pair, err)
+		}
+
+		cashFlows = append(cashFlows, types.CashFlow{Date: date, Amount: amount})
+	}
+
+	return cashFlows, nil
+}
+
+// monthlyCashFlows returns a flow of amount on the first day of each month after start,
+// up to end
+func monthlyCashFlows(start, end time.Time, amount float64) []types.CashFlow {
+	var flows []types.CashFlow
+	for date := time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, start.Location()); !date.After(end); date = date.AddDate(0, 1, 0) {
+		flows = append(flows, types.CashFlow{Date: date, Amount: amount})
+	}
+	return flows
+}
+
+// parseBlackouts parses comma-separated start:end date pairs into blackout ranges
+func parseBlackouts(blackouts string) ([]types.DateRange, error) {
+	if blackouts == "" {
+		return nil, nil
+	}
+
+	var ranges []types.DateRange
+	for _, pair := range strings.Split(blackouts, ",") {
+		parts := strings.Split(strings.TrimSpace(pair), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected start:end, got %q", pair)
+		}
+
+		start, err := time.Parse("2006-01-02", parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid start in %q: %w", pair, err)
+		}
+		end, err := time.Parse("2006-01-02", parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid end in %q: %w", pair, err)
+		}
+
+		ranges = append(ranges, types.DateRange{Start: start, End: end})
+	}
+
+	return ranges, nil
+}
+
+// checkChoice returns an error unless the flag's value is one of the allowed values
+func checkChoice(name, value string, allowed []string) error {
+	for _, choice := range allowed {
+		if value == choice {
+			return nil
+		}
+	}
+	return fmt.Errorf("-%s must be one of %s, got %q", name, strings.Join(allowed, ", "), value)
+}
+
+// parseCapitals parses comma-separated initial capitals for the capacity analysis
+func parseCapitals(capitals string) ([]float64, error) {
+	if capitals == "" {
+		return nil, nil
+	}
+
+	var levels []float64
+	for _, value := range strings.Split(capitals, ",") {
+		capital, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid capital %q: %w", value, err)
+		}
+		if capital <= 0 {
+			return nil, fmt.Errorf("capital must be positive, got %q", value)
+		}
+		levels = append(levels, capital)
+	}
+
+	return levels, nil
+}
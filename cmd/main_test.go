@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strings"
+	"swing-trader/internal/types"
+	"testing"
+)
+
+func TestFormatSummaryLine(t *testing.T) {
+	result := &types.BacktestResult{
+		TotalReturn:           12.3456,
+		AnnualizedReturn:      6.5,
+		AnnualizedReturnValid: true,
+		SharpeRatio:           1.25,
+		MaxDrawdown:           8.75,
+		TotalTrades:           42,
+		WinRate:               57.1429,
+		FinalCapital:          11234.567,
+	}
+
+	line := formatSummaryLine(result)
+	if strings.Contains(line, "\n") {
+		t.Fatalf("Expected a single line, got %q", line)
+	}
+
+	fields := strings.Split(line, ",")
+	if len(fields) != 7 {
+		t.Fatalf("Expected 7 fields, got %d in %q", len(fields), line)
+	}
+
+	expected := []string{"12.3456", "6.5000", "1.2500", "8.7500", "42", "57.1429", "11234.57"}
+	for i, e := range expected {
+		if fields[i] != e {
+			t.Errorf("Expected field %d to be %s, got %s", i, e, fields[i])
+		}
+	}
+
+	// A period too short to annualize leaves that field empty but keeps the count
+	result.AnnualizedReturnValid = false
+	fields = strings.Split(formatSummaryLine(result), ",")
+	if len(fields) != 7 || fields[1] != "" {
+		t.Errorf("Expected an empty annualized return among 7 fields, got %q", fields)
+	}
+}
+
+func TestCheckChoice(t *testing.T) {
+	allowed := []string{"percent", "atr", "equity"}
+
+	if err := checkChoice("stop-mode", "atr", allowed); err != nil {
+		t.Errorf("Expected an allowed value to pass, got %v", err)
+	}
+
+	err := checkChoice("stop-mode", "atrr", allowed)
+	if err == nil {
+		t.Fatal("Expected a typo to be rejected")
+	}
+	if !strings.Contains(err.Error(), "-stop-mode") || !strings.Contains(err.Error(), "percent, atr, equity") {
+		t.Errorf("Expected the error to name the flag and its values, got %q", err)
+	}
+}
+
+func TestInfluxTags(t *testing.T) {
+	config := types.StrategyConfig{
+		StopLoss:         0.05,
+		TakeProfit:       0.1,
+		RSIPeriod:        14,
+		BBPeriod:         20,
+		BBStdDev:         2,
+		BuyThreshold:     30,
+		SellThreshold:    70,
+		FastMAPeriod:     50,
+		SlowMAPeriod:     200,
+		VoteWeights:      types.VoteWeights{RSI: 1, PercentB: 0.5, Stochastic: 1, CCI: 0},
+		VoteThreshold:    0.6,
+		StochasticPeriod: 14,
+		CCIPeriod:        20,
+	}
+
+	tests := []struct {
+		strategy string
+		expected map[string]string
+		absent   []string
+	}{
+		{
+			strategy: "",
+			expected: map[string]string{"strategy": "bb_rsi", "rsi_period": "14", "bb_period": "20", "bb_std_dev": "2", "buy_threshold": "30", "sell_threshold": "70"},
+			absent:   []string{"fast_ma", "slow_ma", "vote_threshold"},
+		},
+		{
+			strategy: "ma_crossover",
+			expected: map[string]string{"strategy": "ma_crossover", "fast_ma": "50", "slow_ma": "200"},
+			absent:   []string{"rsi_period", "bb_period", "buy_threshold", "vote_threshold"},
+		},
+		{
+			strategy: "voting",
+			expected: map[string]string{"strategy": "voting", "vote_rsi": "1", "vote_percent_b": "0.5", "vote_stochastic": "1", "vote_cci": "0", "vote_threshold": "0.6", "stochastic_period": "14", "cci_period": "20"},
+			absent:   []string{"fast_ma", "buy_threshold", "sell_threshold"},
+		},
+	}
+
+	for _, tt := range tests {
+		config.Strategy = tt.strategy
+		tags := influxTags("AAPL", config)
+
+		// Every strategy is tagged with the symbol and its stop and take profit
+		for key, value := range map[string]string{"symbol": "AAPL", "stop_loss": "0.05", "take_profit": "0.1"} {
+			if tags[key] != value {
+				t.Errorf("%q: expected tag %s=%s, got %q", tt.strategy, key, value, tags[key])
+			}
+		}
+		for key, value := range tt.expected {
+			if tags[key] != value {
+				t.Errorf("%q: expected tag %s=%s, got %q", tt.strategy, key, value, tags[key])
+			}
+		}
+		for _, key := range tt.absent {
+			if _, ok := tags[key]; ok {
+				t.Errorf("%q: expected no %s tag, got %q", tt.strategy, key, tags[key])
+			}
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package types
 
-import "time"
+import (
+	"log/slog"
+	"time"
+)
 
 // StockData represents a single day's stock data
 type StockData struct {
@@ -15,16 +18,43 @@ type StockData struct {
 
 // Trade represents a single trade with entry and exit information
 type Trade struct {
-	ID         string
-	EntryDate  time.Time
-	ExitDate   *time.Time // Pointer to handle open trades
-	EntryPrice float64
-	ExitPrice  *float64 // Pointer to handle open trades
-	Quantity   int64
-	ProfitLoss float64
-	Status     string // "open", "closed", "cancelled"
-	StopLoss   float64
-	TakeProfit float64
+	ID            string
+	EntryDate     time.Time
+	ExitDate      *time.Time // Pointer to handle open trades
+	EntryPrice    float64
+	ExitPrice     *float64 // Pointer to handle open trades
+	Quantity      int64
+	ProfitLoss    float64
+	Status        string // "open", "closed", "cancelled"
+	Direction     string // "long" or "short", empty is treated as long
+	StopLoss      float64
+	TakeProfit    float64
+	MAE           float64 // maximum adverse excursion: worst per-share move against the trade while open
+	MFE           float64 // maximum favorable excursion: best per-share move in favor of the trade while open
+	AverageDowns  int     // number of times the position was added to while losing
+	PartialTarget float64 // price at which part of the position is taken off, 0 once taken or when disabled
+	LadderStep    int     // take-profit ladder levels already filled
+	LadderBase    int64   // position size the take-profit ladder fractions apply to
+	Tag           string  // entry rule that opened the trade, copied from the BUY signal (e.g., "bb_rsi")
+	ExitReason    string  // why the trade closed, e.g. "signal", "stop_loss", "take_profit", "week_end" or "end_of_data"
+	EntryBar      int     // index of the entry bar in the backtest data
+	FillBar       int     // index of the bar the entry order finished filling on, after EntryBar when spread over several bars
+	Fills         []Fill  // shares bought after the entry date, by averaging down or an order spread over several bars, included in Quantity and EntryPrice
+	ExitBar       int     // index of the exit bar in the backtest data, 0 while open
+
+	// Risk at entry, for checking the sizing did what was configured
+	CapitalAtEntry float64 // equity when the trade was opened
+	IntendedRisk   float64 // amount the sizing aimed to lose at the stop: capital times the risked fraction
+	ActualRisk     float64 // amount lost at the stop as opened: (entry - stop) x shares, before costs
+	SizingClamped  bool    // share count was changed by the capital limit, the MinShares/MaxShares bounds or the OrderHook
+}
+
+// Fill is a block of shares bought into an open trade after its entry date, so the position
+// is only marked to market once they are held
+type Fill struct {
+	Date     time.Time
+	Quantity int64
+	Price    float64 // fill price after slippage
 }
 
 // TradeResult provides summary statistics for a collection of trades
@@ -38,20 +68,65 @@ type TradeResult struct {
 
 // StrategyConfig holds the configuration for the trading strategy
 type StrategyConfig struct {
-	BuyThreshold   float64 // RSI threshold for buying (e.g., 30)
-	SellThreshold  float64 // RSI threshold for selling (e.g., 70)
-	StopLoss       float64 // percentage for stop loss (e.g., 0.05 for 5%)
-	TakeProfit     float64 // percentage for take profit (e.g., 0.10 for 10%)
-	InitialCapital float64 // starting capital for the backtest
-	RSIPeriod      int     // period for RSI calculation (typically 14)
-	BBPeriod       int     // period for Bollinger Bands (typically 20)
-	BBStdDev       float64 // standard deviation multiplier for Bollinger Bands (typically 2.0)
+	Strategy            string            // signal logic: "bb_rsi" (default, Bollinger Bands + RSI), "ma_crossover" or "voting"
+	BuyThreshold        float64           // RSI threshold for buying (e.g., 30)
+	SellThreshold       float64           // RSI threshold for selling (e.g., 70)
+	StopLoss            float64           // percentage for stop loss (e.g., 0.05 for 5%)
+	TakeProfit          float64           // percentage for take profit (e.g., 0.10 for 10%)
+	InitialCapital      float64           // starting capital for the backtest
+	RSIPeriod           int               // period for RSI calculation (typically 14)
+	RSISmoothing        string            // averaging of RSI gains and losses: "wilder" (default), "ema" or "sma"
+	BBPeriod            int               // period for Bollinger Bands (typically 20)
+	BBStdDev            float64           // standard deviation multiplier for Bollinger Bands (typically 2.0)
+	SignalPriority      string            // signal that wins when BUY and SELL conditions both hold: "buy" (default) or "sell"
+	StopMode            string            // how the stop loss is placed: "percent" (default, uses StopLoss), "atr" or "equity"
+	StopEquityPct       float64           // equity lost when stopped out for the "equity" stop mode (e.g., 0.01 for 1%)
+	ATRPeriod           int               // period for the ATR used by the "atr" stop mode (typically 14)
+	ATRMultiplier       float64           // stop distance in multiples of ATR for the "atr" stop mode (e.g., 2.0)
+	StopActivationDelay int               // bars, counting the entry bar, before the stop loss is checked so entry noise cannot trigger it (0 checks from the entry bar)
+	EntryTrigger        string            // lower band breach for entries: "close" (default, close < lower) or "touch" (low <= lower)
+	ConfirmBars         int               // additional consecutive bars the BUY condition must hold before acting (0 acts immediately)
+	RSIExitLevel        float64           // after an entry, SELL when RSI crosses back above this level (e.g., 50), 0 disables
+	ExitOnUpperBand     bool              // after an entry, SELL with a limit order at the upper band once the bar's high reaches it
+	MaxEntryGapPct      float64           // skip a BUY when the bar opened more than this fraction away from the prior close (e.g., 0.03 for 3%, 0 disables)
+	TrendFilterPeriod   int               // only BUY when the close is above its SMA over this many bars, buying dips in an uptrend (e.g., 200, 0 disables)
+	FastMAPeriod        int               // fast SMA period for the "ma_crossover" strategy (e.g., 50)
+	SlowMAPeriod        int               // slow SMA period for the "ma_crossover" strategy (e.g., 200)
+	VoteWeights         VoteWeights       // weight of each indicator's vote for the "voting" strategy, all 0 never signals
+	VoteThreshold       float64           // weighted vote from 0 to 1 at or above which the "voting" strategy buys, selling at or below its negative (e.g., 0.5)
+	StochasticPeriod    int               // period for the stochastic oscillator vote of the "voting" strategy (typically 14)
+	CCIPeriod           int               // period for the CCI vote of the "voting" strategy (typically 20)
+	TargetR             float64           // final take profit in multiples of the initial risk (entry to stop), 0 uses the TakeProfit percentage
+	PartialTargetR      float64           // take part of the position off at this multiple of the initial risk (0 disables)
+	PartialFraction     float64           // fraction of the position closed at PartialTargetR (e.g., 0.5 for half)
+	TakeProfitLadder    []TakeProfitLevel // exit fractions of the position at ascending gains instead of a single take profit, fractions summing to at most 1
+	IndicatorCache      IndicatorCache    // shares indicator series between runs on the same data, e.g. across a parameter sweep (nil computes them every run)
+	Logger              *slog.Logger      // structured logger for strategy warnings such as conflicting signals, nil uses the engine's logger or slog.Default()
+}
+
+// VoteWeights weight each indicator's vote for the "voting" strategy, with 0 leaving it out
+type VoteWeights struct {
+	RSI        float64
+	PercentB   float64 // Bollinger %B, where the close sits between the bands
+	Stochastic float64
+	CCI        float64
 }
 
 // RiskManagementConfig holds risk management parameters
 type RiskManagementConfig struct {
-	MaxDrawdown  float64 // maximum drawdown percentage (e.g., 0.20 for 20%)
-	PositionSize float64 // percentage of capital to risk per trade (e.g., 0.02 for 2%)
+	MaxDrawdown          float64 // maximum drawdown percentage (e.g., 0.20 for 20%)
+	PositionSize         float64 // percentage of capital to risk per trade (e.g., 0.02 for 2%)
+	MinShares            int64   // floor on shares per trade after sizing (0 for no floor)
+	MaxShares            int64   // ceiling on shares per trade after sizing (0 for no ceiling)
+	SizingMode           string  // "risk" (default) or "vol_target" to scale PositionSize by target over realized volatility
+	TargetVolatility     float64 // annualized volatility target for "vol_target" sizing (e.g., 0.15 for 15%)
+	VolatilityLookback   int     // bars of close-to-close returns used for realized volatility (e.g., 20)
+	AverageDownStep      float64 // add to a losing position each time price falls this far below the first entry (e.g., 0.03 for 3%)
+	MaxAverageDowns      int     // maximum number of adds per position (0 disables averaging down)
+	MaxDailyLoss         float64 // stop new entries for the rest of the day once realized losses reach this fraction of initial capital (0 disables)
+	StrengthScaledSizing bool    // risk between MinStrengthFraction and MaxStrengthFraction in proportion to the signal strength instead of PositionSize
+	MinStrengthFraction  float64 // fraction of capital risked on the weakest signal (strength 0) with strength-scaled sizing
+	MaxStrengthFraction  float64 // fraction of capital risked on the strongest signal (strength 1) with strength-scaled sizing
 }
 
 // BacktestResult contains comprehensive results from a backtest
@@ -64,27 +139,195 @@ type BacktestResult struct {
 	LosingTrades             int64
 	AverageWin               float64
 	AverageLoss              float64
+	ProfitFactor             float64   // gross wins over gross losses; math.MaxFloat64 with wins but no losses, 0 without wins
+	Expectancy               float64   // average P&L per trade expected from the win rate, average win and average loss
 	MaxDrawdown              float64
-	MaxDrawdownDuration      time.Duration
-	TotalReturn              float64
+	MaxDrawdownDuration      time.Duration // longest time from a capital peak to recovering it, or to the end if it never recovers
+	TotalReturn              float64   // percentage return, time-weighted to leave out deposits and withdrawals when there are cash flows
 	AnnualizedReturn         float64
-	SharpeRatio              float64
+	AnnualizedReturnValid    bool      // false when the backtest spans too few days to annualize, leaving AnnualizedReturn at 0
+	SharpeRatio              float64   // annualized mean excess return of the equity curve over its standard deviation
+	SortinoRatio             float64   // annualized mean excess return of the equity curve over its downside deviation
 	StartDate                time.Time
 	EndDate                  time.Time
 	InitialCapital           float64
 	FinalCapital             float64
+	EquityCurve              []float64 // mark-to-market equity at each bar's close, net of performance fees
+	HighWaterMark            []float64 // highest equity reached up to each bar, aligned with EquityCurve
+	PerformanceFees          float64   // total performance fees charged on new highs, deducted from FinalCapital
+	BenchmarkCurve           []float64 // buy-and-hold equity at each bar's close
+	RelativeMaxDrawdown      float64   // max drawdown of equity relative to the benchmark, as a percentage
+	Volatility               float64   // annualized standard deviation of equity-curve returns, as a percentage
+	TimeInMarketPct          float64   // percentage of bars with an open position
+	AvgBarsToWin             float64   // average bars from entry to exit of winning trades
+	AvgBarsToLoss            float64   // average bars from entry to exit of losing trades
+	NetCashFlows             float64   // deposits less withdrawals applied during the backtest, included in FinalCapital
+	MoneyWeightedReturn      float64   // Modified Dietz return on the capital invested over time, as a percentage; with cash flows only
+	CostDragPct              float64   // fees and slippage as a percentage of the gross P&L before them, 0 when the gross P&L is not positive
+	RollingBeta              []float64 // beta of equity-curve returns to the benchmark over a rolling window, aligned with EquityCurve
+	RollingCorrelation       []float64 // correlation of equity-curve returns with the benchmark over the same window
+	PnLByTag                 map[string]float64 // total profit/loss of the trades opened by each signal tag
+	PositionLog              []PositionSnapshot // open positions and cash at each signal or trade event, when PositionLog is enabled
 }
 
 // BacktestConfig holds all configuration for running a backtest
 type BacktestConfig struct {
-	StockDataPath        string
-	StrategyConfig       StrategyConfig
-	RiskManagementConfig RiskManagementConfig
-	StartDate            time.Time
-	EndDate              time.Time
-	InitialCapital       float64
-	TradeFee             float64 // fee per trade, e.g. 0.001 for 0.1%
-	Slippage             float64 // slippage percentage, e.g. 0.001 for 0.1%
+	StockDataPath          string
+	StrategyConfig         StrategyConfig
+	RiskManagementConfig   RiskManagementConfig
+	StartDate              time.Time
+	EndDate                time.Time
+	InitialCapital         float64
+	TradeFee               float64      // fee per trade, e.g. 0.001 for 0.1%
+	Slippage               float64      // slippage percentage, e.g. 0.001 for 0.1%
+	MinDataPoints          int          // minimum bars required to run, raised to the strategy warm-up if lower (0 uses the warm-up)
+	Logger                 *slog.Logger // structured logger for trade events, nil uses slog.Default()
+	PositionLog            bool         // record open positions marked to market with the cash at each signal or trade event in the result
+	OrderHook              OrderHook    // called with each proposed entry before it executes, to veto or resize it (nil approves every order)
+	ReturnType             string       // equity-curve return calculation for risk metrics: "simple" (default) or "log"
+	RiskFreeRate           float64      // annual risk-free rate the Sharpe and Sortino ratios measure excess returns over, e.g. 0.04 for 4%
+	DisableGapFill         bool         // fill stops and targets at their level even when the bar opens beyond them, instead of at the open
+	FreshEntryAfterStop    bool         // after a stop-out, wait for the BUY condition to lapse and recur before re-entering (false re-enters while it persists)
+	AllowShorts            bool         // open a short position on a SELL signal with no long position open, covered by the next BUY
+	MaxOpenPositions       int          // positions open at once, each BUY adding one while capital allows (0 uses 1)
+	MinAnnualizeDays       int          // shortest span in calendar days to annualize returns over (0 uses 30)
+	BarCalendar            string       // bar cadence for annualizing per-bar statistics: "auto" (default, continuous if the data has weekend bars), "business" (252 a year) or "continuous" (365, e.g. crypto)
+	FeeSchedule            []FeeTier    // tiered fee rates by cumulative traded notional, empty uses the flat TradeFee
+	MakerFee               float64      // fee rate on limit-order fills, negative for a liquidity rebate (e.g., -0.0002)
+	TakerFee               float64      // fee rate on market, MOC, stop and target fills; with MakerFee, replaces TradeFee and FeeSchedule when either is set
+	CashFlows              []CashFlow   // dated deposits and withdrawals, each applied to available capital on the first bar on or after its date
+	Blackouts              []DateRange  // windows with no new entries, e.g. around earnings or central bank meetings
+	FlattenInBlackout      bool         // also close open positions on the first signal bar inside a blackout
+	SettlementDays         int          // bars before sale proceeds can fund new buys, e.g. 2 for T+2 (0 settles immediately)
+	BetaWindow             int          // bars of returns for the rolling beta and correlation (0 uses 63)
+	DrawdownBasis          string       // max drawdown from "close" (default, capital after each trade close) or "intrabar" (equity with open positions at each bar's low)
+	FlatAtWeekEnd          bool         // close all positions on the last bar of each week and take no entries on it
+	FlatAtMonthEnd         bool         // close all positions on the last bar of each month and take no entries on it
+	SkipLeading            int          // leading bars excluded from trade execution, so no entries happen in them
+	SkipTrailing           int          // trailing bars excluded from trade execution; open positions close on the last bar before them
+	MaxPositionAgeDays     int          // force-close with a warning any position open this many calendar days, a safety cap against stale positions (0 disables)
+	EquityFilterPeriod     int          // suspend new entries while equity is below its moving average over this many bars, resuming once it recovers (0 disables)
+	PerformanceFeeRate     float64      // fee on gains above the high-water mark at each fee period end, e.g. 0.2 for 20% (0 disables)
+	PerformanceFeePeriod   string       // how often performance fees are charged: "monthly", "quarterly" or "annual" (default)
+	RoundToCents           bool         // round cash movements, trade P&L and final capital to whole cents
+	MaxVolumeParticipation float64      // largest share of a bar's volume an entry fills, spreading larger orders over the following bars at their volume-weighted average price (0 fills at once)
+	SlippageModel          string       // "fixed" (default, Slippage only) or "volume" (adds half the estimated spread and a volume impact)
+	SpreadFactor           float64      // share of a bar's high-low range taken as its bid-ask spread by the volume model (0 uses 0.1)
+	VolumeImpact           float64      // slippage per unit of order size over bar volume for the volume model, e.g. 0.1 adds 0.1% at 1% of volume
+}
+
+// PositionSnapshot is the account at the close of a bar with a signal or trade event
+type PositionSnapshot struct {
+	Date      time.Time
+	Price     float64 // close the open positions are marked to
+	Cash      float64 // available capital, including proceeds awaiting settlement
+	Equity    float64 // cash plus the market value of the open positions
+	Positions []PositionValue
+}
+
+// PositionValue is an open position marked to market
+type PositionValue struct {
+	TradeID       string
+	Quantity      int64
+	EntryPrice    float64
+	MarketValue   float64 // quantity times the marking price
+	UnrealizedPnL float64 // gain or loss against the entry price, before exit costs
+}
+
+// CashFlow is a deposit (positive Amount) or withdrawal (negative Amount) of capital
+type CashFlow struct {
+	Date   time.Time
+	Amount float64
+}
+
+// DateRange is an inclusive range of dates
+type DateRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+
+// IndicatorCache memoizes indicator series computed from the same data. Get returns the
+// series stored under key for data, calling compute on the first request for it.
+type IndicatorCache interface {
+	Get(key string, data []StockData, compute func() any) any
+}
+
+// OrderHook inspects a proposed entry before the engine executes it, for external risk
+// checks. It returns the trade to open, possibly resized, or false to cancel the order.
+type OrderHook func(proposed Trade) (Trade, bool)
+
+// FeeTier is a commission rate that applies once the cumulative traded notional
+// (entries and exits) reaches MinNotional
+type FeeTier struct {
+	MinNotional float64
+	Rate        float64 // fee per trade, e.g. 0.0005 for 0.05%
+}
+
+// TakeProfitLevel is one rung of a take-profit ladder
+type TakeProfitLevel struct {
+	Gain     float64 // gain above the entry price that triggers the exit (e.g., 0.05 for +5%)
+	Fraction float64 // fraction of the position at entry closed at this level (e.g., 0.25)
+}
+
+// HistogramBin is a single bucket of a histogram covering [Lower, Upper)
+type HistogramBin struct {
+	Lower float64
+	Upper float64
+	Count int
+}
+
+// ReturnDistribution describes the distribution of per-trade returns
+type ReturnDistribution struct {
+	Returns  []float64 // per-trade returns as percentages of the entry cost
+	Bins     []HistogramBin
+	Mean     float64
+	Median   float64
+	Skew     float64
+	Kurtosis float64 // excess kurtosis, 0 for a normal distribution
+}
+
+// BaselineResult compares a strategy with backtests of random entries that match its
+// number of trades and holding periods, or with backtests on resampled price data
+type BaselineResult struct {
+	Returns        []float64 // total return of each run, as a percentage
+	Mean           float64
+	Median         float64
+	StrategyReturn float64 // total return of the strategy being compared, as a percentage
+	Percentile     float64 // percentage of runs whose return the strategy beat
+}
+
+// StrategyAllocation is one strategy in a multi-strategy run with the fraction of the
+// total capital it may use
+type StrategyAllocation struct {
+	Name     string
+	Config   StrategyConfig
+	Fraction float64 // share of the total capital in this strategy's bucket (e.g., 0.5 for half)
+}
+
+// MultiStrategyResult combines the backtests of strategies run side by side on separate
+// capital buckets
+type MultiStrategyResult struct {
+	Results         map[string]*BacktestResult // result of each strategy against its own bucket, by name
+	InitialCapital  float64
+	FinalCapital    float64 // final capital of every bucket plus any unallocated capital
+	TotalProfitLoss float64
+	TotalReturn     float64 // percentage return on the total initial capital
+}
+
+// CapacityPoint is the outcome of the backtest at one initial capital in a capacity analysis
+type CapacityPoint struct {
+	InitialCapital  float64
+	TotalProfitLoss float64
+	TotalReturn     float64 // percentage return on this initial capital
+	TotalTrades     int64
+}
+
+// WindowResult is the backtest of one window in a rolling-window report
+type WindowResult struct {
+	Start  time.Time // first day of the window
+	End    time.Time // first day after the window
+	Result *BacktestResult
 }
 
 // BollingerBands represents Bollinger Bands values
@@ -96,8 +339,14 @@ type BollingerBands struct {
 
 // Signal represents a trading signal
 type Signal struct {
-	Date   time.Time
-	Type   string  // "BUY", "SELL", "HOLD"
-	Price  float64
-	Reason string
+	Date         time.Time
+	Type         string  // "BUY", "SELL", "HOLD"
+	Price        float64
+	Reason       string
+	StopDistance float64 // per-share distance from entry to the stop, 0 uses the strategy's percentage stop
+	Quantity     int64   // order size in shares, e.g. for rebalancing: entries and adds to an open position in the same direction take this many, and exits trim this many (0 sizes entries by risk and exits in full)
+	Tag          string  // rule that generated the signal, carried onto the trades it opens
+	OrderType    string  // "market" (default, fills at Price), "moc" (fills at the bar's close) or "limit"
+	LimitPrice   float64 // worst acceptable fill for "limit" orders: the most a buy pays or the least a sell receives
+	Strength     float64 // how strong the BUY setup is, from 0 (barely triggered) to 1
 }
@@ -0,0 +1,146 @@
+package backtesting
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"swing-trader/internal/types"
+	"time"
+)
+
+// RunRandomBaseline backtests runs sets of random entries against the strategy's result.
+// Each run makes as many trades as the strategy, reusing its holding periods in bars in a
+// shuffled order and placing them at random non-overlapping points after the warm-up, so
+// only the entry timing differs. The same seed always gives the same baseline.
+func (e *Engine) RunRandomBaseline(data []types.StockData, strategyResult *types.BacktestResult, runs int, seed int64) (*types.BaselineResult, error) {
+	if strategyResult == nil || len(strategyResult.Trades) == 0 {
+		return nil, fmt.Errorf("strategy made no trades to match")
+	}
+
+	holds := holdingPeriods(strategyResult.Trades, data)
+
+	start := e.WarmUpBars()
+	if start < 0 {
+		start = 0
+	}
+
+	// Bars outside the holding periods, shared out as gaps between the random trades
+	free := len(data) - 1 - start
+	for _, hold := range holds {
+		free -= hold
+	}
+	if free < 0 {
+		return nil, fmt.Errorf("holding periods of %d trades do not fit in %d bars", len(holds), len(data))
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	baseline := &types.BaselineResult{StrategyReturn: strategyResult.TotalReturn}
+
+	for run := 0; run < runs; run++ {
+		signals := randomSignals(data, holds, start, free, rng)
+
+		trades, err := e.executeTrades(signals, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute baseline run %d: %w", run, err)
+		}
+
+		result := e.calculateResults(trades, data)
+		baseline.Returns = append(baseline.Returns, result.TotalReturn)
+	}
+
+	summarizeBaseline(baseline)
+
+	return baseline, nil
+}
+
+// holdingPeriods returns the bars each closed trade was held, in order of its first closed
+// record. The records of a trade closed in parts, such as at a partial target or a ladder
+// level, share its ID and count as one holding period from its entry to its last exit.
+func holdingPeriods(trades []types.Trade, data []types.StockData) []int {
+	indexMap := make(map[time.Time]int)
+	for i, d := range data {
+		indexMap[d.Date] = i
+	}
+
+	var ids []string
+	periods := make(map[string][2]int)
+	for _, trade := range trades {
+		if trade.ExitDate == nil {
+			continue
+		}
+		entry, exit := indexMap[trade.EntryDate], indexMap[*trade.ExitDate]
+		period, ok := periods[trade.ID]
+		if !ok {
+			ids = append(ids, trade.ID)
+			period = [2]int{entry, exit}
+		}
+		if entry < period[0] {
+			period[0] = entry
+		}
+		if exit > period[1] {
+			period[1] = exit
+		}
+		periods[trade.ID] = period
+	}
+
+	holds := make([]int, len(ids))
+	for i, id := range ids {
+		holds[i] = periods[id][1] - periods[id][0]
+	}
+	return holds
+}
+
+// summarizeBaseline fills in the mean and median of the baseline returns and the share
+// of them the strategy return beat
+func summarizeBaseline(baseline *types.BaselineResult) {
+	if len(baseline.Returns) == 0 {
+		return
+	}
+
+	sorted := append([]float64(nil), baseline.Returns...)
+	sort.Float64s(sorted)
+
+	beaten := 0
+	for _, r := range sorted {
+		baseline.Mean += r
+		if baseline.StrategyReturn > r {
+			beaten++
+		}
+	}
+	baseline.Mean /= float64(len(sorted))
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		baseline.Median = (sorted[mid-1] + sorted[mid]) / 2
+	} else {
+		baseline.Median = sorted[mid]
+	}
+	baseline.Percentile = float64(beaten) / float64(len(sorted)) * 100
+}
+
+// randomSignals generates BUY/SELL pairs holding for the given periods in a shuffled
+// order. The free bars are split into random gaps before each trade, keeping the trades
+// in sequence without overlapping.
+func randomSignals(data []types.StockData, holds []int, start, free int, rng *rand.Rand) []types.Signal {
+	order := rng.Perm(len(holds))
+
+	offsets := make([]int, len(holds))
+	for i := range offsets {
+		offsets[i] = rng.Intn(free + 1)
+	}
+	sort.Ints(offsets)
+
+	var signals []types.Signal
+	elapsed := 0
+	for i, k := range order {
+		entry := start + offsets[i] + elapsed
+		exit := entry + holds[k]
+		elapsed += holds[k]
+
+		signals = append(signals,
+			types.Signal{Date: data[entry].Date, Type: "BUY", Price: data[entry].Close, Tag: "random"},
+			types.Signal{Date: data[exit].Date, Type: "SELL", Price: data[exit].Close})
+	}
+
+	return signals
+}
@@ -0,0 +1,131 @@
+package backtesting
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"swing-trader/internal/types"
+	"testing"
+	"time"
+)
+
+func TestRunRandomBaselineDeterministic(t *testing.T) {
+	// Repeat the signal pattern so the strategy makes several trades
+	var closes []float64
+	for i := 0; i < 5; i++ {
+		closes = append(closes, signalTestCloses...)
+	}
+	data := testData(closes...)
+
+	engine := NewEngine(signalTestConfig())
+	result, err := engine.Run(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.TotalTrades == 0 {
+		t.Fatal("Expected the strategy to make trades")
+	}
+
+	baseline, err := engine.RunRandomBaseline(data, result, 25, 42)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(baseline.Returns) != 25 {
+		t.Fatalf("Expected 25 baseline runs, got %d", len(baseline.Returns))
+	}
+	if baseline.StrategyReturn != result.TotalReturn {
+		t.Errorf("Expected the strategy return %.2f%%, got %.2f%%", result.TotalReturn, baseline.StrategyReturn)
+	}
+	if baseline.Percentile < 0 || baseline.Percentile > 100 {
+		t.Errorf("Expected a percentile between 0 and 100, got %.2f", baseline.Percentile)
+	}
+
+	// The same seed reproduces the baseline exactly
+	again, err := engine.RunRandomBaseline(data, result, 25, 42)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(baseline, again) {
+		t.Errorf("Expected identical baselines for the same seed, got %+v and %+v", baseline, again)
+	}
+
+	// A different seed places the entries elsewhere
+	other, err := engine.RunRandomBaseline(data, result, 25, 7)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reflect.DeepEqual(baseline.Returns, other.Returns) {
+		t.Error("Expected a different seed to change the baseline returns")
+	}
+}
+
+func TestHoldingPeriodsGroupPartialExits(t *testing.T) {
+	config := testConfig()
+	config.StrategyConfig.TargetR = 2.0
+	config.StrategyConfig.PartialTargetR = 1.0
+	config.StrategyConfig.PartialFraction = 0.5
+
+	// The first trade takes half off at +1R on bar 2 and the rest at +2R on bar 4, the
+	// second holds from bar 6 to bar 8
+	data := testData(100, 103, 105, 108, 110, 110, 100, 100, 100)
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+		{Date: data[6].Date, Type: "BUY", Price: 100.0},
+		{Date: data[8].Date, Type: "SELL", Price: 100.0},
+	}
+
+	trades, err := NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 3 {
+		t.Fatalf("Expected a partial and a final exit plus a second trade, got %d records", len(trades))
+	}
+
+	// The two records of the first trade are one holding period to its last exit
+	if holds := holdingPeriods(trades, data); !reflect.DeepEqual(holds, []int{4, 2}) {
+		t.Errorf("Expected holding periods [4 2], got %v", holds)
+	}
+}
+
+func TestRandomSignalsMatchHoldingPeriods(t *testing.T) {
+	closes := make([]float64, 30)
+	for i := range closes {
+		closes[i] = 100.0
+	}
+	data := testData(closes...)
+
+	holds := []int{3, 1, 5}
+	start := 4
+	free := len(data) - 1 - start - 9
+
+	signals := randomSignals(data, holds, start, free, rand.New(rand.NewSource(3)))
+	if len(signals) != 2*len(holds) {
+		t.Fatalf("Expected %d signals, got %d", 2*len(holds), len(signals))
+	}
+
+	index := make(map[time.Time]int)
+	for i, d := range data {
+		index[d.Date] = i
+	}
+
+	var got []int
+	previousExit := start
+	for i := 0; i < len(signals); i += 2 {
+		if signals[i].Type != "BUY" || signals[i+1].Type != "SELL" {
+			t.Fatalf("Expected BUY/SELL pairs, got %s/%s", signals[i].Type, signals[i+1].Type)
+		}
+
+		entry, exit := index[signals[i].Date], index[signals[i+1].Date]
+		if entry < previousExit {
+			t.Errorf("Expected trades in sequence without overlap, entry at bar %d before exit at bar %d", entry, previousExit)
+		}
+		got = append(got, exit-entry)
+		previousExit = exit
+	}
+
+	sort.Ints(got)
+	if !reflect.DeepEqual(got, []int{1, 3, 5}) {
+		t.Errorf("Expected the holding periods %v to be reused, got %v", holds, got)
+	}
+}
@@ -0,0 +1,75 @@
+package backtesting
+
+import (
+	"fmt"
+	"math/rand"
+	"swing-trader/internal/types"
+)
+
+// defaultBootstrapBlock is the number of consecutive bars resampled together when the
+// caller does not set a block size
+const defaultBootstrapBlock = 20
+
+// RunBlockBootstrap backtests the strategy on runs resampled versions of the price data
+// and compares their returns with the strategy's result. Each version keeps the dates and
+// first bar, and chains blocks of consecutive bar moves drawn at random from the original,
+// so short-range structure like volatility clusters survives while the overall path does
+// not. An edge that only exists on the real path is likely curve-fitted. The same seed
+// always gives the same bootstrap.
+func (e *Engine) RunBlockBootstrap(data []types.StockData, strategyResult *types.BacktestResult, runs, blockSize int, seed int64) (*types.BaselineResult, error) {
+	if strategyResult == nil {
+		return nil, fmt.Errorf("no strategy result to compare")
+	}
+	if blockSize <= 0 {
+		blockSize = defaultBootstrapBlock
+	}
+	if len(data) < blockSize+1 {
+		return nil, fmt.Errorf("block size %d needs at least %d bars, got %d", blockSize, blockSize+1, len(data))
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	bootstrap := &types.BaselineResult{StrategyReturn: strategyResult.TotalReturn}
+
+	for run := 0; run < runs; run++ {
+		result, err := e.Run(resampleBlocks(data, blockSize, rng))
+		if err != nil {
+			return nil, fmt.Errorf("failed to run bootstrap run %d: %w", run, err)
+		}
+		bootstrap.Returns = append(bootstrap.Returns, result.TotalReturn)
+	}
+
+	summarizeBaseline(bootstrap)
+
+	return bootstrap, nil
+}
+
+// resampleBlocks builds a price series on the dates of data from blocks of consecutive
+// bar moves drawn with replacement. Each bar's open, high, low and close are kept relative
+// to the previous close, so the rebuilt bars stay internally consistent.
+func resampleBlocks(data []types.StockData, blockSize int, rng *rand.Rand) []types.StockData {
+	resampled := make([]types.StockData, len(data))
+	resampled[0] = data[0]
+
+	// Moves are taken from bars 1 onwards, each needing the previous close
+	for i := 1; i < len(data); {
+		start := 1 + rng.Intn(len(data)-blockSize)
+
+		for j := start; j < start+blockSize && i < len(data); j++ {
+			prevClose := data[j-1].Close
+			scale := resampled[i-1].Close / prevClose
+
+			resampled[i] = types.StockData{
+				Date:          data[i].Date,
+				Open:          data[j].Open * scale,
+				High:          data[j].High * scale,
+				Low:           data[j].Low * scale,
+				Close:         data[j].Close * scale,
+				Volume:        data[j].Volume,
+				AdjustedClose: data[j].Close * scale,
+			}
+			i++
+		}
+	}
+
+	return resampled
+}
@@ -0,0 +1,90 @@
+package backtesting
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestRunBlockBootstrapDeterministic(t *testing.T) {
+	var closes []float64
+	for i := 0; i < 5; i++ {
+		closes = append(closes, signalTestCloses...)
+	}
+	data := testData(closes...)
+
+	engine := NewEngine(signalTestConfig())
+	result, err := engine.Run(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	bootstrap, err := engine.RunBlockBootstrap(data, result, 20, 10, 42)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(bootstrap.Returns) != 20 {
+		t.Fatalf("Expected 20 bootstrap runs, got %d", len(bootstrap.Returns))
+	}
+	if bootstrap.StrategyReturn != result.TotalReturn {
+		t.Errorf("Expected the strategy return %.2f%%, got %.2f%%", result.TotalReturn, bootstrap.StrategyReturn)
+	}
+	if bootstrap.Percentile < 0 || bootstrap.Percentile > 100 {
+		t.Errorf("Expected a percentile between 0 and 100, got %.2f", bootstrap.Percentile)
+	}
+
+	// The same seed reproduces the bootstrap exactly
+	again, err := engine.RunBlockBootstrap(data, result, 20, 10, 42)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(bootstrap, again) {
+		t.Errorf("Expected identical bootstraps for the same seed, got %+v and %+v", bootstrap, again)
+	}
+
+	other, err := engine.RunBlockBootstrap(data, result, 20, 10, 7)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reflect.DeepEqual(bootstrap.Returns, other.Returns) {
+		t.Error("Expected a different seed to change the bootstrap returns")
+	}
+}
+
+func TestResampleBlocksKeepsDatesAndMoves(t *testing.T) {
+	data := testData(100, 102, 99, 101, 104, 103, 107, 105, 108, 110, 109)
+
+	resampled := resampleBlocks(data, 3, rand.New(rand.NewSource(1)))
+
+	if len(resampled) != len(data) {
+		t.Fatalf("Expected %d bars, got %d", len(data), len(resampled))
+	}
+	if resampled[0] != data[0] {
+		t.Errorf("Expected the first bar unchanged, got %+v", resampled[0])
+	}
+
+	// Every resampled bar's move is one of the original moves
+	moves := make([]float64, 0, len(data)-1)
+	for i := 1; i < len(data); i++ {
+		moves = append(moves, data[i].Close/data[i-1].Close)
+	}
+
+	for i := 1; i < len(resampled); i++ {
+		if !resampled[i].Date.Equal(data[i].Date) {
+			t.Errorf("Expected the original date at index %d, got %s", i, resampled[i].Date.Format("2006-01-02"))
+		}
+
+		move := resampled[i].Close / resampled[i-1].Close
+		found := false
+		for _, m := range moves {
+			if math.Abs(m-move) < 1e-12 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected the move at index %d to come from the original series, got %.6f", i, move)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package backtesting
+
+import (
+	"fmt"
+	"swing-trader/internal/types"
+)
+
+// RunCapacityAnalysis backtests the config again at each initial capital, in the order
+// given, to show how much capital the strategy can deploy before its own market impact
+// erodes the return. Without MaxVolumeParticipation or the volume slippage model, fills
+// do not depend on order size, so the return stays about the same at every capital.
+func RunCapacityAnalysis(config types.BacktestConfig, capitals []float64, data []types.StockData) ([]types.CapacityPoint, error) {
+	if len(capitals) == 0 {
+		return nil, fmt.Errorf("no capital levels to run")
+	}
+
+	points := make([]types.CapacityPoint, 0, len(capitals))
+	for _, capital := range capitals {
+		if capital <= 0 {
+			return nil, fmt.Errorf("capital levels must be positive, got %v", capital)
+		}
+
+		run := config
+		run.InitialCapital = capital
+		run.StrategyConfig.InitialCapital = capital
+
+		result, err := NewEngine(run).Run(data)
+		if err != nil {
+			return nil, fmt.Errorf("capital %v: %w", capital, err)
+		}
+
+		points = append(points, types.CapacityPoint{
+			InitialCapital:  capital,
+			TotalProfitLoss: result.TotalProfitLoss,
+			TotalReturn:     result.TotalReturn,
+			TotalTrades:     result.TotalTrades,
+		})
+	}
+
+	return points, nil
+}
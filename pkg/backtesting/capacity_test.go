@@ -0,0 +1,52 @@
+package backtesting
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRunCapacityAnalysis(t *testing.T) {
+	// Each bar trades 1000 shares, so a 10% participation cap fills 100 shares a bar
+	data := testData(signalTestCloses...)
+	for i := range data {
+		data[i].Volume = 1000
+	}
+	capitals := []float64{10000, 100000, 1000000}
+
+	// Without the cap every order fills at once, so P&L scales with capital and the
+	// return holds, up to whole-share rounding
+	points, err := RunCapacityAnalysis(signalTestConfig(), capitals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(points) != len(capitals) {
+		t.Fatalf("Expected %d capacity points, got %d", len(capitals), len(points))
+	}
+	for i, point := range points {
+		if point.InitialCapital != capitals[i] || point.TotalTrades == 0 {
+			t.Fatalf("Expected a trading run at %.0f, got %+v", capitals[i], point)
+		}
+		if math.Abs(point.TotalReturn-points[0].TotalReturn) > 0.01 {
+			t.Errorf("Expected the %.2f%% return to hold at %.0f without a cap, got %.2f%%",
+				points[0].TotalReturn, point.InitialCapital, point.TotalReturn)
+		}
+	}
+
+	// With the cap, larger orders fill over more bars of the rally and the return falls
+	config := signalTestConfig()
+	config.MaxVolumeParticipation = 0.1
+	capped, err := RunCapacityAnalysis(config, capitals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i := 1; i < len(capped); i++ {
+		if capped[i].TotalReturn >= capped[i-1].TotalReturn {
+			t.Errorf("Expected the return to fall from %.2f%% at %.0f as capital grows, got %.2f%% at %.0f",
+				capped[i-1].TotalReturn, capped[i-1].InitialCapital, capped[i].TotalReturn, capped[i].InitialCapital)
+		}
+	}
+
+	if _, err := RunCapacityAnalysis(config, []float64{10000, 0}, data); err == nil {
+		t.Error("Expected a non-positive capital to be rejected")
+	}
+}
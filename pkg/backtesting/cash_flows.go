@@ -0,0 +1,69 @@
+package backtesting
+
+import (
+	"sort"
+	"swing-trader/internal/types"
+)
+
+// cashFlowsByBar maps the configured cash flows onto the bars they are applied on: the
+// first bar on or after each flow's date. Flows dated after the last bar are dropped.
+func (e *Engine) cashFlowsByBar(data []types.StockData) map[int]float64 {
+	flows := make(map[int]float64)
+
+	for _, flow := range e.config.CashFlows {
+		i := sort.Search(len(data), func(i int) bool { return !data[i].Date.Before(flow.Date) })
+		if i < len(data) {
+			flows[i] += flow.Amount
+		}
+	}
+
+	return flows
+}
+
+// timeWeightedReturn chains the equity curve's bar-to-bar returns with each bar's cash
+// flow taken out, so deposits and withdrawals do not count as performance. It returns the
+// return over the whole curve as a percentage.
+func timeWeightedReturn(initialCapital float64, equity []float64, flows map[int]float64) float64 {
+	growth := 1.0
+	previous := initialCapital
+
+	for i, value := range equity {
+		// Flows arrive at the start of the bar, before it trades
+		start := previous + flows[i]
+		if start > 0 {
+			growth *= value / start
+		}
+		previous = value
+	}
+
+	return (growth - 1) * 100
+}
+
+// moneyWeightedReturn approximates the return on the money actually invested using the
+// Modified Dietz method: the gain after flows over the starting capital plus each flow
+// weighted by the share of the period it was invested for. It returns a percentage.
+func moneyWeightedReturn(initialCapital, finalCapital float64, data []types.StockData, flows map[int]float64) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	start, end := data[0].Date, data[len(data)-1].Date
+	period := end.Sub(start).Hours()
+
+	gain := finalCapital - initialCapital
+	invested := initialCapital
+	for i, amount := range flows {
+		gain -= amount
+
+		weight := 1.0
+		if period > 0 {
+			weight = end.Sub(data[i].Date).Hours() / period
+		}
+		invested += amount * weight
+	}
+
+	if invested <= 0 {
+		return 0
+	}
+	return gain / invested * 100
+}
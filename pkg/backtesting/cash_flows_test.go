@@ -0,0 +1,135 @@
+package backtesting
+
+import (
+	"math"
+	"swing-trader/internal/types"
+	"testing"
+	"time"
+)
+
+func TestMonthlyDepositsFinalCapital(t *testing.T) {
+	// A year of flat prices and no trades, with $500 deposited on the 1st of each month
+	closes := make([]float64, 365)
+	for i := range closes {
+		closes[i] = 100
+	}
+	data := testData(closes...)
+
+	config := testConfig()
+	for month := time.February; month <= time.December; month++ {
+		config.CashFlows = append(config.CashFlows, types.CashFlow{
+			Date:   time.Date(2023, month, 1, 0, 0, 0, 0, time.UTC),
+			Amount: 500,
+		})
+	}
+	engine := NewEngine(config)
+
+	trades, err := engine.executeTrades(nil, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result := engine.calculateResults(trades, data)
+
+	if result.NetCashFlows != 5500 || result.FinalCapital != 15500 {
+		t.Errorf("Expected $5500 of deposits and a final capital of $15500, got $%.2f and $%.2f",
+			result.NetCashFlows, result.FinalCapital)
+	}
+
+	// Deposits are not performance
+	if math.Abs(result.TotalReturn) > 1e-9 || math.Abs(result.MoneyWeightedReturn) > 1e-9 {
+		t.Errorf("Expected no return from deposits alone, got %.4f%% time-weighted and %.4f%% money-weighted",
+			result.TotalReturn, result.MoneyWeightedReturn)
+	}
+
+	// The equity curve steps up with each deposit
+	if result.EquityCurve[0] != 10000 || result.EquityCurve[len(data)-1] != 15500 {
+		t.Errorf("Expected equity from $10000 to $15500, got $%.2f to $%.2f",
+			result.EquityCurve[0], result.EquityCurve[len(data)-1])
+	}
+}
+
+func TestTimeWeightedReturnExcludesDeposits(t *testing.T) {
+	// Flat until a 10% rise between the entry on bar 20 and the exit on bar 30
+	closes := make([]float64, 40)
+	for i := range closes {
+		closes[i] = 100
+		if i >= 30 {
+			closes[i] = 110
+		}
+	}
+	data := testData(closes...)
+	signals := []types.Signal{
+		{Date: data[20].Date, Type: "BUY", Price: 100},
+		{Date: data[30].Date, Type: "SELL", Price: 110},
+	}
+
+	run := func(config types.BacktestConfig) *types.BacktestResult {
+		engine := NewEngine(config)
+		trades, err := engine.executeTrades(signals, data)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return engine.calculateResults(trades, data)
+	}
+
+	base := run(testConfig())
+
+	// Doubling the capital before the trade sizes it twice as large, for the same return
+	config := testConfig()
+	config.CashFlows = []types.CashFlow{{Date: data[10].Date, Amount: 10000}}
+	funded := run(config)
+
+	if funded.TotalProfitLoss != 2*base.TotalProfitLoss {
+		t.Errorf("Expected the deposit to double the trade P&L from $%.2f, got $%.2f", base.TotalProfitLoss, funded.TotalProfitLoss)
+	}
+	if math.Abs(funded.TotalReturn-base.TotalReturn) > 1e-9 {
+		t.Errorf("Expected the time-weighted return to match the %.4f%% without the deposit, got %.4f%%",
+			base.TotalReturn, funded.TotalReturn)
+	}
+	if funded.FinalCapital != 20000+funded.TotalProfitLoss {
+		t.Errorf("Expected the final capital to include the deposit, got $%.2f", funded.FinalCapital)
+	}
+
+	// Modified Dietz weights the deposit by the 29 of 39 days it was invested. It arrived
+	// before the gain, so the money-weighted return beats the time-weighted one.
+	expected := funded.TotalProfitLoss / (10000 + 10000*29.0/39.0) * 100
+	if math.Abs(funded.MoneyWeightedReturn-expected) > 1e-9 || funded.MoneyWeightedReturn <= funded.TotalReturn {
+		t.Errorf("Expected a money-weighted return of %.4f%%, got %.4f%%", expected, funded.MoneyWeightedReturn)
+	}
+}
+
+func TestBenchmarkCurveInvestsCashFlows(t *testing.T) {
+	// A deposit on bar 1 buys another 100 shares at 100 alongside the initial 100
+	data := testData(100, 100, 125, 100)
+	config := testConfig()
+	config.CashFlows = []types.CashFlow{{Date: data[1].Date, Amount: 10000}}
+
+	benchmark := NewEngine(config).calculateBenchmarkCurve(data)
+	expected := []float64{10000, 20000, 25000, 20000}
+	for i := range expected {
+		if math.Abs(benchmark[i]-expected[i]) > 1e-9 {
+			t.Errorf("Expected benchmark at index %d to be %.2f, got %.2f", i, expected[i], benchmark[i])
+		}
+	}
+
+	// On flat prices, a strategy holding cash through a deposit and a withdrawal keeps pace
+	// with the equally funded benchmark
+	data = testData(100, 100, 100, 100)
+	config.CashFlows = []types.CashFlow{
+		{Date: data[1].Date, Amount: 10000},
+		{Date: data[3].Date, Amount: -15000},
+	}
+	engine := NewEngine(config)
+	trades, err := engine.executeTrades(nil, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result := engine.calculateResults(trades, data)
+
+	if result.EquityCurve[3] != 5000 || math.Abs(result.BenchmarkCurve[3]-5000) > 1e-9 {
+		t.Errorf("Expected both curves to end at $5000, got $%.2f and $%.2f", result.EquityCurve[3], result.BenchmarkCurve[3])
+	}
+	if math.Abs(result.RelativeMaxDrawdown) > 1e-9 {
+		t.Errorf("Expected no relative drawdown from cash flows, got %.4f%%", result.RelativeMaxDrawdown)
+	}
+}
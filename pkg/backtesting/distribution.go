@@ -0,0 +1,86 @@
+package backtesting
+
+import (
+	"math"
+	"sort"
+	"swing-trader/internal/types"
+)
+
+// CalculateReturnDistribution computes the per-trade return distribution, bucketed into
+// equal-width bins between the lowest and highest return, with summary statistics
+func CalculateReturnDistribution(trades []types.Trade, bins int) types.ReturnDistribution {
+	var dist types.ReturnDistribution
+
+	for _, trade := range trades {
+		cost := float64(trade.Quantity) * trade.EntryPrice
+		if cost == 0 {
+			continue
+		}
+		dist.Returns = append(dist.Returns, trade.ProfitLoss/cost*100)
+	}
+
+	n := float64(len(dist.Returns))
+	if n == 0 {
+		return dist
+	}
+
+	sorted := append([]float64(nil), dist.Returns...)
+	sort.Float64s(sorted)
+
+	// Summary statistics
+	for _, r := range sorted {
+		dist.Mean += r
+	}
+	dist.Mean /= n
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		dist.Median = (sorted[mid-1] + sorted[mid]) / 2
+	} else {
+		dist.Median = sorted[mid]
+	}
+
+	var m2, m3, m4 float64
+	for _, r := range sorted {
+		d := r - dist.Mean
+		m2 += d * d
+		m3 += d * d * d
+		m4 += d * d * d * d
+	}
+	m2 /= n
+	m3 /= n
+	m4 /= n
+
+	if m2 > 0 {
+		dist.Skew = m3 / math.Pow(m2, 1.5)
+		dist.Kurtosis = m4/(m2*m2) - 3
+	}
+
+	// Histogram
+	if bins <= 0 {
+		return dist
+	}
+
+	low, high := sorted[0], sorted[len(sorted)-1]
+	width := (high - low) / float64(bins)
+	if width == 0 {
+		width = 1
+	}
+
+	dist.Bins = make([]types.HistogramBin, bins)
+	for i := range dist.Bins {
+		dist.Bins[i].Lower = low + float64(i)*width
+		dist.Bins[i].Upper = low + float64(i+1)*width
+	}
+
+	for _, r := range sorted {
+		index := int((r - low) / width)
+		if index >= bins {
+			// The highest return belongs in the last bin
+			index = bins - 1
+		}
+		dist.Bins[index].Count++
+	}
+
+	return dist
+}
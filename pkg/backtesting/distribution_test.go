@@ -0,0 +1,57 @@
+package backtesting
+
+import (
+	"math"
+	"swing-trader/internal/types"
+	"testing"
+)
+
+func TestCalculateReturnDistribution(t *testing.T) {
+	// $100 entry cost so each P&L is also the return percentage
+	var trades []types.Trade
+	for _, pnl := range []float64{-2, 1, 1, 2, 2, 3, 15} {
+		trades = append(trades, types.Trade{Quantity: 1, EntryPrice: 100, ProfitLoss: pnl})
+	}
+
+	dist := CalculateReturnDistribution(trades, 3)
+
+	if len(dist.Returns) != len(trades) {
+		t.Fatalf("Expected %d returns, got %d", len(trades), len(dist.Returns))
+	}
+
+	expectedCounts := []int{6, 0, 1}
+	if len(dist.Bins) != len(expectedCounts) {
+		t.Fatalf("Expected %d bins, got %d", len(expectedCounts), len(dist.Bins))
+	}
+	for i, expected := range expectedCounts {
+		if dist.Bins[i].Count != expected {
+			t.Errorf("Expected %d returns in bin %d, got %d", expected, i, dist.Bins[i].Count)
+		}
+	}
+	if dist.Bins[0].Lower != -2 || dist.Bins[2].Upper != 15 {
+		t.Errorf("Expected bins to span -2 to 15, got %.2f to %.2f", dist.Bins[0].Lower, dist.Bins[2].Upper)
+	}
+
+	if math.Abs(dist.Mean-22.0/7) > 1e-9 {
+		t.Errorf("Expected mean %.4f, got %.4f", 22.0/7, dist.Mean)
+	}
+	if dist.Median != 2 {
+		t.Errorf("Expected median 2, got %.4f", dist.Median)
+	}
+
+	// One large winner gives a long right tail
+	if dist.Skew <= 0 {
+		t.Errorf("Expected positive skew, got %.4f", dist.Skew)
+	}
+	if dist.Kurtosis <= 0 {
+		t.Errorf("Expected fat tails (positive excess kurtosis), got %.4f", dist.Kurtosis)
+	}
+
+	// Mirroring the returns flips the skew
+	for i := range trades {
+		trades[i].ProfitLoss = -trades[i].ProfitLoss
+	}
+	if mirrored := CalculateReturnDistribution(trades, 3); mirrored.Skew >= 0 {
+		t.Errorf("Expected negative skew for mirrored returns, got %.4f", mirrored.Skew)
+	}
+}
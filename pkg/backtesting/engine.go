@@ -2,23 +2,97 @@ package backtesting
 
 import (
 	"fmt"
+	"log/slog"
 	"math"
+	"reflect"
 	"swing-trader/internal/types"
 	"swing-trader/pkg/strategy"
 	"time"
 )
 
+// tradingDaysPerYear is used to annualize per-bar statistics of daily data on a business
+// calendar, and calendarDaysPerYear for markets that trade every day, like crypto
+const (
+	tradingDaysPerYear  = 252
+	calendarDaysPerYear = 365
+)
+
+// defaultMinAnnualizeDays is the shortest backtest span, in calendar days, whose return
+// is annualized when the config does not set one
+const defaultMinAnnualizeDays = 30
+
+// defaultSpreadFactor is the share of a bar's high-low range taken as its bid-ask spread
+// by the volume slippage model when the config does not set one
+const defaultSpreadFactor = 0.1
+
 // Engine handles the backtesting execution
 type Engine struct {
 	config   types.BacktestConfig
-	strategy *strategy.BBRSIStrategy
+	strategy strategy.Strategy
+	logger   *slog.Logger
+
+	// tradedNotional is the value of all fills so far in the run, used to pick the fee tier
+	tradedNotional float64
+
+	// costsPaid is the fees and slippage of all fills so far in the run, and entryFees the
+	// part paid on entries, which trade P&L leaves out. Both feed the cost drag.
+	costsPaid float64
+	entryFees float64
+
+	// pendingSettlements holds sale proceeds that cannot fund new buys yet, and
+	// unsettledCash is their total
+	pendingSettlements []settlement
+	unsettledCash      float64
+
+	// dayPnL is the realized P&L of trades closed on pnlDay, used for the daily loss limit
+	pnlDay time.Time
+	dayPnL float64
+
+	// bars holds the run's data by date, used to price slippage off the fill bar, and
+	// barIndex each bar's position, used to count the bars a trade has been held
+	bars     map[time.Time]types.StockData
+	barIndex map[time.Time]int
+
+	// stoppedOut is set when a stop closes a position and cleared by a fresh BUY condition,
+	// used to hold off re-entry when FreshEntryAfterStop is enabled
+	stoppedOut bool
+
+	// equityHistory is the mark-to-market equity at the close of each bar so far, used by
+	// the equity-curve filter
+	equityHistory []float64
+
+	// positionLog holds the open positions and cash at each signal or trade event when
+	// PositionLog is enabled
+	positionLog []types.PositionSnapshot
 }
 
-// NewEngine creates a new backtesting engine
+// settlement is the proceeds of a sale awaiting settlement
+type settlement struct {
+	tradeDate time.Time
+	amount    float64
+}
+
+// NewEngine creates a new backtesting engine running the strategy named by the config. The
+// strategy logs to the engine's logger unless its config sets its own.
 func NewEngine(config types.BacktestConfig) *Engine {
+	strategyConfig := config.StrategyConfig
+	if strategyConfig.Logger == nil {
+		strategyConfig.Logger = config.Logger
+	}
+	return NewEngineWithStrategy(config, strategy.NewStrategy(strategyConfig))
+}
+
+// NewEngineWithStrategy creates a new backtesting engine running the given strategy
+func NewEngineWithStrategy(config types.BacktestConfig, s strategy.Strategy) *Engine {
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	return &Engine{
 		config:   config,
-		strategy: strategy.NewBBRSIStrategy(config.StrategyConfig),
+		strategy: s,
+		logger:   logger,
 	}
 }
 
@@ -28,6 +102,23 @@ func (e *Engine) Run(data []types.StockData) (*types.BacktestResult, error) {
 		return nil, fmt.Errorf("no data provided for backtesting")
 	}
 
+	if required := e.minDataPoints(); len(data) < required {
+		return nil, fmt.Errorf("insufficient data: got %d bars, need at least %d", len(data), required)
+	}
+
+	if err := validateLadder(e.config.StrategyConfig.TakeProfitLadder); err != nil {
+		return nil, fmt.Errorf("invalid take-profit ladder: %w", err)
+	}
+
+	// Bars are looked up by date, so a repeated date would silently hide one of its bars
+	seen := make(map[time.Time]int, len(data))
+	for i, d := range data {
+		if first, ok := seen[d.Date]; ok {
+			return nil, fmt.Errorf("duplicate bars dated %s at indexes %d and %d, resolve them before backtesting", d.Date.Format("2006-01-02"), first, i)
+		}
+		seen[d.Date] = i
+	}
+
 	// Generate trading signals
 	signals := e.strategy.GenerateSignals(data)
 	
@@ -37,90 +128,327 @@ func (e *Engine) Run(data []types.StockData) (*types.BacktestResult, error) {
 		return nil, fmt.Errorf("failed to execute trades: %w", err)
 	}
 
+	e.calculateExcursions(trades, data)
+
 	// Calculate comprehensive results
 	result := e.calculateResults(trades, data)
+	if err := validateResult(result); err != nil {
+		return nil, fmt.Errorf("invalid backtest result: %w", err)
+	}
 	
 	return result, nil
 }
 
+// WarmUpBars returns the number of leading bars where the strategy's indicators are
+// still warming up, so no signal can fire
+func (e *Engine) WarmUpBars() int {
+	return e.strategyMinDataPoints() - 1
+}
+
+// strategyMinDataPoints returns the number of bars the strategy needs before its first
+// signal, one for a strategy without a warm-up
+func (e *Engine) strategyMinDataPoints() int {
+	if s, ok := e.strategy.(strategy.WarmUpStrategy); ok {
+		return s.MinDataPoints()
+	}
+	return 1
+}
+
+// minDataPoints returns the minimum number of bars required to run the backtest
+func (e *Engine) minDataPoints() int {
+	required := e.strategyMinDataPoints()
+	if e.config.MinDataPoints > required {
+		required = e.config.MinDataPoints
+	}
+	return required
+}
+
 // executeTrades processes signals and simulates trade execution
 func (e *Engine) executeTrades(signals []types.Signal, data []types.StockData) ([]types.Trade, error) {
 	var trades []types.Trade
 	var openTrades []types.Trade
 	availableCapital := e.config.InitialCapital
 	tradeID := 1
+	e.tradedNotional = 0
+	e.costsPaid = 0
+	e.entryFees = 0
+	e.pendingSettlements = nil
+	e.unsettledCash = 0
+	e.pnlDay = time.Time{}
+	e.dayPnL = 0
+	e.stoppedOut = false
+	e.equityHistory = nil
+	e.positionLog = nil
+
+	// Original entry of each open trade, used to measure averaging-down levels
+	firstEntries := make(map[string]types.Trade)
 
 	// Create a map for quick data lookup by date
 	dataMap := make(map[time.Time]types.StockData)
-	for _, d := range data {
+	indexMap := make(map[time.Time]int)
+	for i, d := range data {
 		dataMap[d.Date] = d
+		indexMap[d.Date] = i
+	}
+	e.bars = dataMap
+	e.barIndex = indexMap
+
+	// Bar of the latest BUY signal, and of the latest SELL with shorts allowed, to tell a
+	// persisting entry condition from a fresh one
+	lastBuyIndex := -2
+	lastSellIndex := -2
+
+	// Deposits and withdrawals by the bar they land on
+	cashFlows := e.cashFlowsByBar(data)
+
+	// Bars excluded at either end take no part in execution, and the final close happens
+	// on the last bar before the trailing region
+	firstBar := e.config.SkipLeading
+	lastBar := len(data) - 1 - e.config.SkipTrailing
+	if firstBar > lastBar {
+		return trades, nil
 	}
 
+	// Signals by the bar they execute on, in order
+	barSignals := make(map[int][]types.Signal)
 	for _, signal := range signals {
-		switch signal.Type {
-		case "BUY":
-			if len(openTrades) == 0 { // Only open one position at a time for simplicity
-				shares := e.strategy.CalculatePositionSize(availableCapital, signal.Price, e.config.RiskManagementConfig)
-				if shares > 0 {
-					// Apply slippage and fees
-					entryPrice := signal.Price * (1 + e.config.Slippage)
-					tradeFee := float64(shares) * entryPrice * e.config.TradeFee
-					totalCost := float64(shares)*entryPrice + tradeFee
-
-					if totalCost <= availableCapital {
+		index := indexMap[signal.Date]
+		if index < firstBar || index > lastBar {
+			continue
+		}
+		barSignals[index] = append(barSignals[index], signal)
+	}
+
+	// Walk every bar so stops and targets are checked against each bar's range, not only
+	// on bars with a signal
+	for index := 0; index <= lastBar; index++ {
+		bar := data[index]
+		availableCapital += cashFlows[index]
+		if index < firstBar {
+			e.recordEquity(availableCapital)
+			continue
+		}
+		e.settleCash(index, indexMap)
+		open := len(openTrades)
+
+		// Stops and targets trade during the bar, ahead of any signal executing at the close
+		openTrades = e.checkStopLossAndTakeProfit(openTrades, index, bar, &trades, &availableCapital)
+		openTrades = e.liquidateStale(openTrades, bar.Date, bar.Close, &trades, &availableCapital)
+		periodEnd := e.periodEndReason(data, index) != ""
+
+		for _, signal := range barSignals[index] {
+			blackout := e.inBlackout(signal.Date)
+			if blackout && e.config.FlattenInBlackout {
+				for i := range openTrades {
+					availableCapital += e.closeTrade(&openTrades[i], signal.Date, signal.Price, "blackout")
+					trades = append(trades, openTrades[i])
+				}
+				openTrades = nil
+			}
+
+			// Work out where the order fills, if the bar reaches a limit order at all
+			fillPrice, filled := e.fillPrice(signal, dataMap[signal.Date])
+
+			// A BUY enters long and, with AllowShorts, a SELL enters short, each only when flat or
+			// adding to positions in the same direction
+			direction := ""
+
+			switch signal.Type {
+			case "BUY":
+				// A BUY on the bar after the previous one continues the same entry condition
+				persisting := index == lastBuyIndex+1
+				lastBuyIndex = index
+				if !persisting {
+					e.stoppedOut = false
+				}
+
+				if !filled {
+					e.logger.Debug("limit order not filled", "date", signal.Date.Format("2006-01-02"), "type", signal.Type, "limit", signal.LimitPrice)
+					break
+				}
+
+				// Cover open shorts rather than reversing into a long on the same signal
+				if len(openTrades) > 0 && openTrades[0].Direction == "short" {
+					openTrades = e.exitPositions(openTrades, signal, fillPrice, &trades, &availableCapital)
+					break
+				}
+				direction = "long"
+
+			case "SELL":
+				if e.config.AllowShorts {
+					// As for BUY, a SELL on the bar after the previous one continues the short entry condition
+					persisting := index == lastSellIndex+1
+					lastSellIndex = index
+					if !persisting {
+						e.stoppedOut = false
+					}
+				}
+
+				if !filled {
+					e.logger.Debug("limit order not filled", "date", signal.Date.Format("2006-01-02"), "type", signal.Type, "limit", signal.LimitPrice)
+					break
+				}
+
+				if e.config.AllowShorts && (len(openTrades) == 0 || openTrades[0].Direction == "short") {
+					direction = "short"
+					break
+				}
+
+				// Close open long positions on sell signal, holding any shorts
+				if len(openTrades) > 0 && openTrades[0].Direction != "short" {
+					openTrades = e.exitPositions(openTrades, signal, fillPrice, &trades, &availableCapital)
+				}
+			}
+
+			if direction != "" {
+				if blackout {
+					e.logger.Debug("entry suppressed in blackout", "date", signal.Date.Format("2006-01-02"))
+				} else if periodEnd {
+					e.logger.Debug("entry suppressed at period end", "date", signal.Date.Format("2006-01-02"))
+				} else if e.equityBelowAverage(e.currentEquity(availableCapital, openTrades, signal.Price)) {
+					e.logger.Debug("entry suppressed by equity curve filter", "date", signal.Date.Format("2006-01-02"))
+				} else if e.dailyLossReached(signal.Date) {
+					e.logger.Debug("entry suppressed by daily loss limit", "date", signal.Date.Format("2006-01-02"), "pnl", e.dayPnL)
+				} else if e.stoppedOut && e.config.FreshEntryAfterStop {
+					e.logger.Debug("re-entry suppressed until a fresh entry condition", "date", signal.Date.Format("2006-01-02"))
+				} else if signal.Quantity > 0 && len(openTrades) > 0 {
+					// An order for a set quantity adds to the open position rather than opening another
+					e.addToPosition(&openTrades[len(openTrades)-1], signal, fillPrice, &availableCapital)
+				} else if len(openTrades) < e.maxOpenPositions() { // Add positions up to MaxOpenPositions while capital allows
+					// Slippage works against the order: a long buys higher and a short sells lower
+					sign := 1.0
+					if direction == "short" {
+						sign = -1
+					}
+
+					// Apply slippage, then size against the actual stop distance using settled cash only.
+					// The strategy prices stops for longs, so a short's stop is mirrored above the entry.
+					settledCash := availableCapital - e.unsettledCash
+					entryPrice := fillPrice * (1 + sign*e.config.Slippage)
+					stopLoss := e.stopLossPrice(signal, entryPrice)
+					shares := e.sizePosition(settledCash, entryPrice, stopLoss, signal.Strength, data, indexMap[signal.Date])
+					if signal.Quantity > 0 {
+						// The signal sets the order size, replacing the risk sizing
+						shares = signal.Quantity
+					}
+					if shares > 0 {
+						// Record the risk sizing aimed for, and whether a cap or floor overrode it
+						intendedRisk := settledCash * e.riskFraction(signal.Strength, data, indexMap[signal.Date])
+						clamped := signal.Quantity == 0 && shares != int64(intendedRisk/(entryPrice-stopLoss))
+						equityAtEntry := e.currentEquity(availableCapital, openTrades, fillPrice)
+
+						// Reprice the fill now the order size is known
+						entryPrice = fillPrice * (1 + sign*e.slippage(signal.Date, shares))
+
+						if e.config.StrategyConfig.StopMode == "equity" {
+							// Place the stop so a stop-out loses the configured share of equity
+							equity := e.currentEquity(availableCapital, openTrades, fillPrice)
+							stopLoss = e.equityStopPrice(equity, entryPrice, shares)
+						}
+						if direction == "short" {
+							stopLoss = 2*entryPrice - stopLoss
+						}
+
 						trade := types.Trade{
 							ID:         fmt.Sprintf("T%d", tradeID),
 							EntryDate:  signal.Date,
 							EntryPrice: entryPrice,
 							Quantity:   shares,
 							Status:     "open",
-							StopLoss:   e.strategy.GetStopLossPrice(entryPrice),
-							TakeProfit: e.strategy.GetTakeProfitPrice(entryPrice),
+							Direction:  direction,
+							StopLoss:   stopLoss,
+							LadderBase: shares,
+							EntryBar:   index,
+							FillBar:    index,
+							Tag:        signal.Tag,
+
+							CapitalAtEntry: equityAtEntry,
+							IntendedRisk:   intendedRisk,
+							ActualRisk:     math.Abs(entryPrice-stopLoss) * float64(shares),
+							SizingClamped:  clamped,
+						}
+						trade.TakeProfit, trade.PartialTarget = e.targetPrices(trade)
+
+						// Let an external check veto or resize the order before it executes
+						approved := true
+						if e.config.OrderHook != nil {
+							proposed := trade
+							trade, approved = e.config.OrderHook(trade)
+							trade.LadderBase = trade.Quantity
+							trade.ActualRisk = math.Abs(trade.EntryPrice-trade.StopLoss) * float64(trade.Quantity)
+
+							// A resize overrides the risk sizing like a cap or floor, and a moved entry
+							// or stop moves the targets unless the hook set them itself
+							if trade.Quantity != proposed.Quantity {
+								trade.SizingClamped = true
+							}
+							moved := trade.EntryPrice != proposed.EntryPrice || trade.StopLoss != proposed.StopLoss
+							if moved && trade.TakeProfit == proposed.TakeProfit && trade.PartialTarget == proposed.PartialTarget {
+								trade.TakeProfit, trade.PartialTarget = e.targetPrices(trade)
+							}
+						}
+
+						// Spread an order too large for the bar's volume over the following bars
+						referencePrice := fillPrice
+						if approved && trade.Quantity > 0 && e.config.MaxVolumeParticipation > 0 {
+							referencePrice = e.fillOverBars(&trade, data, index, lastBar, fillPrice)
+						}
+
+						// Apply fees. A short sets aside its entry value as collateral, like the cost of a long.
+						tradeFee := float64(trade.Quantity) * trade.EntryPrice * e.feeRate(signal.OrderType)
+						totalCost := e.roundMoney(float64(trade.Quantity)*trade.EntryPrice + tradeFee)
+
+						if !approved || (trade.Quantity <= 0 && e.config.MaxVolumeParticipation <= 0) {
+							e.logger.Debug("order vetoed by hook", "date", signal.Date.Format("2006-01-02"), "quantity", shares)
+						} else if trade.Quantity <= 0 {
+							e.logger.Debug("order not filled within volume participation", "date", signal.Date.Format("2006-01-02"), "quantity", shares)
+						} else if totalCost <= settledCash {
+							openTrades = append(openTrades, trade)
+							firstEntries[trade.ID] = trade
+							availableCapital -= totalCost
+							e.tradedNotional += float64(trade.Quantity) * trade.EntryPrice
+							e.costsPaid += float64(trade.Quantity)*math.Abs(trade.EntryPrice-referencePrice) + tradeFee
+							e.entryFees += tradeFee
+							tradeID++
+
+							e.logger.Debug("trade opened",
+								"id", trade.ID,
+								"date", trade.EntryDate.Format("2006-01-02"),
+								"direction", trade.Direction,
+								"price", trade.EntryPrice,
+								"quantity", trade.Quantity,
+								"stop_loss", trade.StopLoss,
+								"take_profit", trade.TakeProfit,
+								"intended_risk", trade.IntendedRisk,
+								"actual_risk", trade.ActualRisk,
+								"sizing_clamped", trade.SizingClamped,
+								"fill_bars", trade.FillBar-trade.EntryBar+1)
 						}
-						openTrades = append(openTrades, trade)
-						availableCapital -= totalCost
-						tradeID++
 					}
 				}
 			}
 
-		case "SELL":
-			// Close all open positions on sell signal
-			for i := range openTrades {
-				exitPrice := signal.Price * (1 - e.config.Slippage)
-				tradeFee := float64(openTrades[i].Quantity) * exitPrice * e.config.TradeFee
-				proceeds := float64(openTrades[i].Quantity)*exitPrice - tradeFee
-				
-				openTrades[i].ExitDate = &signal.Date
-				openTrades[i].ExitPrice = &exitPrice
-				openTrades[i].Status = "closed"
-				openTrades[i].ProfitLoss = proceeds - (float64(openTrades[i].Quantity) * openTrades[i].EntryPrice)
-				
-				availableCapital += proceeds
-				trades = append(trades, openTrades[i])
-			}
-			openTrades = nil
+			// Add to losing positions, their stops re-based for the next bar
+			e.averageDown(openTrades, signal, firstEntries, &availableCapital)
 		}
 
-		// Check stop loss and take profit for open trades
-		openTrades = e.checkStopLossAndTakeProfit(openTrades, signal, &trades, &availableCapital)
+		if periodEnd {
+			openTrades = e.flattenAtPeriodEnd(openTrades, data, index, &trades, &availableCapital)
+		}
+
+		e.recordEquity(e.currentEquity(availableCapital, openTrades, bar.Close))
+		if len(barSignals[index]) > 0 || len(openTrades) != open {
+			e.logPositions(bar, openTrades, availableCapital)
+		}
 	}
 
 	// Close any remaining open trades at the end
-	if len(openTrades) > 0 && len(data) > 0 {
-		lastPrice := data[len(data)-1].Close
-		lastDate := data[len(data)-1].Date
+	if len(openTrades) > 0 {
+		lastPrice := data[lastBar].Close
+		lastDate := data[lastBar].Date
 		
 		for i := range openTrades {
-			exitPrice := lastPrice * (1 - e.config.Slippage)
-			tradeFee := float64(openTrades[i].Quantity) * exitPrice * e.config.TradeFee
-			proceeds := float64(openTrades[i].Quantity)*exitPrice - tradeFee
-			
-			openTrades[i].ExitDate = &lastDate
-			openTrades[i].ExitPrice = &exitPrice
-			openTrades[i].Status = "closed"
-			openTrades[i].ProfitLoss = proceeds - (float64(openTrades[i].Quantity) * openTrades[i].EntryPrice)
-			
+			e.closeTrade(&openTrades[i], lastDate, lastPrice, "end_of_data")
 			trades = append(trades, openTrades[i])
 		}
 	}
@@ -128,107 +456,1411 @@ func (e *Engine) executeTrades(signals []types.Signal, data []types.StockData) (
 	return trades, nil
 }
 
-// checkStopLossAndTakeProfit checks if any open trades should be closed due to stop loss or take profit
-func (e *Engine) checkStopLossAndTakeProfit(openTrades []types.Trade, signal types.Signal, trades *[]types.Trade, availableCapital *float64) []types.Trade {
-	var remainingTrades []types.Trade
+// maxOpenPositions returns how many positions can be open at once (0 uses 1)
+func (e *Engine) maxOpenPositions() int {
+	if e.config.MaxOpenPositions <= 0 {
+		return 1
+	}
+	return e.config.MaxOpenPositions
+}
 
-	for _, trade := range openTrades {
-		closed := false
-		
-		// Check stop loss
-		if signal.Price <= trade.StopLoss {
-			exitPrice := signal.Price * (1 - e.config.Slippage)
-			tradeFee := float64(trade.Quantity) * exitPrice * e.config.TradeFee
-			proceeds := float64(trade.Quantity)*exitPrice - tradeFee
-			
-			trade.ExitDate = &signal.Date
-			trade.ExitPrice = &exitPrice
-			trade.Status = "closed"
-			trade.ProfitLoss = proceeds - (float64(trade.Quantity) * trade.EntryPrice)
-			
-			*availableCapital += proceeds
-			*trades = append(*trades, trade)
-			closed = true
-		} else if signal.Price >= trade.TakeProfit {
-			// Check take profit
-			exitPrice := signal.Price * (1 - e.config.Slippage)
-			tradeFee := float64(trade.Quantity) * exitPrice * e.config.TradeFee
-			proceeds := float64(trade.Quantity)*exitPrice - tradeFee
-			
-			trade.ExitDate = &signal.Date
-			trade.ExitPrice = &exitPrice
-			trade.Status = "closed"
-			trade.ProfitLoss = proceeds - (float64(trade.Quantity) * trade.EntryPrice)
-			
-			*availableCapital += proceeds
-			*trades = append(*trades, trade)
-			closed = true
+// periodEndReason returns "week_end" or "month_end" when the bar at index is the last
+// before a configured boundary, judged by the date of the next bar, and "" otherwise.
+// The final bar has no next bar and is left to the end-of-data close.
+func (e *Engine) periodEndReason(data []types.StockData, index int) string {
+	if index+1 >= len(data) {
+		return ""
+	}
+
+	current, next := data[index].Date, data[index+1].Date
+	if e.config.FlatAtMonthEnd && (current.Month() != next.Month() || current.Year() != next.Year()) {
+		return "month_end"
+	}
+
+	if e.config.FlatAtWeekEnd {
+		currentYear, currentWeek := current.ISOWeek()
+		nextYear, nextWeek := next.ISOWeek()
+		if currentYear != nextYear || currentWeek != nextWeek {
+			return "week_end"
 		}
+	}
 
-		if !closed {
+	return ""
+}
+
+// flattenAtPeriodEnd closes every open trade at the bar's close when the bar at index
+// ends a week or month the config flattens over, returning the trades still open
+func (e *Engine) flattenAtPeriodEnd(openTrades []types.Trade, data []types.StockData, index int, trades *[]types.Trade, availableCapital *float64) []types.Trade {
+	if len(openTrades) == 0 {
+		return openTrades
+	}
+
+	reason := e.periodEndReason(data, index)
+	if reason == "" {
+		return openTrades
+	}
+
+	for i := range openTrades {
+		*availableCapital += e.closeTrade(&openTrades[i], data[index].Date, data[index].Close, reason)
+		*trades = append(*trades, openTrades[i])
+	}
+	return nil
+}
+
+// liquidateStale force-closes, with a warning, every open trade held for MaxPositionAgeDays
+// calendar days or more by date. It is a safety cap against positions a broken exit
+// configuration would never close, not an exit rule.
+func (e *Engine) liquidateStale(openTrades []types.Trade, date time.Time, price float64, trades *[]types.Trade, availableCapital *float64) []types.Trade {
+	maxAge := e.config.MaxPositionAgeDays
+	if maxAge <= 0 {
+		return openTrades
+	}
+
+	var remainingTrades []types.Trade
+	for _, trade := range openTrades {
+		age := int(date.Sub(trade.EntryDate).Hours() / 24)
+		if age < maxAge {
 			remainingTrades = append(remainingTrades, trade)
+			continue
 		}
+
+		e.logger.Warn("position force-closed at maximum age",
+			"id", trade.ID,
+			"entry_date", trade.EntryDate.Format("2006-01-02"),
+			"date", date.Format("2006-01-02"),
+			"age_days", age,
+			"max_age_days", maxAge)
+		*availableCapital += e.closeTrade(&trade, date, price, "max_age")
+		*trades = append(*trades, trade)
 	}
 
 	return remainingTrades
 }
 
-// calculateResults computes comprehensive backtest results
-func (e *Engine) calculateResults(trades []types.Trade, data []types.StockData) *types.BacktestResult {
-	result := &types.BacktestResult{
-		Trades:         trades,
-		InitialCapital: e.config.InitialCapital,
-		StartDate:      data[0].Date,
-		EndDate:        data[len(data)-1].Date,
+// fillPrice returns the price a signal's order fills at on its bar and whether it fills.
+// Market orders fill at the signal price and market-on-close orders at the bar's close.
+// A limit buy fills when the low reaches the limit, at the open if the bar opens below it;
+// a limit sell fills when the high reaches the limit, at the open if the bar opens above it.
+func (e *Engine) fillPrice(signal types.Signal, bar types.StockData) (float64, bool) {
+	switch signal.OrderType {
+	case "moc":
+		if bar.Close > 0 {
+			return bar.Close, true
+		}
+		return signal.Price, true
+
+	case "limit":
+		if signal.Type == "BUY" {
+			if bar.Low > signal.LimitPrice {
+				return 0, false
+			}
+			if bar.Open > 0 && bar.Open < signal.LimitPrice {
+				return bar.Open, true
+			}
+			return signal.LimitPrice, true
+		}
+
+		if bar.High < signal.LimitPrice {
+			return 0, false
+		}
+		if bar.Open > signal.LimitPrice {
+			return bar.Open, true
+		}
+		return signal.LimitPrice, true
 	}
 
-	// Calculate basic metrics
-	var totalPL float64
-	var winningTrades, losingTrades int64
-	var totalWinAmount, totalLossAmount float64
+	return signal.Price, true
+}
 
-	for _, trade := range trades {
-		totalPL += trade.ProfitLoss
-		if trade.ProfitLoss > 0 {
-			winningTrades++
-			totalWinAmount += trade.ProfitLoss
-		} else if trade.ProfitLoss < 0 {
-			losingTrades++
-			totalLossAmount += math.Abs(trade.ProfitLoss)
+// settleCash releases the proceeds of sales made at least SettlementDays bars before
+// the bar at index, making them available for new buys
+func (e *Engine) settleCash(index int, indexMap map[time.Time]int) {
+	var pending []settlement
+	for _, s := range e.pendingSettlements {
+		if index-indexMap[s.tradeDate] >= e.config.SettlementDays {
+			e.unsettledCash -= s.amount
+		} else {
+			pending = append(pending, s)
 		}
 	}
+	e.pendingSettlements = pending
+}
 
-	result.TotalTrades = int64(len(trades))
-	result.WinningTrades = winningTrades
-	result.LosingTrades = losingTrades
-	result.TotalProfitLoss = totalPL
-	result.FinalCapital = e.config.InitialCapital + totalPL
+// dailyLossReached reports whether the trades closed on date's calendar day have realized
+// losses of at least MaxDailyLoss of the initial capital, halting entries until the next day
+func (e *Engine) dailyLossReached(date time.Time) bool {
+	maxLoss := e.config.RiskManagementConfig.MaxDailyLoss
+	if maxLoss <= 0 || !sameDay(date, e.pnlDay) {
+		return false
+	}
+	return -e.dayPnL >= maxLoss*e.config.InitialCapital
+}
 
-	if result.TotalTrades > 0 {
-		result.WinRate = float64(winningTrades) / float64(result.TotalTrades) * 100
+// logPositions records the open positions marked to the bar's close, with the cash and
+// equity, when PositionLog is enabled
+func (e *Engine) logPositions(bar types.StockData, openTrades []types.Trade, cash float64) {
+	if !e.config.PositionLog {
+		return
 	}
 
-	if winningTrades > 0 {
-		result.AverageWin = totalWinAmount / float64(winningTrades)
+	snapshot := types.PositionSnapshot{
+		Date:   bar.Date,
+		Price:  bar.Close,
+		Cash:   cash,
+		Equity: cash,
+	}
+	for _, trade := range openTrades {
+		position := types.PositionValue{
+			TradeID:       trade.ID,
+			Quantity:      trade.Quantity,
+			EntryPrice:    trade.EntryPrice,
+			MarketValue:   positionValue(trade, bar.Close),
+			UnrealizedPnL: positionValue(trade, bar.Close) - float64(trade.Quantity)*trade.EntryPrice,
+		}
+		snapshot.Positions = append(snapshot.Positions, position)
+		snapshot.Equity += position.MarketValue
 	}
 
-	if losingTrades > 0 {
-		result.AverageLoss = totalLossAmount / float64(losingTrades)
+	e.positionLog = append(e.positionLog, snapshot)
+	e.logger.Debug("positions marked",
+		"date", bar.Date.Format("2006-01-02"),
+		"price", bar.Close,
+		"positions", len(snapshot.Positions),
+		"cash", snapshot.Cash,
+		"equity", snapshot.Equity)
+}
+
+// recordEquity appends a bar's closing equity to the history used by the equity-curve filter
+func (e *Engine) recordEquity(equity float64) {
+	if e.config.EquityFilterPeriod > 0 {
+		e.equityHistory = append(e.equityHistory, equity)
 	}
+}
 
-	// Calculate total return
-	result.TotalReturn = (result.FinalCapital - result.InitialCapital) / result.InitialCapital * 100
+// equityBelowAverage reports whether equity is below the moving average of the equity at
+// the close of the last EquityFilterPeriod bars. New entries are suspended while it is,
+// until equity recovers to the average.
+func (e *Engine) equityBelowAverage(equity float64) bool {
+	period := e.config.EquityFilterPeriod
+	if period <= 0 || len(e.equityHistory) < period {
+		return false
+	}
 
-	// Calculate annualized return
-	years := result.EndDate.Sub(result.StartDate).Hours() / (24 * 365.25)
-	if years > 0 && result.FinalCapital > 0 && result.InitialCapital > 0 {
-		result.AnnualizedReturn = (math.Pow(result.FinalCapital/result.InitialCapital, 1/years) - 1) * 100
+	var sum float64
+	for _, value := range e.equityHistory[len(e.equityHistory)-period:] {
+		sum += value
 	}
+	average := sum / float64(period)
+
+	// Tolerate rounding in the average of a flat curve
+	return average-equity > 1e-9*average
+}
 
-	// Calculate max drawdown (simplified)
-	result.MaxDrawdown = e.calculateMaxDrawdown(trades)
+// sameDay reports whether a and b fall on the same calendar date
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
 
-	return result
+// inBlackout reports whether new entries are suppressed on the given date
+func (e *Engine) inBlackout(date time.Time) bool {
+	for _, blackout := range e.config.Blackouts {
+		if !date.Before(blackout.Start) && !date.After(blackout.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// stopLossPrice returns the stop price for an entry, using the signal's stop distance
+// when it carries one and the strategy's percentage stop otherwise
+func (e *Engine) stopLossPrice(signal types.Signal, entryPrice float64) float64 {
+	if signal.StopDistance > 0 {
+		return entryPrice - signal.StopDistance
+	}
+	return e.strategy.GetStopLossPrice(entryPrice)
+}
+
+// sizePosition calculates the shares to buy at the given bar. With strength-scaled sizing
+// the risked fraction runs from the minimum to the maximum fraction as the signal strength
+// goes from 0 to 1. In "vol_target" sizing mode the risked fraction is then scaled by the
+// target over the realized volatility, so positions shrink when the stock is volatile and
+// grow when it is calm.
+func (e *Engine) sizePosition(availableCapital, entryPrice, stopLoss, strength float64, data []types.StockData, index int) int64 {
+	riskConfig := e.config.RiskManagementConfig
+	riskConfig.PositionSize = e.riskFraction(strength, data, index)
+
+	return e.strategy.CalculatePositionSize(availableCapital, entryPrice, stopLoss, riskConfig)
+}
+
+// riskFraction returns the fraction of capital to risk on an entry at the given bar after
+// strength scaling and volatility targeting
+func (e *Engine) riskFraction(strength float64, data []types.StockData, index int) float64 {
+	riskConfig := e.config.RiskManagementConfig
+	fraction := riskConfig.PositionSize
+
+	if riskConfig.StrengthScaledSizing {
+		// Interpolate the risk fraction between the bounds by the signal's strength
+		strength = math.Max(0, math.Min(1, strength))
+		fraction = riskConfig.MinStrengthFraction + (riskConfig.MaxStrengthFraction-riskConfig.MinStrengthFraction)*strength
+	}
+
+	if riskConfig.SizingMode == "vol_target" {
+		realized := realizedVolatility(data, index, riskConfig.VolatilityLookback, e.barsPerYear(data))
+		if realized > 0 {
+			fraction *= riskConfig.TargetVolatility / realized
+		}
+	}
+
+	return fraction
+}
+
+// realizedVolatility calculates the annualized standard deviation of close-to-close
+// returns over the lookback bars ending at index, or 0 if there is not enough history
+func realizedVolatility(data []types.StockData, index, lookback int, barsPerYear float64) float64 {
+	if lookback < 2 || index < lookback || index >= len(data) {
+		return 0
+	}
+
+	returns := make([]float64, 0, lookback)
+	for i := index - lookback + 1; i <= index; i++ {
+		if data[i-1].Close > 0 {
+			returns = append(returns, data[i].Close/data[i-1].Close-1)
+		}
+	}
+
+	return calculateStdDev(returns) * math.Sqrt(barsPerYear)
+}
+
+// barsPerYear returns the number of bars in a year used to annualize per-bar statistics.
+// The "business" calendar has 252 and the "continuous" calendar 365. By default the
+// calendar is detected from the data: a series with weekend bars trades continuously.
+func (e *Engine) barsPerYear(data []types.StockData) float64 {
+	switch e.config.BarCalendar {
+	case "business":
+		return tradingDaysPerYear
+	case "continuous":
+		return calendarDaysPerYear
+	}
+
+	if len(data) == 0 {
+		return tradingDaysPerYear
+	}
+
+	// A business calendar has no weekend bars, while one trading every day has about 2 in 7.
+	// Allow a few stray weekend bars before treating the data as continuous.
+	weekend := 0
+	for _, d := range data {
+		if day := d.Date.Weekday(); day == time.Saturday || day == time.Sunday {
+			weekend++
+		}
+	}
+	if float64(weekend)/float64(len(data)) > 0.1 {
+		return calendarDaysPerYear
+	}
+	return tradingDaysPerYear
+}
+
+// equityStopPrice derives a stop price from the desired equity loss and the position size,
+// so that stopping out of shares at the stop loses StopEquityPct of equity before costs.
+// The position is sized first from the percentage stop, so when StopEquityPct equals
+// PositionSize the equity stop coincides with the percentage stop (up to share rounding);
+// a smaller StopEquityPct tightens the stop and a larger one widens it.
+func (e *Engine) equityStopPrice(equity, entryPrice float64, shares int64) float64 {
+	return entryPrice - equity*e.config.StrategyConfig.StopEquityPct/float64(shares)
+}
+
+// currentEquity returns cash plus the open positions marked at the given price
+func (e *Engine) currentEquity(availableCapital float64, openTrades []types.Trade, price float64) float64 {
+	equity := availableCapital
+	for _, trade := range openTrades {
+		equity += positionValue(trade, price)
+	}
+	return equity
+}
+
+// side returns 1 for a long trade and -1 for a short, the sign of its gain as the price rises
+func side(trade types.Trade) float64 {
+	if trade.Direction == "short" {
+		return -1
+	}
+	return 1
+}
+
+// positionValue returns an open trade's value at price. A long is worth its shares at the
+// price, while a short holds its entry value as collateral plus the gain from the price
+// falling below the entry.
+func positionValue(trade types.Trade, price float64) float64 {
+	return float64(trade.Quantity) * (trade.EntryPrice + side(trade)*(price-trade.EntryPrice))
+}
+
+// reachedStop reports whether price is at or through the trade's stop: at or below it for a
+// long and at or above it for a short
+func reachedStop(trade types.Trade, price float64) bool {
+	return side(trade)*(price-trade.StopLoss) <= 0
+}
+
+// reachedTarget reports whether price is at or beyond a profit target: at or above it for a
+// long and at or below it for a short
+func reachedTarget(trade types.Trade, price, target float64) bool {
+	return side(trade)*(price-target) >= 0
+}
+
+// targetPrices returns the final and partial take-profit prices for the trade's entry and
+// stop. The strategy prices targets for longs, so a short's come from the mirror-image long
+// with the stop reflected below the entry, reflected back below it.
+func (e *Engine) targetPrices(trade types.Trade) (target, partial float64) {
+	s, ok := e.strategy.(strategy.TargetStrategy)
+	if !ok {
+		target = e.strategy.GetTakeProfitPrice(trade.EntryPrice)
+		if trade.Direction == "short" {
+			target = 2*trade.EntryPrice - target
+		}
+		return target, 0
+	}
+
+	if trade.Direction != "short" {
+		return s.GetTargetPrice(trade.EntryPrice, trade.StopLoss), s.GetPartialTargetPrice(trade.EntryPrice, trade.StopLoss)
+	}
+
+	mirrorStop := 2*trade.EntryPrice - trade.StopLoss
+	target = 2*trade.EntryPrice - s.GetTargetPrice(trade.EntryPrice, mirrorStop)
+	if partial = s.GetPartialTargetPrice(trade.EntryPrice, mirrorStop); partial > 0 {
+		partial = 2*trade.EntryPrice - partial
+	}
+	return target, partial
+}
+
+// ladderPrice returns the price that fills the trade's take-profit ladder level at step,
+// below the entry for a short. It is only called for a strategy that prices the ladder.
+func (e *Engine) ladderPrice(trade types.Trade, step int) float64 {
+	price := e.strategy.(strategy.TargetStrategy).GetLadderPrice(trade.EntryPrice, step)
+	if trade.Direction == "short" {
+		return 2*trade.EntryPrice - price
+	}
+	return price
+}
+
+// averageDown adds the original quantity to each open long every time the price falls
+// another AverageDownStep below its first entry, up to MaxAverageDowns adds. The entry
+// becomes the blended average and the stop and target move with it, keeping the
+// original stop distance. Shorts are never added to.
+func (e *Engine) averageDown(openTrades []types.Trade, signal types.Signal, firstEntries map[string]types.Trade, availableCapital *float64) {
+	riskConfig := e.config.RiskManagementConfig
+	if riskConfig.AverageDownStep <= 0 || riskConfig.MaxAverageDowns <= 0 {
+		return
+	}
+
+	for i := range openTrades {
+		trade := &openTrades[i]
+		first := firstEntries[trade.ID]
+
+		if trade.Direction == "short" || trade.AverageDowns >= riskConfig.MaxAverageDowns {
+			continue
+		}
+
+		level := first.EntryPrice * (1 - riskConfig.AverageDownStep*float64(trade.AverageDowns+1))
+		if signal.Price > level {
+			continue
+		}
+
+		addPrice := signal.Price * (1 + e.slippage(signal.Date, first.Quantity))
+		tradeFee := float64(first.Quantity) * addPrice * e.feeRate("market")
+		totalCost := e.roundMoney(float64(first.Quantity)*addPrice + tradeFee)
+		if totalCost > *availableCapital-e.unsettledCash {
+			continue
+		}
+
+		stopDistance := first.EntryPrice - first.StopLoss
+		quantity := trade.Quantity + first.Quantity
+
+		trade.EntryPrice = (trade.EntryPrice*float64(trade.Quantity) + addPrice*float64(first.Quantity)) / float64(quantity)
+		trade.Quantity = quantity
+		trade.StopLoss = trade.EntryPrice - stopDistance
+		target, partial := e.targetPrices(*trade)
+		trade.TakeProfit = target
+		if trade.PartialTarget > 0 {
+			trade.PartialTarget = partial
+		}
+		trade.LadderBase += first.Quantity
+		trade.AverageDowns++
+		trade.Fills = append(trade.Fills, types.Fill{Date: signal.Date, Quantity: first.Quantity, Price: addPrice})
+		*availableCapital -= totalCost
+		e.tradedNotional += float64(first.Quantity) * addPrice
+		e.costsPaid += float64(first.Quantity)*(addPrice-signal.Price) + tradeFee
+		e.entryFees += tradeFee
+
+		e.logger.Debug("trade averaged down",
+			"id", trade.ID,
+			"date", signal.Date.Format("2006-01-02"),
+			"price", addPrice,
+			"quantity", trade.Quantity,
+			"entry_price", trade.EntryPrice,
+			"stop_loss", trade.StopLoss)
+	}
+}
+
+// fillOverBars fills the trade's order with at most MaxVolumeParticipation of each bar's
+// volume, starting on the entry bar at price and continuing at the close of each following
+// bar up to lastBar, with bars lacking volume data filling whatever remains. The trade is
+// re-based on the volume-weighted average fill, keeping its stop distance, its quantity is
+// cut to the shares that filled and FillBar is set to the bar the last of them filled on.
+// The shares filled after the entry bar are recorded as its fills. It returns the
+// volume-weighted fill price before slippage.
+func (e *Engine) fillOverBars(trade *types.Trade, data []types.StockData, index, lastBar int, price float64) float64 {
+	remaining := trade.Quantity
+	var filled int64
+	var cost, reference float64
+
+	for i := index; i <= lastBar && remaining > 0; i++ {
+		barPrice := data[i].Close
+		if i == index {
+			barPrice = price
+		}
+
+		quantity := remaining
+		if limit := int64(float64(data[i].Volume) * e.config.MaxVolumeParticipation); data[i].Volume > 0 && limit < quantity {
+			quantity = limit
+		}
+		if quantity <= 0 {
+			continue
+		}
+
+		fillPrice := barPrice * (1 + side(*trade)*e.slippage(data[i].Date, quantity))
+		if i > index {
+			trade.Fills = append(trade.Fills, types.Fill{Date: data[i].Date, Quantity: quantity, Price: fillPrice})
+		}
+
+		cost += float64(quantity) * fillPrice
+		reference += float64(quantity) * barPrice
+		filled += quantity
+		remaining -= quantity
+		trade.FillBar = i
+	}
+
+	trade.Quantity = filled
+	if filled == 0 {
+		return price
+	}
+
+	stopDistance := trade.EntryPrice - trade.StopLoss
+	trade.EntryPrice = cost / float64(filled)
+	trade.StopLoss = trade.EntryPrice - stopDistance
+	target, partial := e.targetPrices(*trade)
+	trade.TakeProfit = target
+	if trade.PartialTarget > 0 {
+		trade.PartialTarget = partial
+	}
+	trade.LadderBase = filled
+	trade.ActualRisk = math.Abs(stopDistance) * float64(filled)
+
+	return reference / float64(filled)
+}
+
+// feeRate returns the commission rate for the next fill of the given order type. With maker
+// and taker fees set, limit orders add liquidity and pay the maker fee, which is negative
+// for a rebate, and every other fill pays the taker fee. Otherwise with a fee schedule this
+// is the rate of the highest tier the traded notional has reached, falling back to the flat
+// TradeFee before the first tier or without a schedule.
+func (e *Engine) feeRate(orderType string) float64 {
+	if e.config.MakerFee != 0 || e.config.TakerFee != 0 {
+		if orderType == "limit" {
+			return e.config.MakerFee
+		}
+		return e.config.TakerFee
+	}
+
+	rate := e.config.TradeFee
+	reached := -1.0
+
+	for _, tier := range e.config.FeeSchedule {
+		if e.tradedNotional >= tier.MinNotional && tier.MinNotional > reached {
+			rate = tier.Rate
+			reached = tier.MinNotional
+		}
+	}
+
+	return rate
+}
+
+// slippage returns the fractional slippage for an order of the given size on the bar at date.
+// The "volume" model adds half the estimated bid-ask spread, a share of the bar's high-low
+// range, and a price impact proportional to the order's share of the bar's volume to the
+// flat Slippage. Bars without volume pay no impact.
+func (e *Engine) slippage(date time.Time, shares int64) float64 {
+	if e.config.SlippageModel != "volume" {
+		return e.config.Slippage
+	}
+
+	bar, ok := e.bars[date]
+	if !ok || bar.Close <= 0 {
+		return e.config.Slippage
+	}
+
+	spreadFactor := e.config.SpreadFactor
+	if spreadFactor <= 0 {
+		spreadFactor = defaultSpreadFactor
+	}
+	spread := (bar.High - bar.Low) / bar.Close * spreadFactor
+
+	var participation float64
+	if bar.Volume > 0 {
+		participation = float64(shares) / float64(bar.Volume)
+	}
+
+	return e.config.Slippage + spread/2 + e.config.VolumeImpact*participation
+}
+
+// roundMoney rounds an amount to whole cents when RoundToCents is enabled. Cash movements
+// go through it so sums of cent amounts do not pick up floating-point residue.
+func (e *Engine) roundMoney(amount float64) float64 {
+	if !e.config.RoundToCents {
+		return amount
+	}
+	return math.Round(amount*100) / 100
+}
+
+// closeTrade closes a trade at the given price with a market order after slippage and fees
+// and returns the proceeds
+func (e *Engine) closeTrade(trade *types.Trade, date time.Time, price float64, reason string) float64 {
+	return e.closeTradeOrder(trade, date, price, reason, "market")
+}
+
+// closeTradeOrder closes a trade like closeTrade, paying the fee for the given order type.
+// Slippage works against the exit, selling a long lower and buying back a short higher.
+func (e *Engine) closeTradeOrder(trade *types.Trade, date time.Time, price float64, reason, orderType string) float64 {
+	exitPrice := price * (1 - side(*trade)*e.slippage(date, trade.Quantity))
+	tradeFee := float64(trade.Quantity) * exitPrice * e.feeRate(orderType)
+	proceeds := e.roundMoney(positionValue(*trade, exitPrice) - tradeFee)
+	e.tradedNotional += float64(trade.Quantity) * exitPrice
+	e.costsPaid += float64(trade.Quantity)*math.Abs(price-exitPrice) + tradeFee
+
+	trade.ExitDate = &date
+	trade.ExitPrice = &exitPrice
+	trade.Status = "closed"
+	trade.ExitReason = reason
+	trade.ExitBar = e.barIndex[date]
+	trade.ProfitLoss = e.roundMoney(proceeds - (float64(trade.Quantity) * trade.EntryPrice))
+
+	if !sameDay(date, e.pnlDay) {
+		e.pnlDay = date
+		e.dayPnL = 0
+	}
+	e.dayPnL += trade.ProfitLoss
+
+	if e.config.SettlementDays > 0 {
+		e.pendingSettlements = append(e.pendingSettlements, settlement{tradeDate: date, amount: proceeds})
+		e.unsettledCash += proceeds
+	}
+
+	e.logger.Debug("trade closed",
+		"id", trade.ID,
+		"date", date.Format("2006-01-02"),
+		"price", exitPrice,
+		"quantity", trade.Quantity,
+		"pnl", trade.ProfitLoss,
+		"reason", reason)
+
+	return proceeds
+}
+
+// checkStopLossAndTakeProfit checks if any open trades should be closed due to stop loss or take profit.
+// The stop triggers when the bar's range reaches it (the low for a long, the high for a short)
+// and the target when the range reaches it the other way, each filling at its level. Unless
+// DisableGapFill is set, a bar that opens beyond the stop or target fills at its open instead.
+// When a bar reaches both, the stop is assumed to have traded first. The stop is not checked
+// until the trade has been held for StopActivationDelay bars.
+func (e *Engine) checkStopLossAndTakeProfit(openTrades []types.Trade, index int, bar types.StockData, trades *[]types.Trade, availableCapital *float64) []types.Trade {
+	var remainingTrades []types.Trade
+
+	gapped := !e.config.DisableGapFill && bar.Open > 0
+	_, pricesLadder := e.strategy.(strategy.TargetStrategy)
+	laddered := pricesLadder && len(e.config.StrategyConfig.TakeProfitLadder) > 0
+
+	for _, trade := range openTrades {
+		// Exits wait until an order spread over several bars has filled
+		if index <= trade.FillBar {
+			remainingTrades = append(remainingTrades, trade)
+			continue
+		}
+
+		closed := false
+		stopActive := index-trade.EntryBar >= e.config.StrategyConfig.StopActivationDelay
+		adverse, favorable := barExtremes(trade, bar)
+
+		// Check stop loss
+		if stopActive && gapped && reachedStop(trade, bar.Open) {
+			*availableCapital += e.closeTrade(&trade, bar.Date, bar.Open, "stop_loss_gap")
+			*trades = append(*trades, trade)
+			closed = true
+			e.stoppedOut = true
+		} else if stopActive && reachedStop(trade, adverse) {
+			*availableCapital += e.closeTrade(&trade, bar.Date, trade.StopLoss, "stop_loss")
+			*trades = append(*trades, trade)
+			closed = true
+			e.stoppedOut = true
+		} else if laddered {
+			// The ladder replaces the single take profit
+			closed = e.takeLadderProfits(&trade, bar, favorable, trades, availableCapital)
+		} else if gapped && reachedTarget(trade, bar.Open, trade.TakeProfit) {
+			*availableCapital += e.closeTrade(&trade, bar.Date, bar.Open, "take_profit_gap")
+			*trades = append(*trades, trade)
+			closed = true
+		} else if reachedTarget(trade, favorable, trade.TakeProfit) {
+			// Check take profit
+			*availableCapital += e.closeTrade(&trade, bar.Date, trade.TakeProfit, "take_profit")
+			*trades = append(*trades, trade)
+			closed = true
+		} else if trade.PartialTarget > 0 && reachedTarget(trade, favorable, trade.PartialTarget) {
+			e.takePartialProfit(&trade, bar.Date, e.targetFill(trade, bar, trade.PartialTarget), trades, availableCapital)
+		}
+
+		if !closed {
+			remainingTrades = append(remainingTrades, trade)
+		}
+	}
+
+	return remainingTrades
+}
+
+// barExtremes returns the worst and best prices the bar reached for the trade: the low and
+// high for a long, the other way round for a short. A bar without a recorded range uses
+// its close for both.
+func barExtremes(trade types.Trade, bar types.StockData) (adverse, favorable float64) {
+	low, high := bar.Low, bar.High
+	if low <= 0 || high <= 0 {
+		low, high = bar.Close, bar.Close
+	}
+
+	if trade.Direction == "short" {
+		return high, low
+	}
+	return low, high
+}
+
+// targetFill returns where a resting order at a profit target fills on the bar: at the
+// target, or at the open when gap filling is on and the bar opened beyond it
+func (e *Engine) targetFill(trade types.Trade, bar types.StockData, target float64) float64 {
+	if !e.config.DisableGapFill && bar.Open > 0 && reachedTarget(trade, bar.Open, target) {
+		return bar.Open
+	}
+	return target
+}
+
+// takePartialProfit closes PartialFraction of an open trade at the given price, recording
+// the closed shares as their own trade with the same ID. The rest stays open with the
+// original stop and final target.
+func (e *Engine) takePartialProfit(trade *types.Trade, date time.Time, price float64, trades *[]types.Trade, availableCapital *float64) {
+	trade.PartialTarget = 0
+
+	quantity := int64(float64(trade.Quantity) * e.config.StrategyConfig.PartialFraction)
+	if quantity <= 0 || quantity >= trade.Quantity {
+		return
+	}
+
+	partial := *trade
+	partial.Quantity = quantity
+	partial.Fills = splitFills(trade, quantity)
+	*availableCapital += e.closeTrade(&partial, date, price, "partial_take_profit")
+	*trades = append(*trades, partial)
+
+	trade.Quantity -= quantity
+}
+
+// exitPositions closes the open positions at price on an exit signal. A signal with a
+// Quantity only trims that many shares, oldest position first, recording the shares closed
+// from a position that stays open as their own trade with the same ID. It returns the
+// positions still open.
+func (e *Engine) exitPositions(openTrades []types.Trade, signal types.Signal, price float64, trades *[]types.Trade, availableCapital *float64) []types.Trade {
+	var remainingTrades []types.Trade
+	remaining := signal.Quantity
+
+	for i := range openTrades {
+		trade := &openTrades[i]
+		if signal.Quantity > 0 && remaining < trade.Quantity {
+			if remaining > 0 {
+				trimmed := *trade
+				trimmed.Quantity = remaining
+				trimmed.Fills = splitFills(trade, remaining)
+				*availableCapital += e.closeTradeOrder(&trimmed, signal.Date, price, "signal", signal.OrderType)
+				*trades = append(*trades, trimmed)
+
+				trade.Quantity -= remaining
+				trade.LadderBase -= remaining
+				remaining = 0
+			}
+			remainingTrades = append(remainingTrades, *trade)
+			continue
+		}
+
+		remaining -= trade.Quantity
+		*availableCapital += e.closeTradeOrder(trade, signal.Date, price, "signal", signal.OrderType)
+		*trades = append(*trades, *trade)
+	}
+
+	return remainingTrades
+}
+
+// addToPosition buys the signal's Quantity into an open trade at price after slippage and
+// fees, or sells it short into an open short, keeping the trade's stop and target. The
+// shares are recorded as a fill and the entry becomes the blended average. An order the
+// settled cash cannot cover is skipped.
+func (e *Engine) addToPosition(trade *types.Trade, signal types.Signal, price float64, availableCapital *float64) {
+	addPrice := price * (1 + side(*trade)*e.slippage(signal.Date, signal.Quantity))
+	tradeFee := float64(signal.Quantity) * addPrice * e.feeRate(signal.OrderType)
+	totalCost := e.roundMoney(float64(signal.Quantity)*addPrice + tradeFee)
+	if totalCost > *availableCapital-e.unsettledCash {
+		e.logger.Debug("add skipped for lack of cash", "id", trade.ID, "date", signal.Date.Format("2006-01-02"), "quantity", signal.Quantity)
+		return
+	}
+
+	quantity := trade.Quantity + signal.Quantity
+	trade.EntryPrice = (trade.EntryPrice*float64(trade.Quantity) + addPrice*float64(signal.Quantity)) / float64(quantity)
+	trade.Quantity = quantity
+	trade.LadderBase += signal.Quantity
+	trade.Fills = append(trade.Fills, types.Fill{Date: signal.Date, Quantity: signal.Quantity, Price: addPrice})
+	*availableCapital -= totalCost
+	e.tradedNotional += float64(signal.Quantity) * addPrice
+	e.costsPaid += float64(signal.Quantity)*math.Abs(addPrice-price) + tradeFee
+	e.entryFees += tradeFee
+
+	e.logger.Debug("trade added to",
+		"id", trade.ID,
+		"date", signal.Date.Format("2006-01-02"),
+		"price", addPrice,
+		"quantity", trade.Quantity,
+		"entry_price", trade.EntryPrice)
+}
+
+// splitFills returns the fills that go with quantity shares split off an open trade and
+// leaves the rest on the trade. The shares bought on the entry date go first and then the
+// fills in the order they were bought, so the split records together still hold each fill.
+func splitFills(trade *types.Trade, quantity int64) []types.Fill {
+	initial := trade.Quantity
+	for _, fill := range trade.Fills {
+		initial -= fill.Quantity
+	}
+	if quantity <= initial {
+		return nil
+	}
+
+	remaining := quantity - initial
+	var taken, kept []types.Fill
+	for _, fill := range trade.Fills {
+		if remaining <= 0 {
+			kept = append(kept, fill)
+			continue
+		}
+
+		part := fill
+		if part.Quantity > remaining {
+			part.Quantity = remaining
+			rest := fill
+			rest.Quantity -= remaining
+			kept = append(kept, rest)
+		}
+		taken = append(taken, part)
+		remaining -= part.Quantity
+	}
+
+	trade.Fills = kept
+	return taken
+}
+
+// takeLadderProfits closes the shares of an open trade due at each take-profit ladder level
+// the price has reached on the bar, each filling at its level, recording each exit as its own trade with the same ID. Shares are
+// counted against the cumulative fraction so rounding does not leave a remainder behind
+// when the fractions sum to 1. It reports whether the whole position has been closed.
+func (e *Engine) takeLadderProfits(trade *types.Trade, bar types.StockData, price float64, trades *[]types.Trade, availableCapital *float64) bool {
+	ladder := e.config.StrategyConfig.TakeProfitLadder
+
+	var cumulative float64
+	for _, level := range ladder[:trade.LadderStep] {
+		cumulative += level.Fraction
+	}
+
+	for trade.LadderStep < len(ladder) && reachedTarget(*trade, price, e.ladderPrice(*trade, trade.LadderStep)) {
+		fill := e.targetFill(*trade, bar, e.ladderPrice(*trade, trade.LadderStep))
+		cumulative += ladder[trade.LadderStep].Fraction
+		trade.LadderStep++
+
+		exited := trade.LadderBase - trade.Quantity
+		quantity := int64(float64(trade.LadderBase)*cumulative+1e-9) - exited
+		if quantity <= 0 {
+			continue
+		}
+
+		if quantity >= trade.Quantity {
+			*availableCapital += e.closeTrade(trade, bar.Date, fill, "take_profit_ladder")
+			*trades = append(*trades, *trade)
+			return true
+		}
+
+		partial := *trade
+		partial.Quantity = quantity
+		partial.Fills = splitFills(trade, quantity)
+		*availableCapital += e.closeTrade(&partial, bar.Date, fill, "take_profit_ladder")
+		*trades = append(*trades, partial)
+
+		trade.Quantity -= quantity
+	}
+
+	return false
+}
+
+// validateLadder checks the take-profit ladder levels ascend and close at most the whole position
+func validateLadder(ladder []types.TakeProfitLevel) error {
+	var total float64
+	for i, level := range ladder {
+		if level.Gain <= 0 {
+			return fmt.Errorf("level %d needs a positive gain, got %v", i+1, level.Gain)
+		}
+		if i > 0 && level.Gain <= ladder[i-1].Gain {
+			return fmt.Errorf("level %d gain %v does not ascend from %v", i+1, level.Gain, ladder[i-1].Gain)
+		}
+		if level.Fraction <= 0 {
+			return fmt.Errorf("level %d needs a positive fraction, got %v", i+1, level.Fraction)
+		}
+		total += level.Fraction
+	}
+
+	if total > 1+1e-9 {
+		return fmt.Errorf("fractions sum to %v, more than the whole position", total)
+	}
+	return nil
+}
+
+// calculateExcursions records each trade's maximum adverse and favorable excursion by
+// scanning the bars held from entry to exit. The entry fills at the close, so the scan
+// starts on the bar after the entry finished filling, and the exit bar only counts the
+// range from its open to the exit fill.
+func (e *Engine) calculateExcursions(trades []types.Trade, data []types.StockData) {
+	for i := range trades {
+		trade := &trades[i]
+		trade.MAE = 0
+		trade.MFE = 0
+
+		start := trade.EntryBar
+		if trade.FillBar > start {
+			start = trade.FillBar
+		}
+
+		for j := start + 1; j < len(data); j++ {
+			bar := data[j]
+			if trade.ExitDate != nil && bar.Date.After(*trade.ExitDate) {
+				break
+			}
+
+			high, low := bar.High, bar.Low
+			if trade.ExitDate != nil && trade.ExitPrice != nil && bar.Date.Equal(*trade.ExitDate) {
+				high = math.Min(high, math.Max(bar.Open, *trade.ExitPrice))
+				low = math.Max(low, math.Min(bar.Open, *trade.ExitPrice))
+			}
+
+			adverse, favorable := trade.EntryPrice-low, high-trade.EntryPrice
+			if trade.Direction == "short" {
+				adverse, favorable = high-trade.EntryPrice, trade.EntryPrice-low
+			}
+			if adverse > trade.MAE {
+				trade.MAE = adverse
+			}
+			if favorable > trade.MFE {
+				trade.MFE = favorable
+			}
+		}
+	}
+}
+
+// calculateResults computes comprehensive backtest results
+func (e *Engine) calculateResults(trades []types.Trade, data []types.StockData) *types.BacktestResult {
+	result := &types.BacktestResult{
+		Trades:         trades,
+		InitialCapital: e.config.InitialCapital,
+		StartDate:      data[0].Date,
+		EndDate:        data[len(data)-1].Date,
+	}
+
+	// Calculate basic metrics
+	var totalPL float64
+	var winningTrades, losingTrades int64
+	var totalWinAmount, totalLossAmount float64
+
+	result.PnLByTag = make(map[string]float64)
+
+	for _, trade := range trades {
+		totalPL += trade.ProfitLoss
+		result.PnLByTag[trade.Tag] += trade.ProfitLoss
+		if trade.ProfitLoss > 0 {
+			winningTrades++
+			totalWinAmount += trade.ProfitLoss
+		} else if trade.ProfitLoss < 0 {
+			losingTrades++
+			totalLossAmount += math.Abs(trade.ProfitLoss)
+		}
+	}
+
+	result.TotalTrades = int64(len(trades))
+	result.WinningTrades = winningTrades
+	result.LosingTrades = losingTrades
+	result.TotalProfitLoss = e.roundMoney(totalPL)
+
+	cashFlows := e.cashFlowsByBar(data)
+	for _, amount := range cashFlows {
+		result.NetCashFlows += amount
+	}
+	result.FinalCapital = e.roundMoney(e.config.InitialCapital + result.NetCashFlows + totalPL)
+
+	if result.TotalTrades > 0 {
+		result.WinRate = float64(winningTrades) / float64(result.TotalTrades) * 100
+	}
+
+	if winningTrades > 0 {
+		result.AverageWin = totalWinAmount / float64(winningTrades)
+	}
+
+	if losingTrades > 0 {
+		result.AverageLoss = totalLossAmount / float64(losingTrades)
+	}
+
+	// Without losses the profit factor is unbounded, reported as the largest float since
+	// results must stay finite
+	if totalLossAmount > 0 {
+		result.ProfitFactor = totalWinAmount / totalLossAmount
+	} else if totalWinAmount > 0 {
+		result.ProfitFactor = math.MaxFloat64
+	}
+	winFraction := result.WinRate / 100
+	result.Expectancy = winFraction*result.AverageWin - (1-winFraction)*result.AverageLoss
+
+	result.EquityCurve = e.calculateEquityCurve(trades, data)
+
+	// Performance fees come out of the equity, after which the final capital is net of them
+	result.PerformanceFees = e.applyPerformanceFees(result.EquityCurve, data)
+	result.FinalCapital = e.roundMoney(result.FinalCapital - result.PerformanceFees)
+	result.HighWaterMark = calculateHighWaterMark(result.EquityCurve)
+
+	// Calculate total return. Deposits and withdrawals are not performance, so with cash
+	// flows it is the time-weighted return, with the money-weighted return alongside.
+	result.TotalReturn = (result.FinalCapital - result.InitialCapital) / result.InitialCapital * 100
+	if len(cashFlows) > 0 {
+		result.TotalReturn = timeWeightedReturn(e.config.InitialCapital, result.EquityCurve, cashFlows)
+		result.MoneyWeightedReturn = moneyWeightedReturn(e.config.InitialCapital, result.FinalCapital, data, cashFlows)
+	}
+
+	// Calculate annualized return, skipping spans so short that compounding exaggerates it
+	days := result.EndDate.Sub(result.StartDate).Hours() / 24
+	years := days / 365.25
+	if growth := 1 + result.TotalReturn/100; days >= float64(e.minAnnualizeDays()) && years > 0 && growth > 0 && result.InitialCapital > 0 {
+		result.AnnualizedReturn = (math.Pow(growth, 1/years) - 1) * 100
+		result.AnnualizedReturnValid = true
+	}
+
+	// Calculate max drawdown
+	if e.config.DrawdownBasis == "intrabar" {
+		result.MaxDrawdown = e.calculateIntrabarDrawdown(trades, data, result.EquityCurve)
+	} else {
+		result.MaxDrawdown = e.calculateMaxDrawdown(trades)
+	}
+	result.MaxDrawdownDuration = e.calculateMaxDrawdownDuration(trades, result.StartDate, result.EndDate)
+	result.BenchmarkCurve = e.calculateBenchmarkCurve(data)
+	result.RelativeMaxDrawdown = calculateRelativeDrawdown(result.EquityCurve, result.BenchmarkCurve)
+	result.RollingBeta, result.RollingCorrelation = calculateRollingBeta(result.EquityCurve, result.BenchmarkCurve, e.betaWindow())
+
+	result.TimeInMarketPct = calculateTimeInMarket(trades, data)
+	result.PositionLog = e.positionLog
+	result.AvgBarsToWin, result.AvgBarsToLoss = calculateBarsToOutcome(trades)
+
+	// Trade P&L is net of everything but entry fees, so adding back the rest of the costs
+	// gives the gross P&L before any fees or slippage
+	if gross := totalPL - e.entryFees + e.costsPaid; gross > 0 {
+		result.CostDragPct = e.costsPaid / gross * 100
+	}
+
+	returns := e.calculateReturns(result.EquityCurve)
+	result.Volatility = calculateStdDev(returns) * math.Sqrt(e.barsPerYear(data)) * 100
+	result.SharpeRatio = calculateSharpeRatio(returns, e.config.RiskFreeRate, e.barsPerYear(data))
+	result.SortinoRatio = calculateSortinoRatio(returns, e.config.RiskFreeRate, e.barsPerYear(data))
+
+	return result
+}
+
+// validateResult checks every number in the result, including the trades, curves and
+// per-tag P&L, and reports the first NaN or infinite value, which usually comes from bad
+// input data or a division by zero upstream
+func validateResult(result *types.BacktestResult) error {
+	return checkFinite(reflect.ValueOf(*result), "BacktestResult")
+}
+
+// checkFinite walks a value and returns an error naming the path of the first NaN or
+// infinite float within it
+func checkFinite(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("%s is %v", path, f)
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			return checkFinite(v.Elem(), path)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := checkFinite(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := checkFinite(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key())); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if err := checkFinite(v.Field(i), path+"."+field.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// minAnnualizeDays returns the shortest span in calendar days to annualize returns over
+func (e *Engine) minAnnualizeDays() int {
+	if e.config.MinAnnualizeDays > 0 {
+		return e.config.MinAnnualizeDays
+	}
+	return defaultMinAnnualizeDays
+}
+
+// betaWindow returns the rolling window in bars for beta and correlation
+func (e *Engine) betaWindow() int {
+	if e.config.BetaWindow > 0 {
+		return e.config.BetaWindow
+	}
+	return defaultBetaWindow
+}
+
+// calculateReturns computes the per-bar returns of an equity curve, either simple
+// (e1/e0 - 1) or log (ln(e1/e0)) depending on the configured return type
+func (e *Engine) calculateReturns(equity []float64) []float64 {
+	if len(equity) < 2 {
+		return nil
+	}
+
+	returns := make([]float64, 0, len(equity)-1)
+	for i := 1; i < len(equity); i++ {
+		if equity[i-1] <= 0 {
+			continue
+		}
+
+		ratio := equity[i] / equity[i-1]
+		if e.config.ReturnType == "log" {
+			returns = append(returns, math.Log(ratio))
+		} else {
+			returns = append(returns, ratio-1)
+		}
+	}
+
+	return returns
+}
+
+// calculateStdDev calculates the sample standard deviation of values
+func calculateStdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	sumSq := 0.0
+	for _, v := range values {
+		sumSq += (v - mean) * (v - mean)
+	}
+
+	return math.Sqrt(sumSq / float64(len(values)-1))
+}
+
+// calculateSharpeRatio annualizes the mean per-bar return in excess of the risk-free rate
+// over the standard deviation of the returns. The annual risk-free rate is spread evenly
+// over the bars of a year. Returns with no variation give 0.
+func calculateSharpeRatio(returns []float64, riskFreeRate, barsPerYear float64) float64 {
+	stdDev := calculateStdDev(returns)
+	if stdDev == 0 {
+		return 0
+	}
+
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	return (mean - riskFreeRate/barsPerYear) / stdDev * math.Sqrt(barsPerYear)
+}
+
+// calculateSortinoRatio annualizes the mean per-bar return in excess of the risk-free rate
+// over the downside deviation, the root mean square of the excess returns below zero taken
+// over every bar. Returns that never fall below the risk-free rate give 0.
+func calculateSortinoRatio(returns []float64, riskFreeRate, barsPerYear float64) float64 {
+	if len(returns) == 0 {
+		return 0
+	}
+
+	perBar := riskFreeRate / barsPerYear
+	mean := 0.0
+	downside := 0.0
+	for _, r := range returns {
+		excess := r - perBar
+		mean += excess
+		if excess < 0 {
+			downside += excess * excess
+		}
+	}
+	mean /= float64(len(returns))
+
+	deviation := math.Sqrt(downside / float64(len(returns)))
+	if deviation == 0 {
+		return 0
+	}
+
+	return mean / deviation * math.Sqrt(barsPerYear)
+}
+
+// calculateTimeInMarket calculates the percentage of bars that closed with a position open.
+// A position counts from its entry bar up to, but not including, its exit bar.
+func calculateTimeInMarket(trades []types.Trade, data []types.StockData) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	inMarket := 0
+	for _, bar := range data {
+		for _, trade := range trades {
+			if bar.Date.Before(trade.EntryDate) {
+				continue
+			}
+			if trade.ExitDate != nil && !bar.Date.Before(*trade.ExitDate) {
+				continue
+			}
+			inMarket++
+			break
+		}
+	}
+
+	return float64(inMarket) / float64(len(data)) * 100
+}
+
+// calculateBarsToOutcome returns the average number of bars closed trades were held for,
+// split into winners and losers
+func calculateBarsToOutcome(trades []types.Trade) (float64, float64) {
+	var winBars, lossBars, wins, losses int
+
+	for _, trade := range trades {
+		if trade.ExitDate == nil {
+			continue
+		}
+
+		held := trade.ExitBar - trade.EntryBar
+		if trade.ProfitLoss > 0 {
+			winBars += held
+			wins++
+		} else if trade.ProfitLoss < 0 {
+			lossBars += held
+			losses++
+		}
+	}
+
+	var avgWin, avgLoss float64
+	if wins > 0 {
+		avgWin = float64(winBars) / float64(wins)
+	}
+	if losses > 0 {
+		avgLoss = float64(lossBars) / float64(losses)
+	}
+	return avgWin, avgLoss
+}
+
+// calculateBenchmarkCurve computes the equity of buying and holding the stock with the
+// initial capital from the first bar's close. Each cash flow buys or sells shares at the
+// close of the bar it is applied on, so the benchmark is funded like the strategy.
+func (e *Engine) calculateBenchmarkCurve(data []types.StockData) []float64 {
+	benchmark := make([]float64, len(data))
+	if len(data) == 0 || data[0].Close <= 0 {
+		return benchmark
+	}
+
+	cashFlows := e.cashFlowsByBar(data)
+	shares := e.config.InitialCapital / data[0].Close
+
+	for i, bar := range data {
+		if bar.Close > 0 {
+			shares += cashFlows[i] / bar.Close
+		}
+		benchmark[i] = shares * bar.Close
+	}
+
+	return benchmark
+}
+
+// calculateRelativeDrawdown calculates the maximum drawdown of the strategy equity relative
+// to the benchmark equity, as a percentage. It is positive whenever the strategy falls
+// behind its best showing against the benchmark, even if both curves are rising.
+func calculateRelativeDrawdown(equity, benchmark []float64) float64 {
+	peak := 0.0
+	maxDrawdown := 0.0
+
+	for i := range equity {
+		if i >= len(benchmark) || benchmark[i] <= 0 {
+			continue
+		}
+
+		relative := equity[i] / benchmark[i]
+		if relative > peak {
+			peak = relative
+		}
+
+		drawdown := (peak - relative) / peak * 100
+		if drawdown > maxDrawdown {
+			maxDrawdown = drawdown
+		}
+	}
+
+	return maxDrawdown
+}
+
+// calculateMaxDrawdownDuration returns the longest time capital spent below a previous
+// peak, from the exit of the trade setting the peak (or the start, for the initial capital)
+// to the exit of the trade recovering to it. A drawdown still open at the end runs to end.
+func (e *Engine) calculateMaxDrawdownDuration(trades []types.Trade, start, end time.Time) time.Duration {
+	peak := e.config.InitialCapital
+	peakDate := start
+	runningCapital := e.config.InitialCapital
+	underwater := false
+	var longest time.Duration
+
+	for _, trade := range trades {
+		runningCapital += trade.ProfitLoss
+		if runningCapital < peak {
+			underwater = true
+			continue
+		}
+
+		if underwater && trade.ExitDate.Sub(peakDate) > longest {
+			longest = trade.ExitDate.Sub(peakDate)
+		}
+		peak = runningCapital
+		peakDate = *trade.ExitDate
+		underwater = false
+	}
+
+	if underwater && end.Sub(peakDate) > longest {
+		longest = end.Sub(peakDate)
+	}
+
+	return longest
+}
+
+// calculateEquityCurve computes the mark-to-market equity at each bar's close as cash
+// plus the market value of every position open on that bar
+func (e *Engine) calculateEquityCurve(trades []types.Trade, data []types.StockData) []float64 {
+	return e.markToMarket(trades, data, func(_ types.Trade, bar types.StockData) float64 { return bar.Close })
+}
+
+// heldPosition returns the trade cut to the shares held on date, leaving out the fills
+// bought after it, at their average entry price
+func heldPosition(trade types.Trade, date time.Time) types.Trade {
+	held := trade
+	cost := float64(trade.Quantity) * trade.EntryPrice
+	for _, fill := range trade.Fills {
+		if fill.Date.After(date) {
+			held.Quantity -= fill.Quantity
+			cost -= float64(fill.Quantity) * fill.Price
+		}
+	}
+
+	if held.Quantity > 0 {
+		held.EntryPrice = cost / float64(held.Quantity)
+	}
+	return held
+}
+
+// markToMarket computes the equity at each bar as cash, including cash flows so far, plus
+// every open position valued at the price mark picks from the bar for it
+func (e *Engine) markToMarket(trades []types.Trade, data []types.StockData, mark func(types.Trade, types.StockData) float64) []float64 {
+	equity := make([]float64, len(data))
+	cashFlows := e.cashFlowsByBar(data)
+	deposited := 0.0
+
+	for i, bar := range data {
+		deposited += cashFlows[i]
+		cash := e.config.InitialCapital + deposited
+		marketValue := 0.0
+
+		for _, trade := range trades {
+			if bar.Date.Before(trade.EntryDate) {
+				continue
+			}
+
+			if trade.ExitDate != nil && !bar.Date.Before(*trade.ExitDate) {
+				// Closed on or before this bar, so only the realized P&L remains
+				cash += trade.ProfitLoss
+				continue
+			}
+
+			// Still open: the cost of the shares held by this bar has left cash and they are
+			// marked to it
+			held := heldPosition(trade, bar.Date)
+			cash -= float64(held.Quantity) * held.EntryPrice
+			marketValue += positionValue(held, mark(held, bar))
+		}
+
+		equity[i] = cash + marketValue
+	}
+
+	return equity
+}
+
+// calculateIntrabarDrawdown calculates the maximum drawdown of the mark-to-market equity,
+// valuing open positions at each bar's worst price, the low for a long and the high for a
+// short, against the highest closing equity before it.
+// This is the worst case reached while positions were open, which the trade-close
+// measure misses.
+func (e *Engine) calculateIntrabarDrawdown(trades []types.Trade, data []types.StockData, closeEquity []float64) float64 {
+	lowEquity := e.markToMarket(trades, data, func(trade types.Trade, bar types.StockData) float64 {
+		if trade.Direction == "short" {
+			return bar.High
+		}
+		return bar.Low
+	})
+
+	peak := e.config.InitialCapital
+	maxDrawdown := 0.0
+
+	for i := range lowEquity {
+		if peak > 0 {
+			drawdown := (peak - lowEquity[i]) / peak * 100
+			if drawdown > maxDrawdown {
+				maxDrawdown = drawdown
+			}
+		}
+
+		if closeEquity[i] > peak {
+			peak = closeEquity[i]
+		}
+	}
+
+	return maxDrawdown
 }
 
 // calculateMaxDrawdown calculates the maximum drawdown during the backtest period
@@ -0,0 +1,2404 @@
+package backtesting
+
+import (
+	"bytes"
+	"log/slog"
+	"math"
+	"strings"
+	"swing-trader/internal/types"
+	"swing-trader/pkg/indicators"
+	"swing-trader/pkg/strategy"
+	"testing"
+	"time"
+)
+
+// testConfig returns a backtest configuration with the default CLI parameters
+func testConfig() types.BacktestConfig {
+	return types.BacktestConfig{
+		InitialCapital: 10000.0,
+		StrategyConfig: types.StrategyConfig{
+			BuyThreshold:   30.0,
+			SellThreshold:  70.0,
+			StopLoss:       0.05,
+			TakeProfit:     0.10,
+			InitialCapital: 10000.0,
+			RSIPeriod:      14,
+			BBPeriod:       20,
+			BBStdDev:       2.0,
+		},
+		RiskManagementConfig: types.RiskManagementConfig{
+			MaxDrawdown:  0.20,
+			PositionSize: 0.02,
+		},
+	}
+}
+
+// testData builds daily bars with the given closes, using the close for open, high and low
+func testData(closes ...float64) []types.StockData {
+	data := make([]types.StockData, len(closes))
+	for i, c := range closes {
+		data[i] = types.StockData{
+			Date:  time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i),
+			Open:  c,
+			High:  c,
+			Low:   c,
+			Close: c,
+		}
+	}
+	return data
+}
+
+// continuousData builds daily bars with the given closes that each open at the previous
+// close, so a move through a stop or target trades through the level instead of gapping it
+func continuousData(closes ...float64) []types.StockData {
+	data := testData(closes...)
+	for i := 1; i < len(data); i++ {
+		data[i].Open = closes[i-1]
+		if data[i].Open > data[i].High {
+			data[i].High = data[i].Open
+		}
+		if data[i].Open < data[i].Low {
+			data[i].Low = data[i].Open
+		}
+	}
+	return data
+}
+
+func TestRunInsufficientData(t *testing.T) {
+	closes := make([]float64, 10)
+	for i := range closes {
+		closes[i] = 100.0 + float64(i)
+	}
+
+	engine := NewEngine(testConfig())
+	_, err := engine.Run(testData(closes...))
+	if err == nil {
+		t.Fatal("Expected an error for insufficient data, got nil")
+	}
+
+	expected := "insufficient data: got 10 bars, need at least 20"
+	if err.Error() != expected {
+		t.Errorf("Expected error %q, got %q", expected, err.Error())
+	}
+}
+
+func TestRunConfiguredMinDataPoints(t *testing.T) {
+	closes := make([]float64, 30)
+	for i := range closes {
+		closes[i] = 100.0 + float64(i)
+	}
+
+	config := testConfig()
+	config.MinDataPoints = 50
+
+	engine := NewEngine(config)
+	_, err := engine.Run(testData(closes...))
+	if err == nil || !strings.Contains(err.Error(), "need at least 50") {
+		t.Errorf("Expected configured minimum of 50 bars in error, got %v", err)
+	}
+}
+
+func TestCalculateEquityCurveOverlappingPositions(t *testing.T) {
+	data := testData(100, 102, 104, 106, 108, 110)
+
+	exit1Date := data[3].Date
+	exit1Price := 106.0
+	exit2Date := data[5].Date
+	exit2Price := 110.0
+
+	trades := []types.Trade{
+		{
+			ID:         "T1",
+			EntryDate:  data[0].Date,
+			EntryPrice: 100.0,
+			Quantity:   10,
+			ExitDate:   &exit1Date,
+			ExitPrice:  &exit1Price,
+			ProfitLoss: 60.0,
+			Status:     "closed",
+		},
+		{
+			ID:         "T2",
+			EntryDate:  data[1].Date,
+			EntryPrice: 102.0,
+			Quantity:   5,
+			ExitDate:   &exit2Date,
+			ExitPrice:  &exit2Price,
+			ProfitLoss: 40.0,
+			Status:     "closed",
+		},
+	}
+
+	engine := NewEngine(testConfig())
+	equity := engine.calculateEquityCurve(trades, data)
+
+	if len(equity) != len(data) {
+		t.Fatalf("Expected %d equity points, got %d", len(data), len(equity))
+	}
+
+	// Bar 2: both positions open, T1 up 4 x 10 shares, T2 up 2 x 5 shares
+	expected := 10000.0 + 40.0 + 10.0
+	if equity[2] != expected {
+		t.Errorf("Expected equity at bar 2 to be %.2f, got %.2f", expected, equity[2])
+	}
+
+	// Bar 4: T1 realized, T2 still open and up 6 x 5 shares
+	expected = 10000.0 + 60.0 + 30.0
+	if equity[4] != expected {
+		t.Errorf("Expected equity at bar 4 to be %.2f, got %.2f", expected, equity[4])
+	}
+
+	// Final bar: both realized
+	expected = 10000.0 + 60.0 + 40.0
+	if equity[5] != expected {
+		t.Errorf("Expected final equity to be %.2f, got %.2f", expected, equity[5])
+	}
+}
+
+func TestCalculateRelativeDrawdownSlowerThanBenchmark(t *testing.T) {
+	equity := []float64{10000, 10100, 10200, 10300}
+	benchmark := []float64{10000, 10500, 11000, 11500}
+
+	// The strategy never loses money on its own
+	peak := equity[0]
+	for _, v := range equity {
+		if v < peak {
+			t.Fatalf("Test equity should have no absolute drawdown")
+		}
+		peak = v
+	}
+
+	drawdown := calculateRelativeDrawdown(equity, benchmark)
+
+	// Relative equity falls from 1.0 to 10300/11500
+	expected := (1 - 10300.0/11500.0) * 100
+	if math.Abs(drawdown-expected) > 1e-9 {
+		t.Errorf("Expected relative drawdown %.4f%%, got %.4f%%", expected, drawdown)
+	}
+}
+
+func TestCalculateBenchmarkCurve(t *testing.T) {
+	data := testData(100, 110, 90)
+
+	engine := NewEngine(testConfig())
+	benchmark := engine.calculateBenchmarkCurve(data)
+
+	expected := []float64{10000, 11000, 9000}
+	for i := range expected {
+		if math.Abs(benchmark[i]-expected[i]) > 1e-9 {
+			t.Errorf("Expected benchmark at index %d to be %.2f, got %.2f", i, expected[i], benchmark[i])
+		}
+	}
+}
+
+func TestCalculateExcursions(t *testing.T) {
+	data := testData(100, 98, 95, 99, 104, 103, 90)
+	data[2].Low = 93.0   // intraday dip below the close
+	data[4].High = 106.0 // intraday peak above the close
+
+	exitDate := data[5].Date
+	exitPrice := 103.0
+	trades := []types.Trade{
+		{
+			ID:         "T1",
+			EntryDate:  data[0].Date,
+			EntryPrice: 100.0,
+			Quantity:   10,
+			ExitDate:   &exitDate,
+			ExitPrice:  &exitPrice,
+			Status:     "closed",
+		},
+	}
+
+	engine := NewEngine(testConfig())
+	engine.calculateExcursions(trades, data)
+
+	if trades[0].MAE != 7.0 {
+		t.Errorf("Expected MAE of 7.00 from the dip to 93, got %.2f", trades[0].MAE)
+	}
+
+	// The drop to 90 after the exit must not count
+	if trades[0].MFE != 6.0 {
+		t.Errorf("Expected MFE of 6.00 from the peak at 106, got %.2f", trades[0].MFE)
+	}
+}
+
+func TestCalculateExcursionsSkipsEntryBarAndCapsExitBar(t *testing.T) {
+	// The BUY bar trades up to 100 and down to 80 before closing at 85, where the entry fills.
+	// The next bar gaps over the take profit and fills at its open of 95 before running to 97.
+	data := testData(signalTestCloses...)
+	data[7].High = 100
+	data[7].Low = 80
+	data[8].High = 97
+
+	engine := NewEngine(signalTestConfig())
+	trades, err := engine.executeTrades(engine.strategy.GenerateSignals(data), data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 || trades[0].ExitReason != "take_profit_gap" {
+		t.Fatalf("Expected 1 trade closed at the gapped take profit, got %+v", trades)
+	}
+	engine.calculateExcursions(trades, data)
+
+	// Neither the entry bar's range nor the exit bar past the exit fill counts
+	if trades[0].MAE != 0 {
+		t.Errorf("Expected no MAE, got %.2f", trades[0].MAE)
+	}
+	if expected := *trades[0].ExitPrice - trades[0].EntryPrice; math.Abs(trades[0].MFE-expected) > 1e-9 {
+		t.Errorf("Expected MFE of %.2f up to the exit fill, got %.2f", expected, trades[0].MFE)
+	}
+}
+
+// signalTestConfig returns a configuration with short indicator periods so that
+// signalTestCloses produces a BUY at index 7 and a SELL at index 10
+func signalTestConfig() types.BacktestConfig {
+	config := testConfig()
+	config.StrategyConfig.BBPeriod = 5
+	config.StrategyConfig.BBStdDev = 1.5
+	config.StrategyConfig.RSIPeriod = 3
+	return config
+}
+
+// signalTestCloses is a flat series with a sharp drop (oversold, below the lower band)
+// followed by a rally that pushes RSI above 70
+var signalTestCloses = []float64{100, 101, 100, 101, 100, 101, 100, 85, 95, 100, 105, 104}
+
+func TestRunLogsTradeEventsAtDebug(t *testing.T) {
+	var buf bytes.Buffer
+	config := signalTestConfig()
+	config.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	engine := NewEngine(config)
+	result, err := engine.Run(testData(signalTestCloses...))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.TotalTrades != 1 {
+		t.Fatalf("Expected 1 trade, got %d", result.TotalTrades)
+	}
+
+	output := buf.String()
+	for _, expected := range []string{
+		`msg="trade opened" id=T1 date=2023-01-09`,
+		`msg="trade closed" id=T1 date=2023-01-10`,
+		`reason=take_profit`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected debug log to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestRunOmitsTradeEventsAtInfo(t *testing.T) {
+	var buf bytes.Buffer
+	config := signalTestConfig()
+	config.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	engine := NewEngine(config)
+	if _, err := engine.Run(testData(signalTestCloses...)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if strings.Contains(buf.String(), "trade opened") {
+		t.Errorf("Expected no trade events at info level, got:\n%s", buf.String())
+	}
+}
+
+func TestRunExitOnUpperBand(t *testing.T) {
+	config := testConfig()
+	config.StrategyConfig.BBPeriod = 5
+	config.StrategyConfig.BBStdDev = 1.0
+	config.StrategyConfig.RSIPeriod = 3
+	config.StrategyConfig.TakeProfit = 0.20
+	config.StrategyConfig.ExitOnUpperBand = true
+
+	// Oversold entry at 85 on bar 7; bar 11 opens at 93 and trades up through the upper
+	// band at about 94.23 on its way to 96, well short of the 102 take profit
+	data := testData(100, 101, 100, 101, 100, 101, 100, 85, 88, 90, 93, 96, 99, 104)
+	data[11].Open = 93.0
+	upper := indicators.CalculateBollingerBands(data, 5, 1.0)[11].Upper
+
+	result, err := NewEngine(config).Run(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.TotalTrades != 1 {
+		t.Fatalf("Expected 1 trade, got %d", result.TotalTrades)
+	}
+
+	trade := result.Trades[0]
+	if trade.ExitReason != "signal" || !trade.ExitDate.Equal(data[11].Date) {
+		t.Fatalf("Expected the band exit on %s, got %s on %s",
+			data[11].Date.Format("2006-01-02"), trade.ExitReason, trade.ExitDate.Format("2006-01-02"))
+	}
+	if math.Abs(*trade.ExitPrice-upper) > 1e-9 {
+		t.Errorf("Expected the exit at the %.4f upper band rather than the close or target, got %.4f", upper, *trade.ExitPrice)
+	}
+}
+
+func TestRunRiskPerTradeMatchesAcrossStopModes(t *testing.T) {
+	data := testData(signalTestCloses...)
+	for i := range data {
+		data[i].High = data[i].Close + 1
+		data[i].Low = data[i].Close - 1
+	}
+
+	percentConfig := signalTestConfig()
+
+	atrConfig := signalTestConfig()
+	atrConfig.StrategyConfig.StopMode = "atr"
+	atrConfig.StrategyConfig.ATRPeriod = 3
+	atrConfig.StrategyConfig.ATRMultiplier = 1.5
+
+	riskAmount := percentConfig.InitialCapital * percentConfig.RiskManagementConfig.PositionSize
+
+	var stopDistances []float64
+	for _, config := range []types.BacktestConfig{percentConfig, atrConfig} {
+		result, err := NewEngine(config).Run(data)
+		if err != nil {
+			t.Fatalf("Unexpected error for stop mode %q: %v", config.StrategyConfig.StopMode, err)
+		}
+		if len(result.Trades) == 0 {
+			t.Fatalf("Expected a trade for stop mode %q", config.StrategyConfig.StopMode)
+		}
+
+		trade := result.Trades[0]
+		riskPerShare := trade.EntryPrice - trade.StopLoss
+		risk := float64(trade.Quantity) * riskPerShare
+
+		// Whole shares mean the risk can fall short by at most one share's risk
+		if risk > riskAmount || riskAmount-risk >= riskPerShare {
+			t.Errorf("Expected dollar risk close to %.2f for stop mode %q, got %.2f",
+				riskAmount, config.StrategyConfig.StopMode, risk)
+		}
+		stopDistances = append(stopDistances, riskPerShare)
+	}
+
+	if math.Abs(stopDistances[0]-stopDistances[1]) < 0.01 {
+		t.Errorf("Expected the stop modes to place different stops, both were %.2f away", stopDistances[0])
+	}
+}
+
+func TestCalculateReturnsSimpleVsLog(t *testing.T) {
+	equity := []float64{10000, 10500, 10200, 11000, 10800}
+
+	simpleConfig := testConfig()
+	simpleReturns := NewEngine(simpleConfig).calculateReturns(equity)
+
+	logConfig := testConfig()
+	logConfig.ReturnType = "log"
+	logReturns := NewEngine(logConfig).calculateReturns(equity)
+
+	if len(simpleReturns) != len(equity)-1 || len(logReturns) != len(equity)-1 {
+		t.Fatalf("Expected %d returns, got %d simple and %d log", len(equity)-1, len(simpleReturns), len(logReturns))
+	}
+
+	total := equity[len(equity)-1] / equity[0]
+
+	// Simple returns compound multiplicatively back to the total
+	compounded := 1.0
+	for _, r := range simpleReturns {
+		compounded *= 1 + r
+	}
+	if math.Abs(compounded-total) > 1e-12 {
+		t.Errorf("Expected simple returns to compound to %f, got %f", total, compounded)
+	}
+
+	// Log returns sum to the log of the total
+	sum := 0.0
+	for _, r := range logReturns {
+		sum += r
+	}
+	if math.Abs(sum-math.Log(total)) > 1e-12 {
+		t.Errorf("Expected log returns to sum to %f, got %f", math.Log(total), sum)
+	}
+
+	for i := range simpleReturns {
+		if simpleReturns[i] == logReturns[i] {
+			t.Errorf("Expected simple and log returns to differ at index %d", i)
+		}
+	}
+
+	if calculateStdDev(simpleReturns) == calculateStdDev(logReturns) {
+		t.Error("Expected return type to change volatility")
+	}
+}
+
+func TestCalculateSharpeRatio(t *testing.T) {
+	// Mean 0.625% a bar over a standard deviation of about 1.109%, annualized over 252 bars
+	returns := []float64{0.01, -0.005, 0.02, 0.0}
+
+	if sharpe := calculateSharpeRatio(returns, 0, 252); math.Abs(sharpe-8.9490) > 1e-4 {
+		t.Errorf("Expected a Sharpe ratio of 8.9490, got %.4f", sharpe)
+	}
+
+	// A 2.52% risk-free rate takes 0.01% off each bar's return
+	if sharpe := calculateSharpeRatio(returns, 0.0252, 252); math.Abs(sharpe-8.8058) > 1e-4 {
+		t.Errorf("Expected a Sharpe ratio of 8.8058 over the risk-free rate, got %.4f", sharpe)
+	}
+
+	if sharpe := calculateSharpeRatio([]float64{0.01, 0.01, 0.01}, 0, 252); sharpe != 0 {
+		t.Errorf("Expected 0 for returns with no variation, got %.4f", sharpe)
+	}
+
+	// The result takes it from the returns of the equity curve
+	data := testData(100, 102, 101, 104, 103, 106)
+	signals := []types.Signal{{Date: data[0].Date, Type: "BUY", Price: 100.0}}
+
+	engine := NewEngine(testConfig())
+	trades, err := engine.executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result := engine.calculateResults(trades, data)
+
+	expected := calculateSharpeRatio(engine.calculateReturns(result.EquityCurve), 0, engine.barsPerYear(data))
+	if expected == 0 || math.Abs(result.SharpeRatio-expected) > 1e-9 {
+		t.Errorf("Expected a Sharpe ratio of %.4f from the equity curve, got %.4f", expected, result.SharpeRatio)
+	}
+}
+
+func TestCalculateSortinoRatio(t *testing.T) {
+	// Mostly gains with two small losses: the downside deviation of about 0.456% is far
+	// below the 1.39% standard deviation, so Sortino is about three times Sharpe
+	returns := []float64{0.02, 0.015, -0.01, 0.025, 0.01, -0.005}
+
+	sortino := calculateSortinoRatio(returns, 0, 252)
+	if math.Abs(sortino-31.8810) > 1e-4 {
+		t.Errorf("Expected a Sortino ratio of 31.8810, got %.4f", sortino)
+	}
+	if sharpe := calculateSharpeRatio(returns, 0, 252); sortino < 2*sharpe {
+		t.Errorf("Expected Sortino (%.4f) well above Sharpe (%.4f) when losses are small", sortino, sharpe)
+	}
+
+	// The risk-free rate lowers the excess returns and counts shortfalls against it
+	if sortino := calculateSortinoRatio(returns, 0.0252, 252); math.Abs(sortino-31.1591) > 1e-4 {
+		t.Errorf("Expected a Sortino ratio of 31.1591 over the risk-free rate, got %.4f", sortino)
+	}
+
+	if sortino := calculateSortinoRatio([]float64{0.01, 0.02}, 0, 252); sortino != 0 {
+		t.Errorf("Expected 0 without any downside, got %.4f", sortino)
+	}
+}
+
+func TestCalculateTimeInMarket(t *testing.T) {
+	data := testData(100, 101, 102, 103, 104, 105, 106, 107, 108, 109)
+
+	exit1 := data[4].Date
+	exit2 := data[8].Date
+	trades := []types.Trade{
+		{ID: "T1", EntryDate: data[1].Date, ExitDate: &exit1}, // held over bars 1-3
+		{ID: "T2", EntryDate: data[6].Date, ExitDate: &exit2}, // held over bars 6-7
+	}
+
+	pct := calculateTimeInMarket(trades, data)
+	if math.Abs(pct-50.0) > 1e-9 {
+		t.Errorf("Expected 50.0%% time in market, got %.2f%%", pct)
+	}
+
+	// An open trade is held through the last bar
+	trades = append(trades, types.Trade{ID: "T3", EntryDate: data[9].Date})
+	pct = calculateTimeInMarket(trades, data)
+	if math.Abs(pct-60.0) > 1e-9 {
+		t.Errorf("Expected 60.0%% time in market with an open trade, got %.2f%%", pct)
+	}
+}
+
+func TestExecuteTradesEquityStopLosesConfiguredPct(t *testing.T) {
+	config := testConfig()
+	config.StrategyConfig.StopMode = "equity"
+	config.StrategyConfig.StopEquityPct = 0.01
+
+	data := testData(100, 99, 97.5, 97, 96)
+
+	// 2% risk over a 5% stop sizes 40 shares, so losing 1% of 10000 puts the stop $2.50 below entry
+	stopPrice := 97.5
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+	}
+
+	engine := NewEngine(config)
+	trades, err := engine.executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 {
+		t.Fatalf("Expected 1 trade, got %d", len(trades))
+	}
+
+	trade := trades[0]
+	if math.Abs(trade.StopLoss-stopPrice) > 1e-9 {
+		t.Errorf("Expected stop at %.2f, got %.2f", stopPrice, trade.StopLoss)
+	}
+	if trade.ExitDate == nil || !trade.ExitDate.Equal(data[2].Date) {
+		t.Fatalf("Expected the trade to stop out on %s", data[2].Date.Format("2006-01-02"))
+	}
+
+	expectedLoss := -config.InitialCapital * config.StrategyConfig.StopEquityPct
+	if math.Abs(trade.ProfitLoss-expectedLoss) > 1e-9 {
+		t.Errorf("Expected realized loss of %.2f, got %.2f", expectedLoss, trade.ProfitLoss)
+	}
+}
+
+func TestSizePositionVolTarget(t *testing.T) {
+	// Calm regime moving 0.5% a day, then a volatile regime moving 3% a day
+	closes := []float64{100}
+	for i := 0; i < 20; i++ {
+		if i%2 == 0 {
+			closes = append(closes, closes[len(closes)-1]*1.005)
+		} else {
+			closes = append(closes, closes[len(closes)-1]/1.005)
+		}
+	}
+	calmIndex := len(closes) - 1
+	for i := 0; i < 20; i++ {
+		if i%2 == 0 {
+			closes = append(closes, closes[len(closes)-1]*1.03)
+		} else {
+			closes = append(closes, closes[len(closes)-1]/1.03)
+		}
+	}
+	volatileIndex := len(closes) - 1
+	data := testData(closes...)
+
+	config := testConfig()
+	config.RiskManagementConfig.SizingMode = "vol_target"
+	config.RiskManagementConfig.TargetVolatility = 0.12
+	config.RiskManagementConfig.VolatilityLookback = 10
+	engine := NewEngine(config)
+
+	// Same capital, price and stop for both signals
+	calmShares := engine.sizePosition(10000, 100, 95, 0, data, calmIndex)
+	volatileShares := engine.sizePosition(10000, 100, 95, 0, data, volatileIndex)
+
+	if volatileShares >= calmShares {
+		t.Errorf("Expected fewer shares in the volatile regime, got %d calm vs %d volatile", calmShares, volatileShares)
+	}
+
+	// The fixed risk mode ignores volatility
+	riskEngine := NewEngine(testConfig())
+	if riskEngine.sizePosition(10000, 100, 95, 0, data, calmIndex) != riskEngine.sizePosition(10000, 100, 95, 0, data, volatileIndex) {
+		t.Error("Expected risk sizing to be independent of volatility")
+	}
+}
+
+func TestSizePositionStrengthScaled(t *testing.T) {
+	config := testConfig()
+	config.RiskManagementConfig.StrengthScaledSizing = true
+	config.RiskManagementConfig.MinStrengthFraction = 0.01
+	config.RiskManagementConfig.MaxStrengthFraction = 0.03
+	engine := NewEngine(config)
+	data := testData(100)
+
+	// $5 of risk per share: 1% to 3% of 10000 risks $100 to $300
+	if weakest, strongest := engine.sizePosition(10000, 100, 95, 0, data, 0), engine.sizePosition(10000, 100, 95, 1, data, 0); weakest != 20 || strongest != 60 {
+		t.Errorf("Expected 20 to 60 shares across the strength range, got %d and %d", weakest, strongest)
+	}
+
+	weak := engine.sizePosition(10000, 100, 95, 0.25, data, 0)
+	strong := engine.sizePosition(10000, 100, 95, 0.75, data, 0)
+	if strong <= weak {
+		t.Errorf("Expected the stronger signal to size larger, got %d vs %d", strong, weak)
+	}
+
+	// Without strength scaling both use the 2% position size
+	engine = NewEngine(testConfig())
+	if engine.sizePosition(10000, 100, 95, 0.25, data, 0) != engine.sizePosition(10000, 100, 95, 0.75, data, 0) {
+		t.Error("Expected signal strength to be ignored without strength scaling")
+	}
+}
+
+func TestExecuteTradesAverageDown(t *testing.T) {
+	config := testConfig()
+	config.StrategyConfig.StopLoss = 0.20
+	config.RiskManagementConfig.PositionSize = 0.04
+	config.RiskManagementConfig.AverageDownStep = 0.03
+	config.RiskManagementConfig.MaxAverageDowns = 2
+
+	data := testData(100, 96, 93, 90, 88)
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+		{Date: data[1].Date, Type: "BUY", Price: 96.0}, // below the 97 level: first add
+		{Date: data[2].Date, Type: "BUY", Price: 93.0}, // below the 94 level: second add
+		{Date: data[3].Date, Type: "BUY", Price: 90.0}, // below the 91 level, but the cap is reached
+	}
+
+	engine := NewEngine(config)
+	trades, err := engine.executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 {
+		t.Fatalf("Expected 1 trade, got %d", len(trades))
+	}
+
+	trade := trades[0]
+
+	// 4% risk over a 20% stop sizes 20 shares per fill
+	if trade.Quantity != 60 {
+		t.Errorf("Expected 60 shares after two adds, got %d", trade.Quantity)
+	}
+	if trade.AverageDowns != 2 {
+		t.Errorf("Expected 2 average downs, got %d", trade.AverageDowns)
+	}
+
+	expectedEntry := (100.0 + 96.0 + 93.0) / 3
+	if math.Abs(trade.EntryPrice-expectedEntry) > 1e-9 {
+		t.Errorf("Expected blended entry %.4f, got %.4f", expectedEntry, trade.EntryPrice)
+	}
+
+	// The $20 stop distance from the first entry carries over to the blended entry
+	if math.Abs(trade.StopLoss-(expectedEntry-20.0)) > 1e-9 {
+		t.Errorf("Expected stop at %.4f, got %.4f", expectedEntry-20.0, trade.StopLoss)
+	}
+
+	// Each add is only marked once bought: 20 shares at 100 on bar 0, then 40 costing
+	// $3920 marked at 96 on bar 1
+	equity := engine.calculateEquityCurve(trades, data)
+	if math.Abs(equity[0]-10000) > 1e-9 || math.Abs(equity[1]-9920) > 1e-9 {
+		t.Errorf("Expected equity of $10000 on bar 0 and $9920 on bar 1, got $%.2f and $%.2f", equity[0], equity[1])
+	}
+}
+
+func TestSplitFills(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2023, 1, 2+i, 0, 0, 0, 0, time.UTC) }
+	trade := types.Trade{
+		Quantity: 60,
+		Fills: []types.Fill{
+			{Date: day(1), Quantity: 20, Price: 96},
+			{Date: day(2), Quantity: 20, Price: 93},
+		},
+	}
+
+	// The 20 shares bought at entry go first, then 10 of the first add
+	taken := splitFills(&trade, 30)
+	if len(taken) != 1 || taken[0].Quantity != 10 || taken[0].Price != 96 {
+		t.Fatalf("Expected the split to take 10 shares of the first add, got %+v", taken)
+	}
+	if len(trade.Fills) != 2 || trade.Fills[0].Quantity != 10 || trade.Fills[1].Quantity != 20 {
+		t.Errorf("Expected 10 and 20 shares of the adds left on the trade, got %+v", trade.Fills)
+	}
+
+	// A split within the entry shares takes no fills
+	trade = types.Trade{Quantity: 40, Fills: []types.Fill{{Date: day(1), Quantity: 20, Price: 96}}}
+	if taken := splitFills(&trade, 15); len(taken) != 0 || len(trade.Fills) != 1 {
+		t.Errorf("Expected the entry shares to cover the split, got %+v taken", taken)
+	}
+}
+
+func TestExecuteTradesGapFill(t *testing.T) {
+	data := testData(100, 99, 96)
+	// Overnight gap down through the 95 stop, recovering to close above it
+	data[2].Open = 90.0
+	data[2].Low = 89.0
+
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+	}
+
+	// Gap filling is on by default
+	config := testConfig()
+	trades, err := NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 || trades[0].ExitPrice == nil {
+		t.Fatalf("Expected 1 closed trade, got %+v", trades)
+	}
+	if *trades[0].ExitPrice != 90.0 {
+		t.Errorf("Expected the gap to fill at the 90.00 open, got %.2f", *trades[0].ExitPrice)
+	}
+
+	// Without gap fills the stop fills at its own level
+	config.DisableGapFill = true
+	trades, err = NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 || *trades[0].ExitPrice != 95.0 {
+		t.Errorf("Expected the stop to fill at 95.00 without gap fills, got %.2f", *trades[0].ExitPrice)
+	}
+}
+
+func TestExecuteTradesIntrabarStopLoss(t *testing.T) {
+	// The low of 94 on bar 2 breaches the 95 stop while the close stays at 98
+	data := testData(100, 99, 98, 97)
+	data[2].Low = 94.0
+
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+	}
+
+	trades, err := NewEngine(testConfig()).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 {
+		t.Fatalf("Expected 1 trade, got %d", len(trades))
+	}
+
+	// The bar has no signal, and the stop fills at its level rather than the low or close
+	trade := trades[0]
+	if trade.ExitReason != "stop_loss" || !trade.ExitDate.Equal(data[2].Date) {
+		t.Fatalf("Expected the stop loss on %s, got %s on %s",
+			data[2].Date.Format("2006-01-02"), trade.ExitReason, trade.ExitDate.Format("2006-01-02"))
+	}
+	if math.Abs(*trade.ExitPrice-95.0) > 1e-9 {
+		t.Errorf("Expected the stop to fill at 95.00, got %.2f", *trade.ExitPrice)
+	}
+	if math.Abs(trade.ProfitLoss+200) > 1e-9 {
+		t.Errorf("Expected a $200 loss on 40 shares, got $%.2f", trade.ProfitLoss)
+	}
+}
+
+func TestExecuteTradesIntrabarTakeProfit(t *testing.T) {
+	// The high of 111 on bar 2 reaches the 110 target while the close stays at 104
+	data := testData(100, 102, 104, 103)
+	data[2].High = 111.0
+
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+	}
+
+	trades, err := NewEngine(testConfig()).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 {
+		t.Fatalf("Expected 1 trade, got %d", len(trades))
+	}
+	if trade := trades[0]; trade.ExitReason != "take_profit" || !trade.ExitDate.Equal(data[2].Date) || math.Abs(*trade.ExitPrice-110.0) > 1e-9 {
+		t.Errorf("Expected the take profit at 110.00 on %s, got %s at %.2f on %s", data[2].Date.Format("2006-01-02"),
+			trade.ExitReason, *trade.ExitPrice, trade.ExitDate.Format("2006-01-02"))
+	}
+
+	// A bar reaching both the stop and the target is taken as a stop-out
+	data[2].Low = 94.0
+	trades, err = NewEngine(testConfig()).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 || trades[0].ExitReason != "stop_loss" {
+		t.Errorf("Expected a bar spanning stop and target to stop out, got %+v", trades)
+	}
+}
+
+func TestCalculateResultsAnnualizedReturnGuard(t *testing.T) {
+	// Five calendar days with a 5% gain would compound to thousands of percent a year
+	data := testData(100, 101, 102, 103, 104, 105)
+	exitDate := data[5].Date
+	exitPrice := 105.0
+	trades := []types.Trade{{
+		ID:         "T1",
+		EntryDate:  data[0].Date,
+		ExitDate:   &exitDate,
+		EntryPrice: 100,
+		ExitPrice:  &exitPrice,
+		Quantity:   10,
+		ProfitLoss: 500,
+		Status:     "closed",
+	}}
+
+	engine := NewEngine(testConfig())
+	result := engine.calculateResults(trades, data)
+
+	if result.AnnualizedReturnValid || result.AnnualizedReturn != 0 {
+		t.Errorf("Expected the annualized return to be suppressed for a 5-day backtest, got %.2f%% (valid %v)",
+			result.AnnualizedReturn, result.AnnualizedReturnValid)
+	}
+	if math.Abs(result.TotalReturn-5) > 1e-9 {
+		t.Errorf("Expected the total return to still be reported as 5%%, got %.2f%%", result.TotalReturn)
+	}
+
+	// Lowering the minimum span annualizes it again
+	config := testConfig()
+	config.MinAnnualizeDays = 5
+	result = NewEngine(config).calculateResults(trades, data)
+
+	if !result.AnnualizedReturnValid || result.AnnualizedReturn < 1000 {
+		t.Errorf("Expected a compounded annualized return above 1000%% with a 5-day minimum, got %.2f%% (valid %v)",
+			result.AnnualizedReturn, result.AnnualizedReturnValid)
+	}
+}
+
+func TestCalculateResultsContinuousCalendar(t *testing.T) {
+	// A year of bars every calendar day, like crypto, with one trade returning 10%
+	closes := make([]float64, 366)
+	for i := range closes {
+		closes[i] = 100 + float64(i%7)
+	}
+	data := testData(closes...)
+	exitDate := data[365].Date
+	exitPrice := 110.0
+	trades := []types.Trade{{
+		ID:         "T1",
+		EntryDate:  data[0].Date,
+		ExitDate:   &exitDate,
+		EntryPrice: 100,
+		ExitPrice:  &exitPrice,
+		Quantity:   100,
+		ProfitLoss: 1000,
+		Status:     "closed",
+	}}
+
+	engine := NewEngine(testConfig())
+	if bars := engine.barsPerYear(data); bars != 365 {
+		t.Fatalf("Expected weekend bars to be detected as a 365-bar year, got %.0f", bars)
+	}
+
+	result := engine.calculateResults(trades, data)
+
+	// The return is annualized over calendar days, so a year-long backtest keeps its total
+	if !result.AnnualizedReturnValid || math.Abs(result.AnnualizedReturn-result.TotalReturn) > 0.01 {
+		t.Errorf("Expected the annualized return to match the %.2f%% total return over a year, got %.2f%%",
+			result.TotalReturn, result.AnnualizedReturn)
+	}
+
+	expected := calculateStdDev(engine.calculateReturns(result.EquityCurve)) * math.Sqrt(365) * 100
+	if math.Abs(result.Volatility-expected) > 1e-9 {
+		t.Errorf("Expected volatility annualized over 365 bars (%.4f), got %.4f", expected, result.Volatility)
+	}
+
+	// Forcing the business calendar annualizes over 252 bars
+	config := testConfig()
+	config.BarCalendar = "business"
+	if bars := NewEngine(config).barsPerYear(data); bars != 252 {
+		t.Errorf("Expected the business calendar to use 252 bars, got %.0f", bars)
+	}
+
+	// Weekday-only data is detected as a business calendar
+	var weekdays []types.StockData
+	for _, d := range data {
+		if day := d.Date.Weekday(); day != time.Saturday && day != time.Sunday {
+			weekdays = append(weekdays, d)
+		}
+	}
+	if bars := engine.barsPerYear(weekdays); bars != 252 {
+		t.Errorf("Expected weekday-only data to use 252 bars, got %.0f", bars)
+	}
+}
+
+func TestCalculateResultsBarsToOutcome(t *testing.T) {
+	data := testData(make([]float64, 30)...)
+	closed := func(entryBar, exitBar int, pnl float64) types.Trade {
+		return types.Trade{
+			EntryDate:  data[entryBar].Date,
+			ExitDate:   &data[exitBar].Date,
+			EntryBar:   entryBar,
+			ExitBar:    exitBar,
+			ProfitLoss: pnl,
+			Status:     "closed",
+		}
+	}
+
+	// Winners held 10 and 6 bars, losers 2, 3 and 1; the breakeven trade counts as neither
+	trades := []types.Trade{
+		closed(0, 10, 250),
+		closed(11, 13, -100),
+		closed(14, 17, -80),
+		closed(18, 24, 120),
+		closed(25, 26, -50),
+		closed(27, 29, 0),
+	}
+
+	result := NewEngine(testConfig()).calculateResults(trades, data)
+
+	if result.AvgBarsToWin != 8 {
+		t.Errorf("Expected winners to average 8 bars, got %.2f", result.AvgBarsToWin)
+	}
+	if result.AvgBarsToLoss != 2 {
+		t.Errorf("Expected losers to average 2 bars, got %.2f", result.AvgBarsToLoss)
+	}
+}
+
+func TestCalculateResultsProfitFactorAndExpectancy(t *testing.T) {
+	data := testData(make([]float64, 10)...)
+	closed := func(exitBar int, pnl float64) types.Trade {
+		return types.Trade{ExitDate: &data[exitBar].Date, ProfitLoss: pnl, Status: "closed"}
+	}
+
+	// $500 of wins against $150 of losses, half the trades winning $250 on average and
+	// the other half losing $75
+	trades := []types.Trade{closed(2, 300), closed(4, -100), closed(6, 200), closed(8, -50)}
+	result := NewEngine(testConfig()).calculateResults(trades, data)
+
+	if math.Abs(result.ProfitFactor-500.0/150.0) > 1e-9 {
+		t.Errorf("Expected a profit factor of 3.33, got %.4f", result.ProfitFactor)
+	}
+	if math.Abs(result.Expectancy-87.5) > 1e-9 {
+		t.Errorf("Expected an expectancy of $87.50 per trade, got $%.4f", result.Expectancy)
+	}
+
+	// Without losses the profit factor is the finite sentinel rather than a division by zero
+	result = NewEngine(testConfig()).calculateResults(trades[:1], data)
+	if result.ProfitFactor != math.MaxFloat64 || result.Expectancy != 300 {
+		t.Errorf("Expected the no-loss sentinel and a $300 expectancy, got %v and %.2f", result.ProfitFactor, result.Expectancy)
+	}
+
+	result = NewEngine(testConfig()).calculateResults(nil, data)
+	if result.ProfitFactor != 0 || result.Expectancy != 0 {
+		t.Errorf("Expected zero metrics without trades, got %v and %.2f", result.ProfitFactor, result.Expectancy)
+	}
+}
+
+func TestCalculateResultsCostDrag(t *testing.T) {
+	// Ten quick round trips, each buying at 100 and selling at 102
+	var closes []float64
+	for i := 0; i < 10; i++ {
+		closes = append(closes, 100, 102)
+	}
+	data := testData(closes...)
+	var signals []types.Signal
+	for i, d := range data {
+		signal := types.Signal{Date: d.Date, Type: "BUY", Price: d.Close}
+		if i%2 == 1 {
+			signal.Type = "SELL"
+		}
+		signals = append(signals, signal)
+	}
+
+	config := testConfig()
+	config.TradeFee = 0.001
+	config.Slippage = 0.002
+	engine := NewEngine(config)
+
+	trades, err := engine.executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 10 {
+		t.Fatalf("Expected 10 round trips, got %d", len(trades))
+	}
+	result := engine.calculateResults(trades, data)
+
+	// Rebuild the costs and the gross P&L from the fills
+	var costs, gross float64
+	for _, trade := range trades {
+		quantity := float64(trade.Quantity)
+		costs += quantity*(trade.EntryPrice-100) + quantity*trade.EntryPrice*config.TradeFee
+		costs += quantity*(102-*trade.ExitPrice) + quantity**trade.ExitPrice*config.TradeFee
+		gross += quantity * (102 - 100)
+	}
+
+	expected := costs / gross * 100
+	if math.Abs(result.CostDragPct-expected) > 1e-6 {
+		t.Errorf("Expected a cost drag of %.4f%%, got %.4f%%", expected, result.CostDragPct)
+	}
+
+	// About 0.6% of turnover against a 2% move eats roughly a third of the gross
+	if result.CostDragPct < 25 || result.CostDragPct > 35 {
+		t.Errorf("Expected roughly a third of the gross P&L lost to costs, got %.2f%%", result.CostDragPct)
+	}
+}
+
+func TestExecuteTradesFeeScheduleTiers(t *testing.T) {
+	data := testData(100, 100, 100, 100)
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+		{Date: data[1].Date, Type: "SELL", Price: 100.0},
+		{Date: data[2].Date, Type: "BUY", Price: 100.0},
+		{Date: data[3].Date, Type: "SELL", Price: 100.0},
+	}
+
+	config := testConfig()
+	config.TradeFee = 0.01
+	config.FeeSchedule = []types.FeeTier{
+		{MinNotional: 0, Rate: 0.01},
+		{MinNotional: 10000, Rate: 0.001},
+	}
+
+	trades, err := NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 2 {
+		t.Fatalf("Expected 2 trades, got %d", len(trades))
+	}
+
+	// A flat round trip loses only the exit fee, so the P&L gives the rate applied
+	exitRate := func(trade types.Trade) float64 {
+		return -trade.ProfitLoss / (float64(trade.Quantity) * *trade.ExitPrice)
+	}
+
+	// The first round trip trades about $8,000, below the $10,000 tier
+	if rate := exitRate(trades[0]); math.Abs(rate-0.01) > 1e-9 {
+		t.Errorf("Expected the first exit to pay the 1%% base rate, got %.4f%%", rate*100)
+	}
+
+	// The second entry takes the notional past $10,000, so its exit pays the lower rate
+	if rate := exitRate(trades[1]); math.Abs(rate-0.001) > 1e-9 {
+		t.Errorf("Expected the second exit to pay the 0.1%% tier rate, got %.4f%%", rate*100)
+	}
+}
+
+func TestExecuteTradesMakerTakerFees(t *testing.T) {
+	config := testConfig()
+	config.TradeFee = 0.01
+	config.MakerFee = -0.0002
+	config.TakerFee = 0.001
+
+	// A flat round trip, exiting with a resting limit order or at the market
+	exitWith := func(sell types.Signal) types.Trade {
+		data := testData(100, 100)
+		sell.Date = data[1].Date
+		signals := []types.Signal{{Date: data[0].Date, Type: "BUY", Price: 100.0}, sell}
+
+		trades, err := NewEngine(config).executeTrades(signals, data)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(trades) != 1 {
+			t.Fatalf("Expected 1 trade, got %d", len(trades))
+		}
+		return trades[0]
+	}
+
+	// The P&L of a flat trade is the exit fee alone: a rebate for the maker, a cost for the taker
+	maker := exitWith(types.Signal{Type: "SELL", Price: 100.0, OrderType: "limit", LimitPrice: 100.0})
+	if expected := float64(maker.Quantity) * 100 * 0.0002; math.Abs(maker.ProfitLoss-expected) > 1e-9 {
+		t.Errorf("Expected the maker rebate to add $%.2f to the proceeds, got $%.2f", expected, maker.ProfitLoss)
+	}
+
+	taker := exitWith(types.Signal{Type: "SELL", Price: 100.0})
+	if expected := -float64(taker.Quantity) * 100 * 0.001; math.Abs(taker.ProfitLoss-expected) > 1e-9 {
+		t.Errorf("Expected the taker fee to cost $%.2f of the proceeds, got $%.2f", -expected, taker.ProfitLoss)
+	}
+}
+
+func TestExecuteTradesPartialProfitAtRMultiples(t *testing.T) {
+	config := testConfig()
+	config.StrategyConfig.TargetR = 2.0
+	config.StrategyConfig.PartialTargetR = 1.0
+	config.StrategyConfig.PartialFraction = 0.5
+
+	// The 5% stop puts R at $5: +1R at 105 and +2R at 110
+	data := testData(100, 103, 105, 108, 110)
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+	}
+
+	trades, err := NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 2 {
+		t.Fatalf("Expected the position to close in 2 parts, got %d trades", len(trades))
+	}
+
+	// 2% risk over $5 sizes 40 shares, half taken off at +1R and the rest at +2R
+	partial, final := trades[0], trades[1]
+	if partial.Quantity != 20 || math.Abs(*partial.ExitPrice-105.0) > 1e-9 || !partial.ExitDate.Equal(data[2].Date) {
+		t.Errorf("Expected 20 shares closed at 105.00 on %s, got %d at %.2f on %s",
+			data[2].Date.Format("2006-01-02"), partial.Quantity, *partial.ExitPrice, partial.ExitDate.Format("2006-01-02"))
+	}
+	if final.Quantity != 20 || math.Abs(*final.ExitPrice-110.0) > 1e-9 || !final.ExitDate.Equal(data[4].Date) {
+		t.Errorf("Expected 20 shares closed at 110.00 on %s, got %d at %.2f on %s",
+			data[4].Date.Format("2006-01-02"), final.Quantity, *final.ExitPrice, final.ExitDate.Format("2006-01-02"))
+	}
+	if partial.ID != final.ID {
+		t.Errorf("Expected both parts to keep the trade ID, got %s and %s", partial.ID, final.ID)
+	}
+}
+
+func TestExecuteTradesTakeProfitLadder(t *testing.T) {
+	config := testConfig()
+	config.StrategyConfig.TakeProfitLadder = []types.TakeProfitLevel{
+		{Gain: 0.05, Fraction: 0.25},
+		{Gain: 0.10, Fraction: 0.25},
+		{Gain: 0.20, Fraction: 0.50},
+	}
+
+	// Each level fills at its own price on the first bar reaching it: 105, 110 (on the bar
+	// trading up to 111) and 120. The 10% take profit no longer closes the whole position.
+	data := continuousData(100, 103, 105, 108, 111, 115, 120)
+	var signals []types.Signal
+	for _, d := range data {
+		signals = append(signals, types.Signal{Date: d.Date, Type: "HOLD", Price: d.Close})
+	}
+	signals[0].Type = "BUY"
+
+	trades, err := NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 3 {
+		t.Fatalf("Expected one trade per ladder level, got %d trades", len(trades))
+	}
+
+	// 2% risk over $5 sizes 40 shares: 10 at +5%, 10 at +10% and 20 at +20%
+	expected := []struct {
+		quantity int64
+		price    float64
+		bar      int
+	}{{10, 105, 2}, {10, 110, 4}, {20, 120, 6}}
+
+	var exited int64
+	for i, want := range expected {
+		trade := trades[i]
+		if trade.Quantity != want.quantity || math.Abs(*trade.ExitPrice-want.price) > 1e-9 || !trade.ExitDate.Equal(data[want.bar].Date) {
+			t.Errorf("Expected level %d to close %d shares at %.2f on %s, got %d at %.2f on %s", i+1,
+				want.quantity, want.price, data[want.bar].Date.Format("2006-01-02"),
+				trade.Quantity, *trade.ExitPrice, trade.ExitDate.Format("2006-01-02"))
+		}
+		if trade.ExitReason != "take_profit_ladder" || trade.ID != "T1" {
+			t.Errorf("Expected level %d to exit T1 by the ladder, got %s by %s", i+1, trade.ID, trade.ExitReason)
+		}
+		exited += trade.Quantity
+	}
+
+	if exited != 40 {
+		t.Errorf("Expected the ladder to exit all 40 shares, got %d", exited)
+	}
+}
+
+func TestRunRejectsInvalidTakeProfitLadder(t *testing.T) {
+	config := testConfig()
+	config.StrategyConfig.TakeProfitLadder = []types.TakeProfitLevel{
+		{Gain: 0.05, Fraction: 0.5},
+		{Gain: 0.10, Fraction: 0.75},
+	}
+
+	data := testData(make([]float64, 40)...)
+	if _, err := NewEngine(config).Run(data); err == nil || !strings.Contains(err.Error(), "take-profit ladder") {
+		t.Errorf("Expected fractions summing past 1 to be rejected, got %v", err)
+	}
+
+	config.StrategyConfig.TakeProfitLadder = []types.TakeProfitLevel{
+		{Gain: 0.10, Fraction: 0.5},
+		{Gain: 0.05, Fraction: 0.5},
+	}
+	if _, err := NewEngine(config).Run(data); err == nil {
+		t.Error("Expected descending ladder gains to be rejected")
+	}
+}
+
+func TestExecuteTradesStopActivationDelay(t *testing.T) {
+	// The 5% stop sits at 95: the dip on bar 1 breaches it, and so does the drop on bar 3
+	data := continuousData(100, 94, 101, 94, 100)
+	var signals []types.Signal
+	for _, d := range data {
+		signals = append(signals, types.Signal{Date: d.Date, Type: "HOLD", Price: d.Close})
+	}
+	signals[0].Type = "BUY"
+
+	trades, err := NewEngine(testConfig()).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 || !trades[0].ExitDate.Equal(data[1].Date) || trades[0].ExitReason != "stop_loss" {
+		t.Fatalf("Expected the dip to stop out the trade without a delay, got %+v", trades)
+	}
+
+	// Delaying the stop for 3 bars ignores the dip, then stops out on bar 3
+	config := testConfig()
+	config.StrategyConfig.StopActivationDelay = 3
+	trades, err = NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 {
+		t.Fatalf("Expected 1 trade, got %d", len(trades))
+	}
+	if !trades[0].ExitDate.Equal(data[3].Date) || trades[0].ExitReason != "stop_loss" || *trades[0].ExitPrice != 95.0 {
+		t.Errorf("Expected a stop loss at 95.00 on %s once active, got %s at %.2f on %s",
+			data[3].Date.Format("2006-01-02"), trades[0].ExitReason, *trades[0].ExitPrice, trades[0].ExitDate.Format("2006-01-02"))
+	}
+	if trades[0].EntryBar != 0 || trades[0].ExitBar != 3 {
+		t.Errorf("Expected the trade to record bars 0 to 3, got %d to %d", trades[0].EntryBar, trades[0].ExitBar)
+	}
+}
+
+func TestExecuteTradesEquityCurveFilter(t *testing.T) {
+	// The first trade stops out at 94, dropping equity below its 2-bar average. The BUY on
+	// bar 2 comes while it is still below; by bar 3 the average has caught down to it.
+	data := continuousData(100, 94, 96, 97, 99)
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+		{Date: data[1].Date, Type: "HOLD", Price: 94.0},
+		{Date: data[2].Date, Type: "BUY", Price: 96.0},
+		{Date: data[3].Date, Type: "BUY", Price: 97.0},
+		{Date: data[4].Date, Type: "HOLD", Price: 99.0},
+	}
+
+	trades, err := NewEngine(testConfig()).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 2 || !trades[1].EntryDate.Equal(data[2].Date) {
+		t.Fatalf("Expected the unfiltered engine to re-enter on bar 2, got %+v", trades)
+	}
+
+	config := testConfig()
+	config.EquityFilterPeriod = 2
+	trades, err = NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 2 {
+		t.Fatalf("Expected 2 trades, got %d", len(trades))
+	}
+	if trades[0].ExitReason != "stop_loss" {
+		t.Errorf("Expected the first trade to stop out, got %s", trades[0].ExitReason)
+	}
+	if !trades[1].EntryDate.Equal(data[3].Date) {
+		t.Errorf("Expected entries suspended below the equity average and resumed on %s, got an entry on %s",
+			data[3].Date.Format("2006-01-02"), trades[1].EntryDate.Format("2006-01-02"))
+	}
+}
+
+func TestExecuteTradesOrderHook(t *testing.T) {
+	// Four round trips, each proposing a fresh entry
+	data := testData(100, 100, 100, 100, 100, 100, 100, 100)
+	var signals []types.Signal
+	for i, d := range data {
+		signal := types.Signal{Date: d.Date, Type: "BUY", Price: 100.0}
+		if i%2 == 1 {
+			signal.Type = "SELL"
+		}
+		signals = append(signals, signal)
+	}
+
+	// Veto every other proposal and cut the rest to 7 shares
+	var proposals int
+	config := testConfig()
+	config.OrderHook = func(proposed types.Trade) (types.Trade, bool) {
+		proposals++
+		if proposals%2 == 1 {
+			return proposed, false
+		}
+		proposed.Quantity = 7
+		return proposed, true
+	}
+
+	trades, err := NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if proposals != 4 {
+		t.Errorf("Expected the hook to see all 4 proposed entries, got %d", proposals)
+	}
+	if len(trades) != 2 {
+		t.Fatalf("Expected the 2 approved orders to trade, got %d trades", len(trades))
+	}
+	for i, trade := range trades {
+		if want := data[2+4*i].Date; !trade.EntryDate.Equal(want) {
+			t.Errorf("Expected trade %d to enter on %s, got %s", i+1, want.Format("2006-01-02"), trade.EntryDate.Format("2006-01-02"))
+		}
+		if trade.Quantity != 7 {
+			t.Errorf("Expected trade %d to be resized to 7 shares, got %d", i+1, trade.Quantity)
+		}
+		if !trade.SizingClamped || math.Abs(trade.ActualRisk-35) > 1e-9 {
+			t.Errorf("Expected trade %d flagged as resized with $35 actual risk, got %v and $%.2f", i+1, trade.SizingClamped, trade.ActualRisk)
+		}
+	}
+
+	// A hook that tightens the stop moves the target with it
+	config = testConfig()
+	config.StrategyConfig.TargetR = 2
+	config.OrderHook = func(proposed types.Trade) (types.Trade, bool) {
+		proposed.StopLoss = 98
+		return proposed, true
+	}
+	trades, err = NewEngine(config).executeTrades(signals[:2], data[:2])
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 {
+		t.Fatalf("Expected 1 trade, got %d", len(trades))
+	}
+	if trade := trades[0]; math.Abs(trade.TakeProfit-104) > 1e-9 || trade.SizingClamped {
+		t.Errorf("Expected the 2R target repriced to 104 off the 98 stop without a resize, got %.2f and %v", trade.TakeProfit, trade.SizingClamped)
+	}
+}
+
+func TestExecuteTradesPositionLogReconcilesEquity(t *testing.T) {
+	data := testData(100, 102, 99, 104, 106, 103, 108, 110, 107, 109)
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+		{Date: data[2].Date, Type: "HOLD", Price: 99.0},
+		{Date: data[4].Date, Type: "SELL", Price: 106.0},
+		{Date: data[5].Date, Type: "BUY", Price: 103.0},
+		{Date: data[7].Date, Type: "HOLD", Price: 110.0},
+	}
+
+	config := testConfig()
+	config.PositionLog = true
+	engine := NewEngine(config)
+
+	trades, err := engine.executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result := engine.calculateResults(trades, data)
+
+	if len(result.PositionLog) != len(signals) {
+		t.Fatalf("Expected a snapshot at each of the %d signals, got %d", len(signals), len(result.PositionLog))
+	}
+
+	index := make(map[time.Time]int)
+	for i, d := range data {
+		index[d.Date] = i
+	}
+
+	for _, snapshot := range result.PositionLog {
+		i := index[snapshot.Date]
+
+		// Without fees the logged equity matches the equity curve at the bar
+		if math.Abs(snapshot.Equity-result.EquityCurve[i]) > 1e-9 {
+			t.Errorf("Expected equity $%.2f on %s to match the curve, got $%.2f",
+				result.EquityCurve[i], snapshot.Date.Format("2006-01-02"), snapshot.Equity)
+		}
+
+		// And the parts add up to it
+		total := snapshot.Cash
+		for _, position := range snapshot.Positions {
+			if position.MarketValue != float64(position.Quantity)*data[i].Close {
+				t.Errorf("Expected %s marked at the close %.2f, got $%.2f", position.TradeID, data[i].Close, position.MarketValue)
+			}
+			if want := position.MarketValue - float64(position.Quantity)*position.EntryPrice; math.Abs(position.UnrealizedPnL-want) > 1e-9 {
+				t.Errorf("Expected unrealized P&L $%.2f for %s, got $%.2f", want, position.TradeID, position.UnrealizedPnL)
+			}
+			total += position.MarketValue
+		}
+		if math.Abs(total-snapshot.Equity) > 1e-9 {
+			t.Errorf("Expected cash and positions to sum to the equity on %s, got $%.2f vs $%.2f",
+				snapshot.Date.Format("2006-01-02"), total, snapshot.Equity)
+		}
+	}
+
+	// Flat after the SELL, holding again after the second BUY
+	if positions := result.PositionLog[2].Positions; len(positions) != 0 {
+		t.Errorf("Expected no open positions after the sell, got %d", len(positions))
+	}
+	if positions := result.PositionLog[4].Positions; len(positions) != 1 || positions[0].TradeID != "T2" {
+		t.Errorf("Expected T2 open on the last snapshot, got %+v", positions)
+	}
+
+	// Nothing is recorded unless enabled
+	engine = NewEngine(testConfig())
+	trades, _ = engine.executeTrades(signals, data)
+	if log := engine.calculateResults(trades, data).PositionLog; log != nil {
+		t.Errorf("Expected no position log by default, got %d snapshots", len(log))
+	}
+}
+
+func TestExecuteTradesMaxPositionAge(t *testing.T) {
+	// Flat prices never reach the stop or target, and no SELL comes
+	data := testData(100, 100, 100, 100, 100, 100, 100, 100, 100, 100)
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+		{Date: data[3].Date, Type: "HOLD", Price: 100.0},
+	}
+
+	var buf bytes.Buffer
+	config := testConfig()
+	config.MaxPositionAgeDays = 7
+	config.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	trades, err := NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 {
+		t.Fatalf("Expected 1 trade, got %d", len(trades))
+	}
+
+	// Daily bars put the seventh day on bar 7
+	if trades[0].ExitReason != "max_age" || !trades[0].ExitDate.Equal(data[7].Date) {
+		t.Errorf("Expected a max_age close on %s, got %s on %s",
+			data[7].Date.Format("2006-01-02"), trades[0].ExitReason, trades[0].ExitDate.Format("2006-01-02"))
+	}
+
+	logs := buf.String()
+	if !strings.Contains(logs, "level=WARN") || !strings.Contains(logs, "position force-closed at maximum age") || !strings.Contains(logs, "age_days=7") {
+		t.Errorf("Expected a warning for the forced close, got %q", logs)
+	}
+}
+
+func TestExecuteTradesBlackoutSuppressesEntries(t *testing.T) {
+	data := testData(100, 100, 100, 100)
+	config := testConfig()
+	config.Blackouts = []types.DateRange{{Start: data[0].Date, End: data[1].Date}}
+
+	engine := NewEngine(config)
+
+	// A valid entry signal inside the window is ignored
+	trades, err := engine.executeTrades([]types.Signal{{Date: data[1].Date, Type: "BUY", Price: 100.0}}, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 0 {
+		t.Errorf("Expected no entry inside the blackout, got %d trades", len(trades))
+	}
+
+	// The next bar is just outside the window
+	trades, err = engine.executeTrades([]types.Signal{{Date: data[2].Date, Type: "BUY", Price: 100.0}}, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 || !trades[0].EntryDate.Equal(data[2].Date) {
+		t.Errorf("Expected an entry on %s just after the blackout, got %+v", data[2].Date.Format("2006-01-02"), trades)
+	}
+}
+
+func TestExecuteTradesFlattenInBlackout(t *testing.T) {
+	data := testData(100, 101, 102, 103)
+	config := testConfig()
+	config.Blackouts = []types.DateRange{{Start: data[2].Date, End: data[3].Date}}
+	config.FlattenInBlackout = true
+
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+		{Date: data[2].Date, Type: "BUY", Price: 102.0},
+	}
+
+	trades, err := NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 || trades[0].ExitDate == nil || !trades[0].ExitDate.Equal(data[2].Date) {
+		t.Errorf("Expected the position flattened on %s at the start of the blackout, got %+v", data[2].Date.Format("2006-01-02"), trades)
+	}
+}
+
+func TestExecuteTradesSettlementDelay(t *testing.T) {
+	data := testData(100, 100, 100, 100)
+
+	// Sell and buy back on the same day, then try again two bars later
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+		{Date: data[1].Date, Type: "SELL", Price: 100.0},
+		{Date: data[1].Date, Type: "BUY", Price: 100.0},
+		{Date: data[3].Date, Type: "BUY", Price: 100.0},
+	}
+
+	config := testConfig()
+	// Risking half the capital over a 5% stop deploys all of it
+	config.RiskManagementConfig.PositionSize = 0.5
+
+	trades, err := NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 2 || !trades[1].EntryDate.Equal(data[1].Date) {
+		t.Fatalf("Expected a same-day re-entry with immediate settlement, got %+v", trades)
+	}
+
+	config.SettlementDays = 2
+	trades, err = NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 2 {
+		t.Fatalf("Expected 2 trades, got %d", len(trades))
+	}
+
+	// The proceeds of the first sale only fund the entry once settled
+	if !trades[1].EntryDate.Equal(data[3].Date) {
+		t.Errorf("Expected the re-entry blocked until %s, got an entry on %s",
+			data[3].Date.Format("2006-01-02"), trades[1].EntryDate.Format("2006-01-02"))
+	}
+}
+
+func TestExecuteTradesMaxDailyLoss(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2023, 1, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	var data []types.StockData
+	for _, date := range []time.Time{at(2, 10), at(2, 11), at(2, 12), at(2, 13), at(2, 14), at(3, 10)} {
+		data = append(data, types.StockData{Date: date, Open: 100, High: 100, Low: 100, Close: 100})
+	}
+
+	// Two losses of about $200 on the same day, then two more entry attempts
+	signals := []types.Signal{
+		{Date: at(2, 10), Type: "BUY", Price: 100.0},
+		{Date: at(2, 11), Type: "SELL", Price: 95.0},
+		{Date: at(2, 12), Type: "BUY", Price: 100.0},
+		{Date: at(2, 13), Type: "SELL", Price: 95.0},
+		{Date: at(2, 14), Type: "BUY", Price: 100.0},
+		{Date: at(3, 10), Type: "BUY", Price: 100.0},
+	}
+
+	config := testConfig()
+	config.RiskManagementConfig.MaxDailyLoss = 0.03
+
+	trades, err := NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 3 {
+		t.Fatalf("Expected 3 trades, got %d", len(trades))
+	}
+
+	// The first loss alone stays under the $300 limit, so the second entry goes ahead
+	if !trades[1].EntryDate.Equal(at(2, 12)) {
+		t.Errorf("Expected the second entry at %s, got %s", at(2, 12), trades[1].EntryDate)
+	}
+
+	// The afternoon entry is blocked and trading resumes the next day
+	if !trades[2].EntryDate.Equal(at(3, 10)) {
+		t.Errorf("Expected the daily stop to block entries until %s, got an entry at %s", at(3, 10), trades[2].EntryDate)
+	}
+}
+
+func TestCalculateResultsPnLByTag(t *testing.T) {
+	data := testData(100, 110, 100, 90, 100, 105)
+
+	// Two entry rules take turns opening positions
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0, Tag: "bb_rsi"},
+		{Date: data[1].Date, Type: "SELL", Price: 110.0},
+		{Date: data[2].Date, Type: "BUY", Price: 100.0, Tag: "divergence"},
+		{Date: data[3].Date, Type: "SELL", Price: 96.0},
+		{Date: data[4].Date, Type: "BUY", Price: 100.0, Tag: "bb_rsi"},
+		{Date: data[5].Date, Type: "SELL", Price: 105.0},
+	}
+
+	engine := NewEngine(testConfig())
+	trades, err := engine.executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 3 {
+		t.Fatalf("Expected 3 trades, got %d", len(trades))
+	}
+
+	expected := map[string]float64{}
+	for i, tag := range []string{"bb_rsi", "divergence", "bb_rsi"} {
+		if trades[i].Tag != tag {
+			t.Errorf("Expected trade %d tagged %q, got %q", i, tag, trades[i].Tag)
+		}
+		expected[tag] += trades[i].ProfitLoss
+	}
+
+	result := engine.calculateResults(trades, data)
+	if len(result.PnLByTag) != 2 {
+		t.Fatalf("Expected P&L for 2 tags, got %v", result.PnLByTag)
+	}
+	for tag, pnl := range expected {
+		if math.Abs(result.PnLByTag[tag]-pnl) > 1e-9 {
+			t.Errorf("Expected %q P&L of %.2f, got %.2f", tag, pnl, result.PnLByTag[tag])
+		}
+	}
+	if result.PnLByTag["bb_rsi"] <= 0 || result.PnLByTag["divergence"] >= 0 {
+		t.Errorf("Expected bb_rsi to win and divergence to lose, got %v", result.PnLByTag)
+	}
+}
+
+func TestCalculateResultsIntrabarDrawdown(t *testing.T) {
+	// The position dips to a 90 low intrabar but is sold back at its entry price
+	data := testData(100, 100, 100, 100)
+	data[1].Low = 90.0
+	data[2].Low = 95.0
+
+	exitDate := data[3].Date
+	exitPrice := 100.0
+	trades := []types.Trade{{
+		ID:         "T1",
+		EntryDate:  data[0].Date,
+		ExitDate:   &exitDate,
+		EntryPrice: 100.0,
+		ExitPrice:  &exitPrice,
+		Quantity:   50,
+		Status:     "closed",
+	}}
+
+	config := testConfig()
+	result := NewEngine(config).calculateResults(trades, data)
+	if result.MaxDrawdown != 0 {
+		t.Errorf("Expected no close-based drawdown for a breakeven trade, got %.2f%%", result.MaxDrawdown)
+	}
+
+	// 50 shares falling $10 from a $10,000 peak
+	config.DrawdownBasis = "intrabar"
+	result = NewEngine(config).calculateResults(trades, data)
+	if math.Abs(result.MaxDrawdown-5.0) > 1e-9 {
+		t.Errorf("Expected an intrabar drawdown of 5.00%%, got %.2f%%", result.MaxDrawdown)
+	}
+}
+
+func TestCalculateMaxDrawdownDuration(t *testing.T) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2023, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	closed := func(exit time.Time, pnl float64) types.Trade {
+		return types.Trade{ExitDate: &exit, ProfitLoss: pnl, Status: "closed"}
+	}
+
+	// A peak of 10,500 on Jan 10 falls away and is regained on Feb 9, 30 days later. The
+	// Feb 9 peak is lost again on Feb 20 and never recovered.
+	trades := []types.Trade{
+		closed(day(time.January, 10), 500),
+		closed(day(time.January, 20), -300),
+		closed(day(time.January, 25), -200),
+		closed(day(time.February, 9), 600),
+		closed(day(time.February, 20), -100),
+	}
+
+	engine := NewEngine(testConfig())
+	start := day(time.January, 1)
+
+	// Ending on Mar 1, the open drawdown from Feb 9 lasts 20 days, shorter than the recovered one
+	if duration := engine.calculateMaxDrawdownDuration(trades, start, day(time.March, 1)); duration != 30*24*time.Hour {
+		t.Errorf("Expected the recovered 30-day drawdown to be the longest, got %v", duration)
+	}
+
+	// Ending on May 1, the unrecovered drawdown runs 81 days from the Feb 9 peak to the end
+	if duration := engine.calculateMaxDrawdownDuration(trades, start, day(time.May, 1)); duration != 81*24*time.Hour {
+		t.Errorf("Expected the unrecovered drawdown to run 81 days to the end, got %v", duration)
+	}
+
+	// A losing first trade measures from the start, the initial capital being the first peak
+	trades = []types.Trade{closed(day(time.January, 5), -100), closed(day(time.January, 15), 100)}
+	if duration := engine.calculateMaxDrawdownDuration(trades, start, day(time.March, 1)); duration != 14*24*time.Hour {
+		t.Errorf("Expected a 14-day drawdown from the start, got %v", duration)
+	}
+}
+
+func TestFillPriceOrderTypes(t *testing.T) {
+	bar := types.StockData{
+		Date:  time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		Open:  100.0,
+		High:  106.0,
+		Low:   94.0,
+		Close: 102.0,
+	}
+
+	tests := []struct {
+		name     string
+		signal   types.Signal
+		expected float64
+		filled   bool
+	}{
+		{"market", types.Signal{Type: "BUY", Price: 101.0}, 101.0, true},
+		{"explicit market", types.Signal{Type: "BUY", Price: 101.0, OrderType: "market"}, 101.0, true},
+		{"market on close", types.Signal{Type: "BUY", Price: 101.0, OrderType: "moc"}, 102.0, true},
+		{"buy limit reached", types.Signal{Type: "BUY", Price: 101.0, OrderType: "limit", LimitPrice: 96.0}, 96.0, true},
+		{"buy limit above open", types.Signal{Type: "BUY", Price: 101.0, OrderType: "limit", LimitPrice: 103.0}, 100.0, true},
+		{"buy limit below low", types.Signal{Type: "BUY", Price: 101.0, OrderType: "limit", LimitPrice: 90.0}, 0, false},
+		{"sell limit reached", types.Signal{Type: "SELL", Price: 101.0, OrderType: "limit", LimitPrice: 105.0}, 105.0, true},
+		{"sell limit below open", types.Signal{Type: "SELL", Price: 101.0, OrderType: "limit", LimitPrice: 98.0}, 100.0, true},
+		{"sell limit above high", types.Signal{Type: "SELL", Price: 101.0, OrderType: "limit", LimitPrice: 110.0}, 0, false},
+	}
+
+	engine := NewEngine(testConfig())
+	for _, tt := range tests {
+		price, filled := engine.fillPrice(tt.signal, bar)
+		if filled != tt.filled || price != tt.expected {
+			t.Errorf("%s: expected fill %v at %.2f, got %v at %.2f", tt.name, tt.filled, tt.expected, filled, price)
+		}
+	}
+}
+
+func TestExecuteTradesLimitOrderNotFilled(t *testing.T) {
+	data := testData(100, 100)
+	data[0].Low = 98.0
+
+	// The limit is below the bar's low, so no position is opened
+	signals := []types.Signal{{Date: data[0].Date, Type: "BUY", Price: 100.0, OrderType: "limit", LimitPrice: 97.0}}
+	trades, err := NewEngine(testConfig()).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 0 {
+		t.Errorf("Expected no trades from an unfilled limit order, got %d", len(trades))
+	}
+
+	// Reaching the limit fills there
+	signals[0].LimitPrice = 98.5
+	trades, err = NewEngine(testConfig()).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 || trades[0].EntryPrice != 98.5 {
+		t.Errorf("Expected an entry at the 98.50 limit, got %+v", trades)
+	}
+}
+
+func TestExecuteTradesFlatAtWeekEnd(t *testing.T) {
+	// Thursday and Friday, then Monday and Tuesday of the following week
+	data := testData(100, 101, 102, 103)
+	for i, date := range []time.Time{
+		time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 6, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 9, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC),
+	} {
+		data[i].Date = date
+	}
+
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+		{Date: data[2].Date, Type: "BUY", Price: 102.0},
+	}
+
+	config := testConfig()
+	trades, err := NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 {
+		t.Fatalf("Expected the position held over the weekend without the rule, got %d trades", len(trades))
+	}
+
+	config.FlatAtWeekEnd = true
+	trades, err = NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 2 {
+		t.Fatalf("Expected 2 trades, got %d", len(trades))
+	}
+
+	// Flattened at Friday's close, then reopened on Monday's signal
+	if !trades[0].ExitDate.Equal(data[1].Date) || *trades[0].ExitPrice != 101.0 {
+		t.Errorf("Expected the first trade closed on Friday at 101.00, got %s at %.2f",
+			trades[0].ExitDate.Format("2006-01-02"), *trades[0].ExitPrice)
+	}
+	if trades[0].ExitReason != "week_end" {
+		t.Errorf("Expected the week_end exit reason, got %q", trades[0].ExitReason)
+	}
+	if !trades[1].EntryDate.Equal(data[2].Date) {
+		t.Errorf("Expected a new entry on Monday, got %s", trades[1].EntryDate.Format("2006-01-02"))
+	}
+}
+
+func TestCalculateResultsRoundToCents(t *testing.T) {
+	// Alternate entries and exits at awkward prices with fees and slippage
+	closes := make([]float64, 400)
+	for i := range closes {
+		closes[i] = 100 + float64(i%37)*0.173
+	}
+	data := testData(closes...)
+
+	var signals []types.Signal
+	for i := 0; i+1 < len(data); i += 2 {
+		signals = append(signals,
+			types.Signal{Date: data[i].Date, Type: "BUY", Price: data[i].Close},
+			types.Signal{Date: data[i+1].Date, Type: "SELL", Price: data[i+1].Close})
+	}
+
+	subCent := func(amount float64) bool {
+		cents := amount * 100
+		return math.Abs(cents-math.Round(cents)) > 1e-6
+	}
+
+	config := testConfig()
+	config.TradeFee = 0.001
+	config.Slippage = 0.0013
+
+	engine := NewEngine(config)
+	trades, err := engine.executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) < 100 {
+		t.Fatalf("Expected at least 100 trades, got %d", len(trades))
+	}
+
+	residue := false
+	for _, trade := range trades {
+		residue = residue || subCent(trade.ProfitLoss)
+	}
+	if !residue {
+		t.Fatalf("Expected sub-cent P&L without rounding")
+	}
+
+	config.RoundToCents = true
+	engine = NewEngine(config)
+	trades, err = engine.executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, trade := range trades {
+		if subCent(trade.ProfitLoss) {
+			t.Errorf("Expected trade %s P&L in whole cents, got %v", trade.ID, trade.ProfitLoss)
+		}
+	}
+
+	result := engine.calculateResults(trades, data)
+	if result.FinalCapital != math.Round(result.FinalCapital*100)/100 {
+		t.Errorf("Expected final capital in whole cents, got %v", result.FinalCapital)
+	}
+	if result.TotalProfitLoss != math.Round(result.TotalProfitLoss*100)/100 {
+		t.Errorf("Expected total P&L in whole cents, got %v", result.TotalProfitLoss)
+	}
+}
+
+func TestExecuteTradesVolumeSlippage(t *testing.T) {
+	config := testConfig()
+	config.Slippage = 0.001
+	config.SlippageModel = "volume"
+	config.VolumeImpact = 0.1
+
+	entryPrice := func(high, low float64, volume int64) (float64, int64) {
+		data := testData(100, 100)
+		data[0].High = high
+		data[0].Low = low
+		data[0].Volume = volume
+
+		signals := []types.Signal{{Date: data[0].Date, Type: "BUY", Price: 100.0}}
+		trades, err := NewEngine(config).executeTrades(signals, data)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(trades) != 1 {
+			t.Fatalf("Expected 1 trade, got %d", len(trades))
+		}
+		return trades[0].EntryPrice, trades[0].Quantity
+	}
+
+	liquid, liquidShares := entryPrice(100.5, 99.5, 1000000)
+	thin, thinShares := entryPrice(104, 96, 400)
+
+	if liquidShares != thinShares {
+		t.Fatalf("Expected the same order size on both bars, got %d and %d", liquidShares, thinShares)
+	}
+
+	// The liquid bar pays 0.1% + half of a 0.1% spread + a negligible impact
+	shares := float64(liquidShares)
+	expectedLiquid := 100 * (1 + 0.001 + 0.0005 + 0.1*shares/1000000)
+	if math.Abs(liquid-expectedLiquid) > 1e-9 {
+		t.Errorf("Expected liquid fill at %.6f, got %.6f", expectedLiquid, liquid)
+	}
+
+	// The thin bar pays 0.1% + half of a 0.8% spread + an impact on about 10% of volume
+	expectedThin := 100 * (1 + 0.001 + 0.004 + 0.1*shares/400)
+	if math.Abs(thin-expectedThin) > 1e-9 {
+		t.Errorf("Expected thin fill at %.6f, got %.6f", expectedThin, thin)
+	}
+
+	if thin <= liquid {
+		t.Errorf("Expected more slippage on the thin bar, got %.4f vs %.4f", thin, liquid)
+	}
+}
+
+func TestExecuteTradesPartialFills(t *testing.T) {
+	config := testConfig()
+	config.InitialCapital = 100000
+	config.MaxVolumeParticipation = 0.1
+
+	// 2% risk over a 5% stop sizes 400 shares, which 10% of these volumes fills over four bars
+	data := testData(100, 101, 102, 103, 103, 103)
+	for i, volume := range []int64{1500, 1000, 500, 3000, 3000, 3000} {
+		data[i].Volume = volume
+	}
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+		{Date: data[4].Date, Type: "HOLD", Price: 103.0},
+	}
+
+	engine := NewEngine(config)
+	trades, err := engine.executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 {
+		t.Fatalf("Expected 1 trade, got %d", len(trades))
+	}
+
+	trade := trades[0]
+	if trade.Quantity != 400 || trade.EntryBar != 0 || trade.FillBar != 3 {
+		t.Fatalf("Expected 400 shares filled from bar 0 to bar 3, got %d from bar %d to bar %d",
+			trade.Quantity, trade.EntryBar, trade.FillBar)
+	}
+
+	// 150 at 100, 100 at 101, 50 at 102 and the last 100 at 103
+	vwap := (150*100.0 + 100*101.0 + 50*102.0 + 100*103.0) / 400
+	if math.Abs(trade.EntryPrice-vwap) > 1e-9 {
+		t.Errorf("Expected the volume-weighted entry price %.4f, got %.4f", vwap, trade.EntryPrice)
+	}
+
+	// The stop keeps its $5 distance below the averaged entry
+	if math.Abs(trade.StopLoss-(vwap-5)) > 1e-9 {
+		t.Errorf("Expected the stop at %.4f, got %.4f", vwap-5, trade.StopLoss)
+	}
+
+	expectedPL := 400 * (103 - vwap)
+	if trade.ExitReason != "end_of_data" || math.Abs(trade.ProfitLoss-expectedPL) > 1e-9 {
+		t.Errorf("Expected the end-of-data close for $%.2f, got %s for $%.2f", expectedPL, trade.ExitReason, trade.ProfitLoss)
+	}
+
+	// Only the shares filled by each bar are marked: 250 costing $25100 at 101 on bar 1
+	// and 300 costing $30200 at 102 on bar 2
+	equity := engine.calculateEquityCurve(trades, data)
+	if math.Abs(equity[1]-100150) > 1e-9 || math.Abs(equity[2]-100400) > 1e-9 {
+		t.Errorf("Expected equity of $100150 on bar 1 and $100400 on bar 2, got $%.2f and $%.2f", equity[1], equity[2])
+	}
+}
+
+func TestExecuteTradesMaxOpenPositions(t *testing.T) {
+	data := testData(100, 99, 98, 99, 101)
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+		{Date: data[1].Date, Type: "BUY", Price: 99.0},
+		{Date: data[2].Date, Type: "BUY", Price: 98.0},
+		{Date: data[3].Date, Type: "BUY", Price: 99.0},
+		{Date: data[4].Date, Type: "SELL", Price: 101.0},
+	}
+
+	config := testConfig()
+	config.MaxOpenPositions = 3
+	engine := NewEngine(config)
+	trades, err := engine.executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Three entries on bars 0 to 2, the fourth BUY finds the limit reached, and the SELL closes them all
+	if len(trades) != 3 {
+		t.Fatalf("Expected 3 trades, got %d", len(trades))
+	}
+	totalCost, totalPL := 0.0, 0.0
+	for i, trade := range trades {
+		if !trade.EntryDate.Equal(data[i].Date) {
+			t.Errorf("Expected trade %d to enter on %s, got %s", i, data[i].Date.Format("2006-01-02"), trade.EntryDate.Format("2006-01-02"))
+		}
+		if trade.ExitReason != "signal" || !trade.ExitDate.Equal(data[4].Date) {
+			t.Errorf("Expected trade %d closed by the SELL on bar 4, got %s", i, trade.ExitReason)
+		}
+		totalCost += float64(trade.Quantity) * trade.EntryPrice
+		totalPL += trade.ProfitLoss
+	}
+
+	// Each entry is sized from the cash left by the ones before it
+	if totalCost > config.InitialCapital {
+		t.Errorf("Expected the entries to fit the capital, got $%.2f invested", totalCost)
+	}
+	if trades[1].Quantity >= trades[0].Quantity || trades[2].Quantity >= trades[1].Quantity {
+		t.Errorf("Expected smaller entries as cash is used, got %d, %d and %d shares",
+			trades[0].Quantity, trades[1].Quantity, trades[2].Quantity)
+	}
+
+	// Equity is back to cash once all positions are closed
+	equity := engine.calculateEquityCurve(trades, data)
+	if math.Abs(equity[4]-(config.InitialCapital+totalPL)) > 1e-9 {
+		t.Errorf("Expected final equity $%.2f, got $%.2f", config.InitialCapital+totalPL, equity[4])
+	}
+
+	// The default keeps a single position
+	trades, err = NewEngine(testConfig()).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 {
+		t.Errorf("Expected 1 trade by default, got %d", len(trades))
+	}
+}
+
+func TestExecuteTradesShortTakeProfit(t *testing.T) {
+	config := testConfig()
+	config.AllowShorts = true
+
+	data := continuousData(100, 97, 93, 89, 89, 92)
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "SELL", Price: 100.0},
+		{Date: data[1].Date, Type: "HOLD", Price: 97.0},
+		{Date: data[3].Date, Type: "HOLD", Price: 89.0},
+		{Date: data[5].Date, Type: "BUY", Price: 92.0},
+	}
+
+	engine := NewEngine(config)
+	trades, err := engine.executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The closing BUY opens a new long, so the short is the first trade
+	if len(trades) != 2 {
+		t.Fatalf("Expected the short and a long closed at the end, got %d trades", len(trades))
+	}
+
+	// 2% risk over a 5% stop sizes 40 shares, with the stop above the entry and the target below
+	short := trades[0]
+	if short.Direction != "short" || short.Quantity != 40 {
+		t.Fatalf("Expected a 40-share short, got %d shares %q", short.Quantity, short.Direction)
+	}
+	if math.Abs(short.StopLoss-105) > 1e-9 || math.Abs(short.TakeProfit-90) > 1e-9 {
+		t.Errorf("Expected the stop at 105 and the target at 90, got %.2f and %.2f", short.StopLoss, short.TakeProfit)
+	}
+
+	// The fall to 89 reaches the target and buys back at 90, 10 points below the entry
+	if short.ExitReason != "take_profit" || !short.ExitDate.Equal(data[3].Date) {
+		t.Fatalf("Expected the take profit on %s, got %s", data[3].Date.Format("2006-01-02"), short.ExitReason)
+	}
+	if math.Abs(short.ProfitLoss-400) > 1e-9 {
+		t.Errorf("Expected a $400 profit, got $%.2f", short.ProfitLoss)
+	}
+
+	// While open, the short gains as the price falls
+	equity := engine.calculateEquityCurve(trades, data)
+	if math.Abs(equity[1]-10120) > 1e-9 || math.Abs(equity[4]-10400) > 1e-9 {
+		t.Errorf("Expected equity of $10120 on bar 1 and $10400 once covered, got $%.2f and $%.2f", equity[1], equity[4])
+	}
+
+	if long := trades[1]; long.Direction != "long" || !long.EntryDate.Equal(data[5].Date) {
+		t.Errorf("Expected the BUY to open a long when flat, got %q on %s", long.Direction, long.EntryDate.Format("2006-01-02"))
+	}
+}
+
+func TestExecuteTradesShortStopLoss(t *testing.T) {
+	config := testConfig()
+	config.AllowShorts = true
+
+	data := continuousData(100, 103, 106, 104)
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "SELL", Price: 100.0},
+		{Date: data[1].Date, Type: "HOLD", Price: 103.0},
+		{Date: data[2].Date, Type: "HOLD", Price: 106.0},
+	}
+
+	engine := NewEngine(config)
+	trades, err := engine.executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 {
+		t.Fatalf("Expected 1 trade, got %d", len(trades))
+	}
+
+	// The rise through the stop at 105 buys back at the stop, losing 5 points on 40 shares
+	trade := trades[0]
+	if trade.Direction != "short" || trade.ExitReason != "stop_loss" || !trade.ExitDate.Equal(data[2].Date) {
+		t.Fatalf("Expected the short stopped out on %s, got %q closed by %s",
+			data[2].Date.Format("2006-01-02"), trade.Direction, trade.ExitReason)
+	}
+	if math.Abs(trade.ProfitLoss+200) > 1e-9 {
+		t.Errorf("Expected a $200 loss, got $%.2f", trade.ProfitLoss)
+	}
+	if !engine.stoppedOut {
+		t.Error("Expected the stop-out to be recorded")
+	}
+
+	// Without AllowShorts the SELL has nothing to close and opens nothing
+	trades, err = NewEngine(testConfig()).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 0 {
+		t.Errorf("Expected no trades with shorts disabled, got %d", len(trades))
+	}
+}
+
+func TestExecuteTradesReentryAfterStop(t *testing.T) {
+	data := continuousData(100, 94, 93, 92, 91, 92, 90, 95)
+
+	// The entry condition holds through the intrabar stop-out on bar 1 until bar 3, lapses
+	// on bars 4 and 5 and recurs on bar 6. With re-entry allowed, the BUY at bar 1's close
+	// re-enters straight after the stop.
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+		{Date: data[1].Date, Type: "BUY", Price: 94.0},
+		{Date: data[2].Date, Type: "BUY", Price: 93.0},
+		{Date: data[3].Date, Type: "BUY", Price: 92.0},
+		{Date: data[6].Date, Type: "BUY", Price: 90.0},
+	}
+
+	tests := []struct {
+		reentry bool
+		entry   time.Time
+	}{
+		{true, data[1].Date},
+		{false, data[6].Date},
+	}
+
+	for _, tt := range tests {
+		config := testConfig()
+		config.FreshEntryAfterStop = !tt.reentry
+
+		trades, err := NewEngine(config).executeTrades(signals, data)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(trades) != 2 {
+			t.Fatalf("Re-entry %v: expected 2 trades, got %d", tt.reentry, len(trades))
+		}
+		if trades[0].ExitReason != "stop_loss" || !trades[0].ExitDate.Equal(data[1].Date) {
+			t.Errorf("Re-entry %v: expected the first trade stopped out on bar 1, got %s on %s",
+				tt.reentry, trades[0].ExitReason, trades[0].ExitDate.Format("2006-01-02"))
+		}
+		if !trades[1].EntryDate.Equal(tt.entry) {
+			t.Errorf("Re-entry %v: expected the second entry on %s, got %s",
+				tt.reentry, tt.entry.Format("2006-01-02"), trades[1].EntryDate.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestRunRejectsDuplicateDates(t *testing.T) {
+	data := testData(signalTestCloses...)
+	data[5].Date = data[4].Date
+
+	_, err := NewEngine(signalTestConfig()).Run(data)
+	if err == nil || !strings.Contains(err.Error(), "duplicate bars dated 2023-01-06") {
+		t.Errorf("Expected the repeated date to be rejected, got %v", err)
+	}
+}
+
+func TestRunRejectsNaNResults(t *testing.T) {
+	closes := append([]float64(nil), signalTestCloses...)
+	data := testData(closes...)
+	data[len(data)/2].Close = math.NaN()
+
+	_, err := NewEngine(signalTestConfig()).Run(data)
+	if err == nil {
+		t.Fatal("Expected an error for a NaN price")
+	}
+	if !strings.Contains(err.Error(), "is NaN") || !strings.Contains(err.Error(), "BacktestResult.") {
+		t.Errorf("Expected the error to name the NaN result field, got %v", err)
+	}
+
+	// Clean data still produces a result
+	if _, err := NewEngine(signalTestConfig()).Run(testData(signalTestCloses...)); err != nil {
+		t.Errorf("Unexpected error on clean data: %v", err)
+	}
+}
+
+func TestValidateResultNamesField(t *testing.T) {
+	exitPrice := math.Inf(1)
+	result := &types.BacktestResult{
+		FinalCapital: 10000,
+		Trades:       []types.Trade{{ID: "T1"}, {ID: "T2", ExitPrice: &exitPrice}},
+	}
+
+	err := validateResult(result)
+	if err == nil || !strings.Contains(err.Error(), "BacktestResult.Trades[1].ExitPrice is +Inf") {
+		t.Errorf("Expected the infinite exit price to be reported, got %v", err)
+	}
+
+	exitPrice = 101
+	if err := validateResult(result); err != nil {
+		t.Errorf("Unexpected error for finite values: %v", err)
+	}
+}
+
+func TestExecuteTradesRecordsRisk(t *testing.T) {
+	data := testData(100, 110)
+	signals := []types.Signal{
+		{Date: data[0].Date, Type: "BUY", Price: 100.0},
+		{Date: data[1].Date, Type: "SELL", Price: 110.0},
+	}
+
+	trades, err := NewEngine(testConfig()).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 {
+		t.Fatalf("Expected 1 trade, got %d", len(trades))
+	}
+
+	// 2% of 10000 is 200 intended, with $5 of risk per share rounding down to whole shares
+	trade := trades[0]
+	if trade.CapitalAtEntry != 10000 {
+		t.Errorf("Expected capital at entry of 10000, got %.2f", trade.CapitalAtEntry)
+	}
+	if math.Abs(trade.IntendedRisk-200) > 1e-9 {
+		t.Errorf("Expected intended risk of 200, got %.2f", trade.IntendedRisk)
+	}
+	perShare := trade.EntryPrice - trade.StopLoss
+	if math.Abs(trade.ActualRisk-perShare*float64(trade.Quantity)) > 1e-9 {
+		t.Errorf("Expected actual risk of %.2f, got %.2f", perShare*float64(trade.Quantity), trade.ActualRisk)
+	}
+	if trade.ActualRisk > trade.IntendedRisk || trade.IntendedRisk-trade.ActualRisk >= perShare {
+		t.Errorf("Expected actual risk within one share of the intended 200, got %.2f", trade.ActualRisk)
+	}
+	if trade.SizingClamped {
+		t.Error("Expected unclamped sizing")
+	}
+
+	// A share cap overrides the risk sizing
+	config := testConfig()
+	config.RiskManagementConfig.MaxShares = 10
+	trades, err = NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !trades[0].SizingClamped || trades[0].ActualRisk >= trades[0].IntendedRisk/2 {
+		t.Errorf("Expected clamped sizing well under the intended risk, got %+v", trades[0])
+	}
+}
+
+func TestExecuteTradesSkipLeadingAndTrailing(t *testing.T) {
+	data := testData(100, 100, 100, 101, 102, 103, 104, 105)
+	signals := []types.Signal{
+		{Date: data[1].Date, Type: "BUY", Price: 100.0},
+		{Date: data[3].Date, Type: "BUY", Price: 101.0},
+		{Date: data[7].Date, Type: "SELL", Price: 105.0},
+	}
+
+	// Without skipping the first BUY enters and the SELL closes the trade on the last bar
+	trades, err := NewEngine(testConfig()).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 || !trades[0].EntryDate.Equal(data[1].Date) || trades[0].ExitReason != "signal" {
+		t.Fatalf("Expected one trade from bar 1 closed by the SELL, got %+v", trades)
+	}
+
+	config := testConfig()
+	config.SkipLeading = 3
+	config.SkipTrailing = 2
+	trades, err = NewEngine(config).executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(trades) != 1 {
+		t.Fatalf("Expected 1 trade, got %d", len(trades))
+	}
+
+	// The entry waits for the first bar after the leading region
+	trade := trades[0]
+	if !trade.EntryDate.Equal(data[3].Date) {
+		t.Errorf("Expected no entry before bar 3, got one on %s", trade.EntryDate.Format("2006-01-02"))
+	}
+
+	// The SELL in the trailing region is ignored and the close happens on bar 5
+	if !trade.ExitDate.Equal(data[5].Date) || trade.ExitReason != "end_of_data" || *trade.ExitPrice != 103.0 {
+		t.Errorf("Expected the end-of-data close at 103 on %s, got %s at %.2f on %s",
+			data[5].Date.Format("2006-01-02"), trade.ExitReason, *trade.ExitPrice, trade.ExitDate.Format("2006-01-02"))
+	}
+}
+
+// firstBarStrategy buys a fixed number of shares on the first bar and prices a fixed
+// percentage stop and target, implementing only the Strategy interface
+type firstBarStrategy struct{}
+
+func (firstBarStrategy) GenerateSignals(data []types.StockData) []types.Signal {
+	return []types.Signal{{Date: data[0].Date, Type: "BUY", Price: data[0].Close, Reason: "First bar"}}
+}
+
+func (firstBarStrategy) CalculatePositionSize(availableCapital, currentPrice, stopLossPrice float64, riskConfig types.RiskManagementConfig) int64 {
+	return 10
+}
+
+func (firstBarStrategy) GetStopLossPrice(entryPrice float64) float64 {
+	return entryPrice * 0.9
+}
+
+func (firstBarStrategy) GetTakeProfitPrice(entryPrice float64) float64 {
+	return entryPrice * 1.1
+}
+
+func TestRunWithCustomStrategy(t *testing.T) {
+	engine := NewEngineWithStrategy(testConfig(), firstBarStrategy{})
+
+	// Without a warm-up the strategy can signal on the first of only a few bars
+	result, err := engine.Run(continuousData(100, 105, 112, 108))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(result.Trades) != 1 {
+		t.Fatalf("Expected 1 trade, got %d", len(result.Trades))
+	}
+
+	trade := result.Trades[0]
+	if !trade.EntryDate.Equal(time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected entry on the first bar, got %s", trade.EntryDate.Format("2006-01-02"))
+	}
+	if trade.Quantity != 10 {
+		t.Errorf("Expected the strategy's 10 shares, got %d", trade.Quantity)
+	}
+	if trade.ExitPrice == nil || trade.ExitReason != "take_profit" || math.Abs(*trade.ExitPrice-110) > 1e-9 {
+		t.Fatalf("Expected a take profit at the strategy's 110 target, got %+v", trade)
+	}
+}
+
+func TestRunRebalanceStrategyHoldsTargetWeight(t *testing.T) {
+	// Rises from 100 to 150, then falls back to 100
+	var closes []float64
+	for i := 0; i <= 50; i++ {
+		closes = append(closes, 100+float64(i))
+	}
+	for i := 1; i <= 50; i++ {
+		closes = append(closes, 150-float64(i))
+	}
+	data := testData(closes...)
+
+	config := testConfig()
+	rebalance := strategy.NewRebalanceStrategy(strategy.RebalanceConfig{TargetWeight: 0.5, Frequency: 10, InitialCapital: config.InitialCapital})
+	result, err := NewEngineWithStrategy(config, rebalance).Run(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Trims are recorded against the one position the adds build up
+	var trims int
+	for _, trade := range result.Trades {
+		if trade.ID != "T1" {
+			t.Errorf("Expected every rebalance to trade the one position, got trade %s", trade.ID)
+		}
+		if trade.ExitReason == "signal" {
+			trims++
+		}
+	}
+	if trims == 0 {
+		t.Error("Expected the uptrend to trim the position")
+	}
+
+	// After each rebalance the holding is within one share of half the equity, up to the
+	// last bar where the position is closed out
+	for i := 0; i < len(data)-1; i += 10 {
+		var shares int64
+		for _, trade := range result.Trades {
+			if !data[i].Date.Before(trade.EntryDate) && (trade.ExitDate == nil || data[i].Date.Before(*trade.ExitDate)) {
+				shares += heldPosition(trade, data[i].Date).Quantity
+			}
+		}
+
+		equity := result.EquityCurve[i]
+		weight := float64(shares) * closes[i] / equity
+		if math.Abs(weight-0.5) > closes[i]/equity {
+			t.Errorf("Expected a weight near 0.50 after rebalancing on bar %d, got %.4f (%d shares of $%.2f equity)", i, weight, shares, equity)
+		}
+	}
+}
+
+func TestRunVotingStrategy(t *testing.T) {
+	// Chops sideways, falls far enough for RSI to vote oversold, then rallies until it votes
+	// overbought
+	var closes []float64
+	for i := 0; i < 20; i++ {
+		closes = append(closes, 100+0.2*float64(i%2))
+	}
+	for i := 1; i <= 4; i++ {
+		closes = append(closes, 100-float64(i))
+	}
+	for i := 1; i <= 8; i++ {
+		closes = append(closes, 96+float64(i))
+	}
+	data := testData(closes...)
+
+	config := testConfig()
+	config.StrategyConfig.Strategy = "voting"
+	config.StrategyConfig.VoteWeights = types.VoteWeights{RSI: 1}
+	config.StrategyConfig.VoteThreshold = 0.4
+	config.StrategyConfig.StochasticPeriod = 14
+	config.StrategyConfig.CCIPeriod = 20
+
+	result, err := NewEngine(config).Run(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(result.Trades) == 0 {
+		t.Fatal("Expected the oversold vote to open a trade")
+	}
+
+	// The entry is priced and sized like any other strategy's
+	trade := result.Trades[0]
+	if trade.Tag != "vote" {
+		t.Errorf("Expected the trade to be tagged vote, got %q", trade.Tag)
+	}
+	if expected := trade.EntryPrice * 0.95; math.Abs(trade.StopLoss-expected) > 1e-9 {
+		t.Errorf("Expected a 5%% stop at %.4f, got %.4f", expected, trade.StopLoss)
+	}
+	if trade.Quantity <= 0 {
+		t.Errorf("Expected a sized position, got %d shares", trade.Quantity)
+	}
+	if trade.ExitReason != "signal" {
+		t.Errorf("Expected the overbought vote to close the trade, got exit reason %q", trade.ExitReason)
+	}
+}
@@ -0,0 +1,65 @@
+package backtesting
+
+import (
+	"fmt"
+	"swing-trader/internal/types"
+)
+
+// RunMultiStrategy backtests several strategies side by side on the same data. Each
+// strategy gets a bucket of its Fraction of the configured initial capital and sizes and
+// funds its trades from that bucket alone, so one strategy cannot starve another and the
+// buckets together never use more than the total capital. Fractions must be positive and
+// sum to at most 1; any remainder stays in cash.
+func RunMultiStrategy(config types.BacktestConfig, allocations []types.StrategyAllocation, data []types.StockData) (*types.MultiStrategyResult, error) {
+	if len(allocations) == 0 {
+		return nil, fmt.Errorf("no strategies to run")
+	}
+
+	total := 0.0
+	names := make(map[string]bool)
+	for _, allocation := range allocations {
+		if allocation.Name == "" {
+			return nil, fmt.Errorf("strategy allocation needs a name")
+		}
+		if names[allocation.Name] {
+			return nil, fmt.Errorf("duplicate strategy name %q", allocation.Name)
+		}
+		names[allocation.Name] = true
+
+		if allocation.Fraction <= 0 {
+			return nil, fmt.Errorf("strategy %q needs a positive capital fraction, got %v", allocation.Name, allocation.Fraction)
+		}
+		total += allocation.Fraction
+	}
+	if total > 1+1e-9 {
+		return nil, fmt.Errorf("capital fractions sum to %v, more than the total capital", total)
+	}
+
+	combined := &types.MultiStrategyResult{
+		Results:        make(map[string]*types.BacktestResult),
+		InitialCapital: config.InitialCapital,
+		FinalCapital:   config.InitialCapital,
+	}
+
+	for _, allocation := range allocations {
+		bucket := config
+		bucket.InitialCapital = config.InitialCapital * allocation.Fraction
+		bucket.StrategyConfig = allocation.Config
+		bucket.StrategyConfig.InitialCapital = bucket.InitialCapital
+
+		result, err := NewEngine(bucket).Run(data)
+		if err != nil {
+			return nil, fmt.Errorf("strategy %q: %w", allocation.Name, err)
+		}
+
+		combined.Results[allocation.Name] = result
+		combined.TotalProfitLoss += result.TotalProfitLoss
+		combined.FinalCapital += result.TotalProfitLoss
+	}
+
+	if combined.InitialCapital > 0 {
+		combined.TotalReturn = combined.TotalProfitLoss / combined.InitialCapital * 100
+	}
+
+	return combined, nil
+}
@@ -0,0 +1,107 @@
+package backtesting
+
+import (
+	"math"
+	"strings"
+	"swing-trader/internal/types"
+	"testing"
+)
+
+func TestRunMultiStrategyRespectsAllocations(t *testing.T) {
+	var closes []float64
+	for i := 0; i < 3; i++ {
+		closes = append(closes, signalTestCloses...)
+	}
+	data := testData(closes...)
+
+	// Both strategies would risk far more than their buckets, so the capital limit binds
+	config := signalTestConfig()
+	config.RiskManagementConfig.PositionSize = 1.0
+
+	wide := config.StrategyConfig
+	wide.BBStdDev = 1.0
+	allocations := []types.StrategyAllocation{
+		{Name: "tight", Config: config.StrategyConfig, Fraction: 0.6},
+		{Name: "wide", Config: wide, Fraction: 0.4},
+	}
+
+	combined, err := RunMultiStrategy(config, allocations, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(combined.Results) != 2 {
+		t.Fatalf("Expected results for 2 strategies, got %d", len(combined.Results))
+	}
+
+	// Capital deployed by each strategy on each bar against the capital of its bucket,
+	// which grows or shrinks only with that strategy's own realized P&L
+	deployed := make(map[string][]float64)
+	capital := make(map[string][]float64)
+	for _, allocation := range allocations {
+		result := combined.Results[allocation.Name]
+		bucket := config.InitialCapital * allocation.Fraction
+		if result.TotalTrades == 0 {
+			t.Fatalf("Expected %s to trade", allocation.Name)
+		}
+		if result.InitialCapital != bucket {
+			t.Errorf("Expected %s to start with its %.2f bucket, got %.2f", allocation.Name, bucket, result.InitialCapital)
+		}
+
+		usage := make([]float64, len(data))
+		available := make([]float64, len(data))
+		for i := range available {
+			available[i] = bucket
+		}
+		for _, trade := range result.Trades {
+			for i, bar := range data {
+				if !bar.Date.Before(trade.EntryDate) && bar.Date.Before(*trade.ExitDate) {
+					usage[i] += trade.EntryPrice * float64(trade.Quantity)
+				}
+				if bar.Date.After(*trade.ExitDate) {
+					available[i] += trade.ProfitLoss
+				}
+			}
+		}
+
+		for _, trade := range result.Trades {
+			i := int(trade.EntryDate.Sub(data[0].Date).Hours() / 24)
+			if math.Abs(trade.CapitalAtEntry-available[i]) > 1e-6 {
+				t.Errorf("Expected %s to size against its bucket of %.2f on bar %d, got %.2f", allocation.Name, available[i], i, trade.CapitalAtEntry)
+			}
+		}
+		for i, used := range usage {
+			if used > available[i] {
+				t.Errorf("Expected %s to use at most %.2f, used %.2f on bar %d", allocation.Name, available[i], used, i)
+			}
+		}
+		deployed[allocation.Name] = usage
+		capital[allocation.Name] = available
+	}
+
+	for i := range data {
+		used := deployed["tight"][i] + deployed["wide"][i]
+		total := capital["tight"][i] + capital["wide"][i]
+		if used > total {
+			t.Errorf("Expected combined usage within the total capital of %.2f, used %.2f on bar %d", total, used, i)
+		}
+	}
+
+	pnl := combined.Results["tight"].TotalProfitLoss + combined.Results["wide"].TotalProfitLoss
+	if math.Abs(combined.TotalProfitLoss-pnl) > 1e-9 || math.Abs(combined.FinalCapital-(config.InitialCapital+pnl)) > 1e-9 {
+		t.Errorf("Expected combined P&L %.2f and final capital %.2f, got %.2f and %.2f",
+			pnl, config.InitialCapital+pnl, combined.TotalProfitLoss, combined.FinalCapital)
+	}
+}
+
+func TestRunMultiStrategyRejectsOverAllocation(t *testing.T) {
+	config := signalTestConfig()
+	allocations := []types.StrategyAllocation{
+		{Name: "a", Config: config.StrategyConfig, Fraction: 0.7},
+		{Name: "b", Config: config.StrategyConfig, Fraction: 0.5},
+	}
+
+	_, err := RunMultiStrategy(config, allocations, testData(signalTestCloses...))
+	if err == nil || !strings.Contains(err.Error(), "more than the total capital") {
+		t.Errorf("Expected an over-allocation error, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package backtesting
+
+import (
+	"swing-trader/internal/types"
+	"time"
+)
+
+// applyPerformanceFees charges PerformanceFeeRate on gains above the high-water mark at the
+// end of each fee period, deducting the fees from the equity curve in place and returning
+// their total. The mark starts at the initial capital, moves with deposits and withdrawals,
+// and rises to the equity after each fee, so a recovery that only wins back earlier losses
+// is not charged again.
+func (e *Engine) applyPerformanceFees(equity []float64, data []types.StockData) float64 {
+	rate := e.config.PerformanceFeeRate
+	if rate <= 0 || len(equity) == 0 {
+		return 0
+	}
+
+	cashFlows := e.cashFlowsByBar(data)
+	highWaterMark := e.config.InitialCapital
+	totalFees := 0.0
+
+	for i := range equity {
+		highWaterMark += cashFlows[i]
+		equity[i] -= totalFees
+
+		periodEnd := i == len(equity)-1 || e.feePeriod(data[i].Date) != e.feePeriod(data[i+1].Date)
+		if !periodEnd || equity[i] <= highWaterMark {
+			continue
+		}
+
+		fee := e.roundMoney((equity[i] - highWaterMark) * rate)
+		totalFees += fee
+		equity[i] -= fee
+		highWaterMark = equity[i]
+	}
+
+	return totalFees
+}
+
+// feePeriod identifies the performance fee period containing date for the configured
+// frequency: "monthly", "quarterly" or "annual" (the default)
+func (e *Engine) feePeriod(date time.Time) int {
+	switch e.config.PerformanceFeePeriod {
+	case "monthly":
+		return date.Year()*12 + int(date.Month()) - 1
+	case "quarterly":
+		return date.Year()*4 + (int(date.Month())-1)/3
+	default:
+		return date.Year()
+	}
+}
+
+// calculateHighWaterMark returns the highest equity reached up to each point of the curve
+func calculateHighWaterMark(equity []float64) []float64 {
+	marks := make([]float64, len(equity))
+
+	for i, value := range equity {
+		marks[i] = value
+		if i > 0 && marks[i-1] > value {
+			marks[i] = marks[i-1]
+		}
+	}
+
+	return marks
+}
@@ -0,0 +1,97 @@
+package backtesting
+
+import (
+	"math"
+	"swing-trader/internal/types"
+	"testing"
+)
+
+func TestPerformanceFeeOnlyOnNewHighs(t *testing.T) {
+	// Five months of daily bars from 2023-01-02, with the equity held at each month's closing value
+	closes := make([]float64, 150)
+	for i := range closes {
+		closes[i] = 100
+	}
+	data := testData(closes...)
+
+	monthEquity := map[int]float64{1: 11000, 2: 10000, 3: 10700, 4: 11800, 5: 11800}
+	equity := make([]float64, len(data))
+	for i, d := range data {
+		equity[i] = monthEquity[int(d.Date.Month())]
+	}
+
+	config := testConfig()
+	config.PerformanceFeeRate = 0.2
+	config.PerformanceFeePeriod = "monthly"
+	engine := NewEngine(config)
+
+	fees := engine.applyPerformanceFees(equity, data)
+
+	// January's $1000 gain pays $200, raising the high-water mark to $10800. March's recovery
+	// to $10500 net stays below it, and April's new high pays 20% of the $800 above it.
+	if math.Abs(fees-360) > 1e-9 {
+		t.Errorf("Expected $360 of performance fees, got $%.2f", fees)
+	}
+
+	for i, d := range data {
+		var expected float64
+		switch d.Date.Month() {
+		case 1:
+			expected = 11000
+			if d.Date.Day() == 31 {
+				expected = 10800
+			}
+		case 2:
+			expected = 9800
+		case 3:
+			expected = 10500
+		case 4:
+			expected = 11600
+			if d.Date.Day() == 30 {
+				expected = 11440
+			}
+		default:
+			expected = 11440
+		}
+		if math.Abs(equity[i]-expected) > 1e-9 {
+			t.Errorf("Expected net equity $%.2f on %s, got $%.2f", expected, d.Date.Format("2006-01-02"), equity[i])
+		}
+	}
+
+	marks := calculateHighWaterMark(equity)
+	if marks[70] != 11000 || marks[len(marks)-1] != 11600 {
+		t.Errorf("Expected high-water marks of $11000 in March and $11600 at the end, got $%.2f and $%.2f",
+			marks[70], marks[len(marks)-1])
+	}
+}
+
+func TestPerformanceFeeReducesFinalCapital(t *testing.T) {
+	// One winning trade, charged once at the end of the annual fee period
+	data := testData(100, 104, 108, 111, 111)
+	signals := []types.Signal{{Date: data[0].Date, Type: "BUY", Price: 100.0}}
+
+	config := testConfig()
+	config.PerformanceFeeRate = 0.2
+	engine := NewEngine(config)
+
+	trades, err := engine.executeTrades(signals, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result := engine.calculateResults(trades, data)
+
+	if result.TotalProfitLoss <= 0 {
+		t.Fatalf("Expected a winning trade, got P&L of $%.2f", result.TotalProfitLoss)
+	}
+
+	expectedFee := result.TotalProfitLoss * 0.2
+	if math.Abs(result.PerformanceFees-expectedFee) > 1e-9 {
+		t.Errorf("Expected a performance fee of $%.2f, got $%.2f", expectedFee, result.PerformanceFees)
+	}
+
+	expectedFinal := config.InitialCapital + result.TotalProfitLoss - expectedFee
+	if math.Abs(result.FinalCapital-expectedFinal) > 1e-9 || math.Abs(result.EquityCurve[len(data)-1]-expectedFinal) > 1e-9 {
+		t.Errorf("Expected final capital and equity of $%.2f, got $%.2f and $%.2f",
+			expectedFinal, result.FinalCapital, result.EquityCurve[len(data)-1])
+	}
+}
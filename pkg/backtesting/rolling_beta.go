@@ -0,0 +1,70 @@
+package backtesting
+
+import (
+	"math"
+)
+
+// defaultBetaWindow is the rolling window, in bars, for beta and correlation when the
+// config does not set one (roughly a quarter of daily bars)
+const defaultBetaWindow = 63
+
+// calculateRollingBeta computes the beta and correlation of the strategy's per-bar returns
+// against the benchmark's over a rolling window of returns. Both series are aligned with
+// the equity curves and are zero until window returns are available, i.e. before bar
+// index window. A window with a flat benchmark has zero beta, and one where either curve
+// is flat has zero correlation.
+func calculateRollingBeta(equity, benchmark []float64, window int) (beta, correlation []float64) {
+	n := len(equity)
+	if len(benchmark) < n {
+		n = len(benchmark)
+	}
+	beta = make([]float64, n)
+	correlation = make([]float64, n)
+	if window < 2 {
+		return beta, correlation
+	}
+
+	strategyReturns := barReturns(equity[:n])
+	benchmarkReturns := barReturns(benchmark[:n])
+
+	for i := window; i < n; i++ {
+		s := strategyReturns[i-window+1 : i+1]
+		b := benchmarkReturns[i-window+1 : i+1]
+
+		var meanS, meanB float64
+		for j := range s {
+			meanS += s[j]
+			meanB += b[j]
+		}
+		meanS /= float64(window)
+		meanB /= float64(window)
+
+		var covariance, varS, varB float64
+		for j := range s {
+			covariance += (s[j] - meanS) * (b[j] - meanB)
+			varS += (s[j] - meanS) * (s[j] - meanS)
+			varB += (b[j] - meanB) * (b[j] - meanB)
+		}
+
+		if varB > 0 {
+			beta[i] = covariance / varB
+		}
+		if varS > 0 && varB > 0 {
+			correlation[i] = covariance / math.Sqrt(varS*varB)
+		}
+	}
+
+	return beta, correlation
+}
+
+// barReturns computes simple returns aligned with the curve, zero for the first bar and
+// after a non-positive value
+func barReturns(curve []float64) []float64 {
+	returns := make([]float64, len(curve))
+	for i := 1; i < len(curve); i++ {
+		if curve[i-1] > 0 {
+			returns[i] = curve[i]/curve[i-1] - 1
+		}
+	}
+	return returns
+}
@@ -0,0 +1,61 @@
+package backtesting
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalculateRollingBetaRegimes(t *testing.T) {
+	// The benchmark alternates up and down moves throughout
+	benchmark := []float64{100}
+	for i := 1; i < 60; i++ {
+		move := 0.02
+		if i%2 == 0 {
+			move = -0.015
+		}
+		benchmark = append(benchmark, benchmark[i-1]*(1+move))
+	}
+
+	// The strategy tracks the benchmark for the first 30 bars, then sits in cash
+	equity := make([]float64, len(benchmark))
+	for i := range benchmark {
+		if i <= 30 {
+			equity[i] = benchmark[i] * 50
+		} else {
+			equity[i] = equity[30]
+		}
+	}
+
+	window := 10
+	beta, correlation := calculateRollingBeta(equity, benchmark, window)
+
+	if len(beta) != len(equity) || len(correlation) != len(equity) {
+		t.Fatalf("Expected series aligned with %d bars, got %d and %d", len(equity), len(beta), len(correlation))
+	}
+
+	for i := 0; i < window; i++ {
+		if beta[i] != 0 || correlation[i] != 0 {
+			t.Errorf("Expected zero during warm-up at index %d, got beta %.4f and correlation %.4f", i, beta[i], correlation[i])
+		}
+	}
+
+	// Invested regime: identical returns
+	for i := window; i <= 30; i++ {
+		if math.Abs(beta[i]-1) > 1e-9 || math.Abs(correlation[i]-1) > 1e-9 {
+			t.Errorf("Expected beta and correlation of 1 at index %d, got %.4f and %.4f", i, beta[i], correlation[i])
+		}
+	}
+
+	// Cash regime: once the window holds only flat returns there is no market exposure
+	for i := 30 + window; i < len(equity); i++ {
+		if math.Abs(beta[i]) > 1e-9 || math.Abs(correlation[i]) > 1e-9 {
+			t.Errorf("Expected beta and correlation of 0 at index %d, got %.4f and %.4f", i, beta[i], correlation[i])
+		}
+	}
+
+	// Straddling the switch, beta falls between the regimes
+	mid := 30 + window/2
+	if beta[mid] <= 0 || beta[mid] >= 1 {
+		t.Errorf("Expected beta between 0 and 1 across the regime change at index %d, got %.4f", mid, beta[mid])
+	}
+}
@@ -0,0 +1,78 @@
+package backtesting
+
+import (
+	"fmt"
+	"swing-trader/internal/types"
+	"time"
+)
+
+// dataWindow is one window of a rolling-window report with the bars inside it
+type dataWindow struct {
+	start time.Time
+	end   time.Time
+	data  []types.StockData
+}
+
+// rollingWindows splits the data into windows months long, starting every step months from
+// the first day of the first bar's month, so 12-month windows with a 12-month step are
+// calendar years. Each window holds the bars on or after its start and before its end; the
+// last windows can run past the data and hold fewer bars.
+func rollingWindows(data []types.StockData, months, step int) []dataWindow {
+	if len(data) == 0 || months <= 0 || step <= 0 {
+		return nil
+	}
+
+	first := data[0].Date
+	last := data[len(data)-1].Date
+
+	var windows []dataWindow
+	for n := 0; ; n++ {
+		start := time.Date(first.Year(), first.Month()+time.Month(n*step), 1, 0, 0, 0, 0, first.Location())
+		if start.After(last) {
+			break
+		}
+		end := start.AddDate(0, months, 0)
+
+		var bars []types.StockData
+		for _, bar := range data {
+			if !bar.Date.Before(start) && bar.Date.Before(end) {
+				bars = append(bars, bar)
+			}
+		}
+
+		windows = append(windows, dataWindow{start: start, end: end, data: bars})
+	}
+
+	return windows
+}
+
+// RunRollingWindows backtests the config separately on each window of the data months long,
+// starting every step months (see rollingWindows), for a table of out-of-sample results by
+// period without any optimization. Each window starts with the full initial capital and no
+// positions. Windows with too few bars for the strategy to warm up are skipped with a warning.
+func RunRollingWindows(config types.BacktestConfig, data []types.StockData, months, step int) ([]types.WindowResult, error) {
+	if months <= 0 || step <= 0 {
+		return nil, fmt.Errorf("window and step must be positive, got %d and %d months", months, step)
+	}
+
+	var results []types.WindowResult
+	for _, window := range rollingWindows(data, months, step) {
+		engine := NewEngine(config)
+		if len(window.data) < engine.minDataPoints() {
+			engine.logger.Warn("rolling window skipped with too few bars",
+				"start", window.start.Format("2006-01-02"),
+				"bars", len(window.data),
+				"required", engine.minDataPoints())
+			continue
+		}
+
+		result, err := engine.Run(window.data)
+		if err != nil {
+			return nil, fmt.Errorf("window from %s: %w", window.start.Format("2006-01-02"), err)
+		}
+
+		results = append(results, types.WindowResult{Start: window.start, End: window.end, Result: result})
+	}
+
+	return results, nil
+}
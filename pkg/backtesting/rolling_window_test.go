@@ -0,0 +1,93 @@
+package backtesting
+
+import (
+	"testing"
+	"time"
+
+	"swing-trader/internal/types"
+)
+
+// multiYearData builds daily bars from 2021-01-04 to 2024-01-02 repeating the signal
+// test pattern, so every year trades
+func multiYearData() []types.StockData {
+	var data []types.StockData
+	date := time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	for i := 0; date.Before(time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)); i++ {
+		price := signalTestCloses[i%len(signalTestCloses)]
+		data = append(data, types.StockData{Date: date, Open: price, High: price, Low: price, Close: price})
+		date = date.AddDate(0, 0, 1)
+	}
+	return data
+}
+
+func TestRollingWindowsBoundaries(t *testing.T) {
+	data := multiYearData()
+	year := func(y int) time.Time { return time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	// 12-month windows a year apart are the calendar years, the last holding only the
+	// two bars of 2024
+	windows := rollingWindows(data, 12, 12)
+	if len(windows) != 4 {
+		t.Fatalf("Expected 4 calendar-year windows, got %d", len(windows))
+	}
+	for i, window := range windows {
+		start, end := year(2021+i), year(2022+i)
+		if !window.start.Equal(start) || !window.end.Equal(end) {
+			t.Errorf("Expected window %d to span %s to %s, got %s to %s", i, start.Format("2006-01-02"),
+				end.Format("2006-01-02"), window.start.Format("2006-01-02"), window.end.Format("2006-01-02"))
+		}
+		if len(window.data) == 0 || window.data[0].Date.Before(start) || !window.data[len(window.data)-1].Date.Before(end) {
+			t.Errorf("Expected window %d to hold only bars from %s to before %s", i, start.Format("2006-01-02"), end.Format("2006-01-02"))
+		}
+	}
+
+	// Each bar falls in exactly one window of a non-overlapping split
+	total := 0
+	for _, window := range windows {
+		total += len(window.data)
+	}
+	if total != len(data) {
+		t.Errorf("Expected the windows to hold all %d bars once, got %d", len(data), total)
+	}
+	if first := windows[1].data[0].Date; !first.Equal(year(2022)) {
+		t.Errorf("Expected the 2022 window to start on its first bar, got %s", first.Format("2006-01-02"))
+	}
+
+	// Stepping 6 months overlaps the windows, starting every January and July
+	windows = rollingWindows(data, 12, 6)
+	if len(windows) != 7 {
+		t.Fatalf("Expected 7 half-year-stepped windows, got %d", len(windows))
+	}
+	if start := windows[1].start; !start.Equal(time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the second window to start on 2021-07-01, got %s", start.Format("2006-01-02"))
+	}
+}
+
+func TestRunRollingWindows(t *testing.T) {
+	data := multiYearData()
+	config := signalTestConfig()
+
+	// The two-bar 2024 window is too short for the strategy to warm up and is skipped
+	results, err := RunRollingWindows(config, data, 12, 12)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected results for 2021 to 2023, got %d windows", len(results))
+	}
+
+	for i, window := range results {
+		year := 2021 + i
+		if window.Start.Year() != year || window.Result.StartDate.Year() != year || window.Result.EndDate.Year() != year {
+			t.Errorf("Expected window %d to backtest %d alone, got %s to %s", i, year,
+				window.Result.StartDate.Format("2006-01-02"), window.Result.EndDate.Format("2006-01-02"))
+		}
+		if window.Result.InitialCapital != config.InitialCapital || window.Result.TotalTrades == 0 {
+			t.Errorf("Expected window %d to trade from the full initial capital, got %+v", i, window.Result.InitialCapital)
+		}
+	}
+
+	if _, err := RunRollingWindows(config, data, 0, 12); err == nil {
+		t.Error("Expected a zero window length to be rejected")
+	}
+}
@@ -0,0 +1,26 @@
+package data
+
+import (
+	"swing-trader/internal/types"
+)
+
+// AlignByDate returns the bars of a and b on the dates present in both, in the order of a,
+// so that aAligned[i] and bAligned[i] share a date. Dates are compared as instants, so the
+// same moment in different locations matches.
+func AlignByDate(a, b []types.StockData) (aAligned, bAligned []types.StockData) {
+	byDate := make(map[int64]types.StockData, len(b))
+	for _, d := range b {
+		byDate[d.Date.UnixNano()] = d
+	}
+
+	for _, d := range a {
+		match, ok := byDate[d.Date.UnixNano()]
+		if !ok {
+			continue
+		}
+		aAligned = append(aAligned, d)
+		bAligned = append(bAligned, match)
+	}
+
+	return aAligned, bAligned
+}
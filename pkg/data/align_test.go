@@ -0,0 +1,60 @@
+package data
+
+import (
+	"swing-trader/internal/types"
+	"testing"
+	"time"
+)
+
+func TestAlignByDate(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	// a covers Jan 2-6 without Jan 4, b covers Jan 4-9 without Jan 7
+	a := []types.StockData{
+		{Date: day(2), Close: 1},
+		{Date: day(3), Close: 2},
+		{Date: day(5), Close: 3},
+		{Date: day(6), Close: 4},
+	}
+	b := []types.StockData{
+		{Date: day(4), Close: 10},
+		{Date: day(5), Close: 20},
+		{Date: day(6), Close: 30},
+		{Date: day(8), Close: 40},
+		{Date: day(9), Close: 50},
+	}
+
+	aAligned, bAligned := AlignByDate(a, b)
+
+	if len(aAligned) != 2 || len(bAligned) != 2 {
+		t.Fatalf("Expected 2 common dates, got %d and %d", len(aAligned), len(bAligned))
+	}
+
+	expected := []struct {
+		date   time.Time
+		aClose float64
+		bClose float64
+	}{
+		{day(5), 3, 20},
+		{day(6), 4, 30},
+	}
+
+	for i, e := range expected {
+		if !aAligned[i].Date.Equal(e.date) || !bAligned[i].Date.Equal(e.date) {
+			t.Errorf("Expected both series on %s at index %d, got %s and %s", e.date.Format("2006-01-02"), i,
+				aAligned[i].Date.Format("2006-01-02"), bAligned[i].Date.Format("2006-01-02"))
+		}
+		if aAligned[i].Close != e.aClose || bAligned[i].Close != e.bClose {
+			t.Errorf("Expected closes %.0f and %.0f at index %d, got %.0f and %.0f", e.aClose, e.bClose, i,
+				aAligned[i].Close, bAligned[i].Close)
+		}
+	}
+
+	// No overlap leaves both empty
+	aAligned, bAligned = AlignByDate(a[:2], b)
+	if len(aAligned) != 0 || len(bAligned) != 0 {
+		t.Errorf("Expected no common dates, got %d and %d", len(aAligned), len(bAligned))
+	}
+}
@@ -0,0 +1,104 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"swing-trader/internal/types"
+	"time"
+)
+
+// alphaVantageBaseURL is the Alpha Vantage query endpoint
+const alphaVantageBaseURL = "https://www.alphavantage.co/query"
+
+// alphaVantageResponse is the TIME_SERIES_DAILY_ADJUSTED payload, including the
+// fields the API uses in place of data for errors and rate limiting
+type alphaVantageResponse struct {
+	TimeSeries   map[string]map[string]string `json:"Time Series (Daily)"`
+	ErrorMessage string                       `json:"Error Message"`
+	Note         string                       `json:"Note"`
+	Information  string                       `json:"Information"`
+}
+
+// FetchAlphaVantage downloads daily adjusted stock data for a symbol from Alpha Vantage
+func FetchAlphaVantage(symbol, apiKey string) ([]types.StockData, error) {
+	return fetchAlphaVantage(alphaVantageBaseURL, symbol, apiKey)
+}
+
+// fetchAlphaVantage downloads daily adjusted stock data from the given base URL
+func fetchAlphaVantage(baseURL, symbol, apiKey string) ([]types.StockData, error) {
+	params := url.Values{}
+	params.Set("function", "TIME_SERIES_DAILY_ADJUSTED")
+	params.Set("symbol", symbol)
+	params.Set("outputsize", "full")
+	params.Set("apikey", apiKey)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(baseURL + "?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Alpha Vantage data for %s: %w", symbol, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Alpha Vantage request for %s failed with status %s", symbol, resp.Status)
+	}
+
+	var payload alphaVantageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode Alpha Vantage response: %w", err)
+	}
+
+	// The API reports errors and rate limits with a 200 status and a message field
+	switch {
+	case payload.ErrorMessage != "":
+		return nil, fmt.Errorf("Alpha Vantage error for %s: %s", symbol, payload.ErrorMessage)
+	case payload.Note != "":
+		return nil, fmt.Errorf("Alpha Vantage rate limit for %s: %s", symbol, payload.Note)
+	case payload.Information != "":
+		return nil, fmt.Errorf("Alpha Vantage request for %s was rejected: %s", symbol, payload.Information)
+	case len(payload.TimeSeries) == 0:
+		return nil, fmt.Errorf("Alpha Vantage returned no daily data for %s", symbol)
+	}
+
+	stockData := make([]types.StockData, 0, len(payload.TimeSeries))
+	for dateStr, values := range payload.TimeSeries {
+		date, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse date %s: %w", dateStr, err)
+		}
+
+		var fields [5]float64
+		for i, key := range []string{"1. open", "2. high", "3. low", "4. close", "5. adjusted close"} {
+			fields[i], err = strconv.ParseFloat(values[key], 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s on %s: %w", key, dateStr, err)
+			}
+		}
+
+		volume, err := strconv.ParseInt(values["6. volume"], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse volume on %s: %w", dateStr, err)
+		}
+
+		stockData = append(stockData, types.StockData{
+			Date:          date,
+			Open:          fields[0],
+			High:          fields[1],
+			Low:           fields[2],
+			Close:         fields[3],
+			AdjustedClose: fields[4],
+			Volume:        volume,
+		})
+	}
+
+	// Sort data chronologically (oldest first)
+	sort.Slice(stockData, func(i, j int) bool {
+		return stockData[i].Date.Before(stockData[j].Date)
+	})
+
+	return stockData, nil
+}
@@ -0,0 +1,114 @@
+package data
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const alphaVantageDailyFixture = `{
+    "Meta Data": {
+        "1. Information": "Daily Time Series with Splits and Dividend Events",
+        "2. Symbol": "IBM",
+        "3. Last Refreshed": "2024-01-04",
+        "4. Output Size": "Full size",
+        "5. Time Zone": "US/Eastern"
+    },
+    "Time Series (Daily)": {
+        "2024-01-04": {
+            "1. open": "160.7",
+            "2. high": "161.8",
+            "3. low": "159.95",
+            "4. close": "160.1",
+            "5. adjusted close": "158.52",
+            "6. volume": "4176208",
+            "7. dividend amount": "0.0000",
+            "8. split coefficient": "1.0"
+        },
+        "2024-01-02": {
+            "1. open": "162.83",
+            "2. high": "163.29",
+            "3. low": "160.38",
+            "4. close": "162.7",
+            "5. adjusted close": "161.09",
+            "6. volume": "3993843",
+            "7. dividend amount": "0.0000",
+            "8. split coefficient": "1.0"
+        },
+        "2024-01-03": {
+            "1. open": "161.0",
+            "2. high": "161.73",
+            "3. low": "160.08",
+            "4. close": "160.96",
+            "5. adjusted close": "159.37",
+            "6. volume": "4083564",
+            "7. dividend amount": "0.0000",
+            "8. split coefficient": "1.0"
+        }
+    }
+}`
+
+func TestFetchAlphaVantage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("function") != "TIME_SERIES_DAILY_ADJUSTED" || query.Get("symbol") != "IBM" || query.Get("apikey") != "demo" {
+			t.Errorf("Unexpected query %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(alphaVantageDailyFixture))
+	}))
+	defer server.Close()
+
+	stockData, err := fetchAlphaVantage(server.URL, "IBM", "demo")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(stockData) != 3 {
+		t.Fatalf("Expected 3 data points, got %d", len(stockData))
+	}
+
+	// Sorted oldest first
+	expectedDates := []string{"2024-01-02", "2024-01-03", "2024-01-04"}
+	for i, expected := range expectedDates {
+		if got := stockData[i].Date.Format("2006-01-02"); got != expected {
+			t.Errorf("Expected date %s at index %d, got %s", expected, i, got)
+		}
+	}
+
+	first := stockData[0]
+	if first.Open != 162.83 || first.High != 163.29 || first.Low != 160.38 || first.Close != 162.7 ||
+		first.AdjustedClose != 161.09 || first.Volume != 3993843 {
+		t.Errorf("Unexpected values for first data point: %+v", first)
+	}
+	if !first.Date.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected UTC date, got %v", first.Date)
+	}
+}
+
+func TestFetchAlphaVantageErrorResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{"invalid symbol", `{"Error Message": "Invalid API call."}`, "Invalid API call."},
+		{"rate limit", `{"Note": "Thank you for using Alpha Vantage! Our standard API call frequency is 5 calls per minute."}`, "rate limit"},
+		{"premium endpoint", `{"Information": "This is a premium endpoint."}`, "premium endpoint"},
+		{"empty series", `{"Time Series (Daily)": {}}`, "no daily data"},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(tt.body))
+		}))
+
+		_, err := fetchAlphaVantage(server.URL, "IBM", "demo")
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.expected, err)
+		}
+
+		server.Close()
+	}
+}
@@ -115,8 +115,8 @@ func LoadStockDataFromCSV(filePath string) ([]types.StockData, error) {
 		})
 	}
 
-	// Sort data chronologically (oldest first)
-	sort.Slice(stockData, func(i, j int) bool {
+	// Sort data chronologically (oldest first), keeping rows with the same date in file order
+	sort.SliceStable(stockData, func(i, j int) bool {
 		return stockData[i].Date.Before(stockData[j].Date)
 	})
 
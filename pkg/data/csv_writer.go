@@ -0,0 +1,47 @@
+package data
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"swing-trader/internal/types"
+)
+
+// WriteStockDataToCSV writes stock data to a CSV file in the same Yahoo layout read by
+// LoadStockDataFromCSV, with dates in YYYY-MM-DD format
+func WriteStockDataToCSV(data []types.StockData, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+
+	if err := writer.Write([]string{"Date", "Open", "High", "Low", "Close", "Adj Close", "Volume"}); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for i, d := range data {
+		record := []string{
+			d.Date.Format("2006-01-02"),
+			strconv.FormatFloat(d.Open, 'f', -1, 64),
+			strconv.FormatFloat(d.High, 'f', -1, 64),
+			strconv.FormatFloat(d.Low, 'f', -1, 64),
+			strconv.FormatFloat(d.Close, 'f', -1, 64),
+			strconv.FormatFloat(d.AdjustedClose, 'f', -1, 64),
+			strconv.FormatInt(d.Volume, 10),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV row %d: %w", i+1, err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV data: %w", err)
+	}
+
+	return file.Close()
+}
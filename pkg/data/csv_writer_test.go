@@ -0,0 +1,58 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteStockDataToCSVRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	input := filepath.Join(dir, "input.csv")
+	csvData := "Date,Open,High,Low,Close,AdjClose,Volume\n" +
+		"Jul 2 2025,209.08,213.34,208.14,212.44,212.44,66327031\n" +
+		"Jul 1 2025,206.67,210.19,206.14,207.82,207.82,78788900\n" +
+		"Jun 30 2025,202.01,207.39,199.26,205.17,205.17,-\n"
+	if err := os.WriteFile(input, []byte(csvData), 0644); err != nil {
+		t.Fatalf("Failed to write fixture: %v", err)
+	}
+
+	original, err := LoadStockDataFromCSV(input)
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+
+	output := filepath.Join(dir, "output.csv")
+	if err := WriteStockDataToCSV(original, output); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	written, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read written CSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(written)), "\n")
+	if lines[0] != "Date,Open,High,Low,Close,Adj Close,Volume" {
+		t.Errorf("Unexpected header %q", lines[0])
+	}
+	if lines[1] != "2025-06-30,202.01,207.39,199.26,205.17,205.17,0" {
+		t.Errorf("Unexpected first row %q", lines[1])
+	}
+
+	reloaded, err := LoadStockDataFromCSV(output)
+	if err != nil {
+		t.Fatalf("Failed to reload written CSV: %v", err)
+	}
+
+	if len(reloaded) != len(original) {
+		t.Fatalf("Expected %d rows after round trip, got %d", len(original), len(reloaded))
+	}
+
+	for i := range original {
+		if !reloaded[i].Date.Equal(original[i].Date) || reloaded[i] != original[i] {
+			t.Errorf("Row %d changed in round trip: %+v -> %+v", i, original[i], reloaded[i])
+		}
+	}
+}
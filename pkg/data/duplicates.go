@@ -0,0 +1,36 @@
+package data
+
+import (
+	"fmt"
+	"log/slog"
+	"swing-trader/internal/types"
+)
+
+// ResolveDuplicateDates handles bars sharing a date, e.g. rows exported both before and
+// after an adjustment, which would otherwise overwrite each other in lookups by date. The
+// policy "error" rejects them, while "first" and "last" keep the earliest or latest of each
+// set of duplicates in the input order (for a loaded CSV, the file order) and log a warning.
+// Any other policy uses "last". The data must be sorted by date.
+func ResolveDuplicateDates(data []types.StockData, policy string) ([]types.StockData, error) {
+	var resolved []types.StockData
+
+	for _, bar := range data {
+		n := len(resolved)
+		if n == 0 || !resolved[n-1].Date.Equal(bar.Date) {
+			resolved = append(resolved, bar)
+			continue
+		}
+
+		switch policy {
+		case "error":
+			return nil, fmt.Errorf("duplicate bars dated %s", bar.Date.Format("2006-01-02"))
+		case "first":
+			// The bar already kept stays
+		default:
+			resolved[n-1] = bar
+		}
+		slog.Warn("duplicate bar date, keeping one", "date", bar.Date.Format("2006-01-02"), "policy", policy)
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,59 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveDuplicateDates(t *testing.T) {
+	// Jul 1 appears twice, before and after an adjustment, in a newest-first export
+	input := filepath.Join(t.TempDir(), "duplicates.csv")
+	csvData := "Date,Open,High,Low,Close,AdjClose,Volume\n" +
+		"Jul 2 2025,209.08,213.34,208.14,212.44,212.44,66327031\n" +
+		"Jul 1 2025,206.67,210.19,206.14,207.82,207.82,78788900\n" +
+		"Jul 1 2025,103.34,105.10,103.07,103.91,103.91,157577800\n" +
+		"Jun 30 2025,202.01,207.39,199.26,205.17,205.17,-\n"
+	if err := os.WriteFile(input, []byte(csvData), 0644); err != nil {
+		t.Fatalf("Failed to write fixture: %v", err)
+	}
+
+	loaded, err := LoadStockDataFromCSV(input)
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+
+	// Loading keeps both rows, in file order, for the policy to choose between
+	if len(loaded) != 4 || loaded[1].Close != 207.82 || loaded[2].Close != 103.91 {
+		t.Fatalf("Expected both Jul 1 rows in file order, got %+v", loaded)
+	}
+
+	if _, err := ResolveDuplicateDates(loaded, "error"); err == nil {
+		t.Error("Expected the error policy to reject the duplicate date")
+	}
+
+	tests := []struct {
+		policy string
+		close  float64
+	}{
+		{"first", 207.82},
+		{"last", 103.91},
+		{"", 103.91},
+	}
+
+	for _, tt := range tests {
+		resolved, err := ResolveDuplicateDates(loaded, tt.policy)
+		if err != nil {
+			t.Fatalf("Policy %q: unexpected error: %v", tt.policy, err)
+		}
+		if len(resolved) != 3 {
+			t.Fatalf("Policy %q: expected one bar per date, got %d bars", tt.policy, len(resolved))
+		}
+		if resolved[1].Close != tt.close {
+			t.Errorf("Policy %q: expected the Jul 1 close of %.2f, got %.2f", tt.policy, tt.close, resolved[1].Close)
+		}
+		if resolved[0].Close != 205.17 || resolved[2].Close != 212.44 {
+			t.Errorf("Policy %q: expected the other dates untouched, got %+v", tt.policy, resolved)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"swing-trader/internal/types"
+	"time"
+)
+
+// influxEscaper escapes the characters with meaning in line protocol measurements, tag keys,
+// tag values and field keys
+var influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// InfluxLine formats the result's summary metrics as one InfluxDB line-protocol point in the
+// measurement, tagged with tags (e.g. the symbol and strategy parameters) in sorted key order
+// and timestamped at the end of the backtest in nanoseconds
+func InfluxLine(measurement string, tags map[string]string, result *types.BacktestResult) string {
+	var line strings.Builder
+	line.WriteString(influxEscaper.Replace(measurement))
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		// Line protocol does not allow empty tag values, so such tags are left out
+		if tags[key] == "" {
+			continue
+		}
+		fmt.Fprintf(&line, ",%s=%s", influxEscaper.Replace(key), influxEscaper.Replace(tags[key]))
+	}
+
+	fields := []string{
+		floatField("total_return", result.TotalReturn),
+		floatField("annualized_return", result.AnnualizedReturn),
+		floatField("sharpe_ratio", result.SharpeRatio),
+		floatField("max_drawdown", result.MaxDrawdown),
+		floatField("win_rate", result.WinRate),
+		floatField("total_profit_loss", result.TotalProfitLoss),
+		floatField("final_capital", result.FinalCapital),
+		fmt.Sprintf("total_trades=%di", result.TotalTrades),
+		fmt.Sprintf("winning_trades=%di", result.WinningTrades),
+		fmt.Sprintf("losing_trades=%di", result.LosingTrades),
+	}
+
+	fmt.Fprintf(&line, " %s %d", strings.Join(fields, ","), result.EndDate.UnixNano())
+	return line.String()
+}
+
+// floatField formats a float field with the shortest exact decimal representation
+func floatField(key string, value float64) string {
+	return key + "=" + strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// WriteInflux sends line-protocol lines to destination. An http:// or https:// destination
+// is an InfluxDB write endpoint, e.g. http://localhost:8086/api/v2/write?org=me&bucket=backtests&precision=ns,
+// posted to with the token as its Authorization header when one is given. Any other
+// destination is a file the lines are appended to.
+func WriteInflux(destination, token string, lines ...string) error {
+	body := strings.Join(lines, "\n") + "\n"
+
+	if !strings.HasPrefix(destination, "http://") && !strings.HasPrefix(destination, "https://") {
+		file, err := os.OpenFile(destination, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open line protocol file %s: %w", destination, err)
+		}
+		if _, err := file.WriteString(body); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write line protocol to %s: %w", destination, err)
+		}
+		return file.Close()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, destination, bytes.NewBufferString(body))
+	if err != nil {
+		return fmt.Errorf("failed to create InfluxDB write request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to write to InfluxDB: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// InfluxDB answers a successful write with 204 No Content
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("InfluxDB write failed with status %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package export
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"swing-trader/internal/types"
+	"testing"
+	"time"
+)
+
+func sampleResult() *types.BacktestResult {
+	return &types.BacktestResult{
+		TotalReturn:      12.5,
+		AnnualizedReturn: 6.25,
+		SharpeRatio:      1.1,
+		MaxDrawdown:      8.75,
+		WinRate:          60,
+		TotalProfitLoss:  1250,
+		FinalCapital:     11250,
+		TotalTrades:      10,
+		WinningTrades:    6,
+		LosingTrades:     4,
+		EndDate:          time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestInfluxLine(t *testing.T) {
+	tags := map[string]string{
+		"symbol":    "AAPL",
+		"bb_period": "20",
+		"params":    "rsi 14, bb=2",
+		"empty":     "",
+	}
+
+	line := InfluxLine("backtest", tags, sampleResult())
+
+	// Tags sort by key with spaces, commas and equals signs escaped, and empty tags dropped.
+	// Integer fields carry the i suffix and the timestamp is the end date in nanoseconds.
+	expected := `backtest,bb_period=20,params=rsi\ 14\,\ bb\=2,symbol=AAPL ` +
+		`total_return=12.5,annualized_return=6.25,sharpe_ratio=1.1,max_drawdown=8.75,win_rate=60,` +
+		`total_profit_loss=1250,final_capital=11250,total_trades=10i,winning_trades=6i,losing_trades=4i ` +
+		`1704153600000000000`
+	if line != expected {
+		t.Errorf("Unexpected line protocol:\n got %s\nwant %s", line, expected)
+	}
+}
+
+func TestWriteInfluxHTTP(t *testing.T) {
+	line := InfluxLine("backtest", map[string]string{"symbol": "AAPL"}, sampleResult())
+
+	var body, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Query().Get("bucket") != "research" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		auth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	if err := WriteInflux(server.URL+"/api/v2/write?bucket=research", "secret", line); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if body != line+"\n" || auth != "Token secret" {
+		t.Errorf("Expected the line with token auth, got body %q and Authorization %q", body, auth)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer failing.Close()
+
+	if err := WriteInflux(failing.URL, "", line); err == nil {
+		t.Error("Expected an error for a rejected write")
+	}
+}
+
+func TestWriteInfluxFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.lp")
+
+	for _, symbol := range []string{"AAPL", "MSFT"} {
+		line := InfluxLine("backtest", map[string]string{"symbol": symbol}, sampleResult())
+		if err := WriteInflux(path, "", line); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := InfluxLine("backtest", map[string]string{"symbol": "AAPL"}, sampleResult()) + "\n" +
+		InfluxLine("backtest", map[string]string{"symbol": "MSFT"}, sampleResult()) + "\n"
+	if string(data) != expected {
+		t.Errorf("Expected both lines appended, got:\n%s", data)
+	}
+}
@@ -0,0 +1,36 @@
+package indicators
+
+import (
+	"math"
+	"swing-trader/internal/types"
+)
+
+// CalculateATR calculates the Average True Range using Wilder's smoothing. The first
+// valid point is at index period since the true range needs the previous close.
+func CalculateATR(data []types.StockData, period int) []float64 {
+	atrValues := make([]float64, len(data))
+	if period <= 0 || len(data) < period+1 {
+		return atrValues
+	}
+
+	trueRanges := make([]float64, len(data))
+	for i := 1; i < len(data); i++ {
+		highLow := data[i].High - data[i].Low
+		highClose := math.Abs(data[i].High - data[i-1].Close)
+		lowClose := math.Abs(data[i].Low - data[i-1].Close)
+		trueRanges[i] = math.Max(highLow, math.Max(highClose, lowClose))
+	}
+
+	// Seed with the average of the first period true ranges
+	var sum float64
+	for i := 1; i <= period; i++ {
+		sum += trueRanges[i]
+	}
+	atrValues[period] = sum / float64(period)
+
+	for i := period + 1; i < len(data); i++ {
+		atrValues[i] = (atrValues[i-1]*float64(period-1) + trueRanges[i]) / float64(period)
+	}
+
+	return atrValues
+}
@@ -0,0 +1,41 @@
+package indicators
+
+import (
+	"math"
+	"swing-trader/internal/types"
+	"testing"
+)
+
+func TestCalculateATR(t *testing.T) {
+	testData := []types.StockData{
+		{High: 11.0, Low: 9.0, Close: 10.0},
+		{High: 12.0, Low: 10.0, Close: 11.0}, // TR = 2
+		{High: 12.0, Low: 11.0, Close: 11.5}, // TR = 1
+		{High: 15.0, Low: 12.0, Close: 14.0}, // TR = max(3, 3.5, 0.5) = 3.5
+		{High: 14.0, Low: 10.0, Close: 11.0}, // TR = max(4, 0, 4) = 4
+	}
+
+	atr := CalculateATR(testData, 3)
+
+	if len(atr) != len(testData) {
+		t.Fatalf("Expected ATR length %d, got %d", len(testData), len(atr))
+	}
+
+	for i := 0; i < 3; i++ {
+		if atr[i] != 0 {
+			t.Errorf("Expected zero ATR during warm-up at index %d, got %f", i, atr[i])
+		}
+	}
+
+	// Seed is the simple average of the first three true ranges
+	expected := (2.0 + 1.0 + 3.5) / 3
+	if math.Abs(atr[3]-expected) > 1e-9 {
+		t.Errorf("Expected ATR at index 3 to be %f, got %f", expected, atr[3])
+	}
+
+	// Wilder smoothing afterwards
+	expected = (expected*2 + 4.0) / 3
+	if math.Abs(atr[4]-expected) > 1e-9 {
+		t.Errorf("Expected ATR at index 4 to be %f, got %f", expected, atr[4])
+	}
+}
@@ -0,0 +1,110 @@
+package indicators
+
+import (
+	"math/rand"
+	"swing-trader/internal/types"
+	"testing"
+	"time"
+)
+
+// benchmarkData returns a random walk of daily bars, roughly 40 years of trading days
+func benchmarkData() []types.StockData {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]types.StockData, 10000)
+	price := 100.0
+	for i := range data {
+		price *= 1 + (rng.Float64()-0.5)*0.04
+		data[i] = types.StockData{
+			Date:  time.Date(1985, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i),
+			Open:  price,
+			High:  price * 1.01,
+			Low:   price * 0.99,
+			Close: price,
+		}
+	}
+	return data
+}
+
+func BenchmarkCalculateBollingerBands(b *testing.B) {
+	data := benchmarkData()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CalculateBollingerBands(data, 200, 2.0)
+	}
+}
+
+func BenchmarkNaiveBollingerBands(b *testing.B) {
+	data := benchmarkData()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		naiveBollingerBands(data, 200, 2.0)
+	}
+}
+
+func BenchmarkCalculateRSI(b *testing.B) {
+	data := benchmarkData()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CalculateRSI(data, 14)
+	}
+}
+
+func BenchmarkCalculateConnorsRSI(b *testing.B) {
+	data := benchmarkData()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CalculateConnorsRSI(data, 3, 2, 100)
+	}
+}
+
+func BenchmarkCalculateDEMA(b *testing.B) {
+	data := benchmarkData()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CalculateDEMA(data, 50)
+	}
+}
+
+func BenchmarkCalculateTEMA(b *testing.B) {
+	data := benchmarkData()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CalculateTEMA(data, 50)
+	}
+}
+
+func BenchmarkCalculateKAMA(b *testing.B) {
+	data := benchmarkData()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CalculateKAMA(data, 10, 2, 30)
+	}
+}
+
+func BenchmarkCalculateATR(b *testing.B) {
+	data := benchmarkData()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CalculateATR(data, 14)
+	}
+}
+
+func BenchmarkCalculateGannHiLo(b *testing.B) {
+	data := benchmarkData()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CalculateGannHiLo(data, 20)
+	}
+}
+
+func BenchmarkCalculateRollingStats(b *testing.B) {
+	data := benchmarkData()
+	closes := make([]float64, len(data))
+	for i, d := range data {
+		closes[i] = d.Close
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CalculateRollingStats(closes, 200)
+	}
+}
@@ -1,28 +1,24 @@
 package indicators
 
 import (
-    "math"
     "swing-trader/internal/types"
 )
 
-// CalculateBollingerBands calculates the Bollinger Bands for given stock data
+// CalculateBollingerBands calculates the Bollinger Bands for given stock data.
+// The mean and standard deviation come from CalculateRollingStats, whose sliding Welford
+// variance stays accurate for large, nearly constant prices.
 func CalculateBollingerBands(data []types.StockData, period int, stdDevMultiplier float64) (bands []types.BollingerBands) {
-    for i := range data {
-        sum := 0.0
-        sqSum := 0.0
-        
-        if i >= period-1 {
-            for j := 0; j < period; j++ {
-                sum += data[i-j].Close
-                sqSum += math.Pow(data[i-j].Close, 2)
-            }
+    closes := make([]float64, len(data))
+    for i, d := range data {
+        closes[i] = d.Close
+    }
 
-            // Calculate mean
-            mean := sum / float64(period)
+    stats := CalculateRollingStats(closes, period)
 
-            // Calculate standard deviation
-            variance := (sqSum / float64(period)) - math.Pow(mean, 2)
-            stdDev := math.Sqrt(variance)
+    for i := range data {
+        if period > 0 && i >= period-1 {
+            mean := stats.Mean[i]
+            stdDev := stats.StdDev[i]
 
             // Append the Bollinger Bands for this point
             upper := mean + (stdDevMultiplier * stdDev)
@@ -40,4 +36,3 @@ func CalculateBollingerBands(data []types.StockData, period int, stdDevMultiplie
 
     return bands
 }
-
@@ -2,6 +2,7 @@ package indicators
 
 import (
 	"math"
+	"math/rand"
 	"swing-trader/internal/types"
 	"testing"
 	"time"
@@ -76,3 +77,83 @@ func TestCalculateBollingerBandsInsufficientData(t *testing.T) {
 		}
 	}
 }
+
+// naiveBollingerBands recomputes the full window for every point, as a reference
+// for the rolling implementation
+func naiveBollingerBands(data []types.StockData, period int, stdDevMultiplier float64) []types.BollingerBands {
+	bands := make([]types.BollingerBands, len(data))
+	for i := period - 1; i < len(data); i++ {
+		window := data[i-period+1 : i+1]
+
+		sum := 0.0
+		for _, d := range window {
+			sum += d.Close
+		}
+		mean := sum / float64(period)
+
+		variance := 0.0
+		for _, d := range window {
+			variance += (d.Close - mean) * (d.Close - mean)
+		}
+		stdDev := math.Sqrt(variance / float64(period))
+		bands[i] = types.BollingerBands{
+			Upper:  mean + (stdDevMultiplier * stdDev),
+			Middle: mean,
+			Lower:  mean - (stdDevMultiplier * stdDev),
+		}
+	}
+	return bands
+}
+
+func TestCalculateBollingerBandsLargeNearConstantPrices(t *testing.T) {
+	// Closes alternating between 10000.00 and 10000.01 have a standard deviation of exactly
+	// 0.005, which subtracting the squared mean from the mean square loses to cancellation
+	testData := make([]types.StockData, 60)
+	for i := range testData {
+		testData[i] = types.StockData{Close: 10000.00 + 0.01*float64(i%2)}
+	}
+
+	period := 20
+	bands := CalculateBollingerBands(testData, period, 2.0)
+
+	for i := period - 1; i < len(bands); i++ {
+		band := bands[i]
+		if math.IsNaN(band.Upper) || math.IsNaN(band.Lower) || math.IsInf(band.Upper, 0) || math.IsInf(band.Lower, 0) {
+			t.Fatalf("Expected finite bands at index %d, got %v", i, band)
+		}
+		if math.Abs(band.Middle-10000.005) > 1e-9 {
+			t.Errorf("Expected the middle band at 10000.005 at index %d, got %.9f", i, band.Middle)
+		}
+		if math.Abs(band.Upper-band.Middle-0.01) > 1e-9 || math.Abs(band.Middle-band.Lower-0.01) > 1e-9 {
+			t.Errorf("Expected the bands 0.01 either side of the mean at index %d, got %.9f to %.9f", i, band.Lower, band.Upper)
+		}
+	}
+}
+
+func TestCalculateBollingerBandsMatchesNaive(t *testing.T) {
+	// The sliding variance update rounds differently from summing each window, so the two
+	// methods agree to within rounding
+	rng := rand.New(rand.NewSource(42))
+	testData := make([]types.StockData, 500)
+	price := 100.0
+	for i := range testData {
+		price += float64(rng.Intn(7) - 3)
+		testData[i] = types.StockData{Close: price}
+	}
+
+	for _, period := range []int{1, 5, 20, 50} {
+		rolling := CalculateBollingerBands(testData, period, 2.0)
+		naive := naiveBollingerBands(testData, period, 2.0)
+
+		if len(rolling) != len(naive) {
+			t.Fatalf("Period %d: expected %d bands, got %d", period, len(naive), len(rolling))
+		}
+
+		for i := range naive {
+			if math.Abs(rolling[i].Upper-naive[i].Upper) > 1e-9 || math.Abs(rolling[i].Middle-naive[i].Middle) > 1e-9 || math.Abs(rolling[i].Lower-naive[i].Lower) > 1e-9 {
+				t.Errorf("Period %d: mismatch at index %d, rolling %v vs naive %v", period, i, rolling[i], naive[i])
+				break
+			}
+		}
+	}
+}
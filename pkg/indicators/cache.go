@@ -0,0 +1,67 @@
+package indicators
+
+import (
+	"swing-trader/internal/types"
+	"sync"
+)
+
+// Cache memoizes indicator series so backtests over the same data, such as a parameter
+// sweep where many combinations share an RSI or Bollinger period, compute each only once.
+// Entries are keyed by the indicator and its parameters, encoded in the key string, and by
+// the identity of the data slice: the same backing array and length. The data must not be
+// modified while it is cached, and callers must not modify the returned series. A Cache is
+// safe for concurrent use; concurrent requests for one entry compute it once.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[cacheKey]*cacheEntry
+	hits    int
+	misses  int
+}
+
+// cacheKey identifies an indicator series by its key and the data it was computed from
+type cacheKey struct {
+	key    string
+	first  *types.StockData
+	length int
+}
+
+// cacheEntry holds one series, computed by the first request for it
+type cacheEntry struct {
+	once  sync.Once
+	value any
+}
+
+// NewCache creates an empty indicator cache
+func NewCache() *Cache {
+	return &Cache{entries: make(map[cacheKey]*cacheEntry)}
+}
+
+// Get returns the series cached under key for data, calling compute to fill the entry on
+// the first request
+func (c *Cache) Get(key string, data []types.StockData, compute func() any) any {
+	id := cacheKey{key: key, length: len(data)}
+	if len(data) > 0 {
+		id.first = &data[0]
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[id]
+	if ok {
+		c.hits++
+	} else {
+		entry = &cacheEntry{}
+		c.entries[id] = entry
+		c.misses++
+	}
+	c.mu.Unlock()
+
+	entry.once.Do(func() { entry.value = compute() })
+	return entry.value
+}
+
+// Stats returns the number of requests served from the cache and the number computed
+func (c *Cache) Stats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
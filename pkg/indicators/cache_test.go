@@ -0,0 +1,60 @@
+package indicators
+
+import (
+	"swing-trader/internal/types"
+	"sync"
+	"testing"
+)
+
+func TestCacheComputesOncePerKeyAndData(t *testing.T) {
+	cache := NewCache()
+	data := closeSeries(1, 2, 3, 4, 5)
+
+	var mu sync.Mutex
+	computed := 0
+	compute := func() any {
+		mu.Lock()
+		computed++
+		mu.Unlock()
+		return []float64{42}
+	}
+
+	// Concurrent workers asking for the same series share one computation
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := cache.Get("sma/3", data, compute).([]float64); got[0] != 42 {
+				t.Errorf("Expected the cached series, got %v", got)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if computed != 1 {
+		t.Errorf("Expected 1 computation across concurrent requests, got %d", computed)
+	}
+	if hits, misses := cache.Stats(); hits != 15 || misses != 1 {
+		t.Errorf("Expected 15 hits and 1 miss, got %d and %d", hits, misses)
+	}
+
+	// Another key or another data slice, even with equal values, is a separate entry
+	cache.Get("sma/4", data, compute)
+	cache.Get("sma/3", closeSeries(1, 2, 3, 4, 5), compute)
+	cache.Get("sma/3", data[:4], compute)
+	if computed != 4 {
+		t.Errorf("Expected separate entries per key and data, got %d computations", computed)
+	}
+}
+
+func TestCacheEmptyData(t *testing.T) {
+	cache := NewCache()
+	var empty []types.StockData
+
+	first := cache.Get("rsi/14", empty, func() any { return 1 })
+	second := cache.Get("rsi/14", empty, func() any { return 2 })
+	if first != 1 || second != 1 {
+		t.Errorf("Expected empty data to cache like any other, got %v and %v", first, second)
+	}
+}
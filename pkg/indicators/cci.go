@@ -0,0 +1,40 @@
+package indicators
+
+import (
+	"math"
+	"swing-trader/internal/types"
+)
+
+// cciConstant scales CCI so most readings fall between -100 and 100
+const cciConstant = 0.015
+
+// CalculateCCI calculates the Commodity Channel Index, the distance of the typical price
+// (high + low + close) / 3 from its period moving average in units of the mean absolute
+// deviation, scaled by 1/0.015. A window with no deviation reads 0, as do points before
+// index period-1.
+func CalculateCCI(data []types.StockData, period int) []float64 {
+	cciValues := make([]float64, len(data))
+	if period <= 0 || len(data) < period {
+		return cciValues
+	}
+
+	typical := make([]float64, len(data))
+	for i, d := range data {
+		typical[i] = (d.High + d.Low + d.Close) / 3
+	}
+	means := calculateSMA(typical, period)
+
+	for i := period - 1; i < len(data); i++ {
+		var deviation float64
+		for _, tp := range typical[i-period+1 : i+1] {
+			deviation += math.Abs(tp - means[i])
+		}
+		deviation /= float64(period)
+
+		if deviation > 0 {
+			cciValues[i] = (typical[i] - means[i]) / (cciConstant * deviation)
+		}
+	}
+
+	return cciValues
+}
@@ -0,0 +1,36 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalculateCCI(t *testing.T) {
+	// With high = low = close, the typical prices are the closes
+	data := closeSeries(10, 12, 14, 13)
+	for i := range data {
+		data[i].High = data[i].Close
+		data[i].Low = data[i].Close
+	}
+
+	cci := CalculateCCI(data, 3)
+
+	if cci[0] != 0 || cci[1] != 0 {
+		t.Errorf("Expected zeros during warm-up, got %.2f and %.2f", cci[0], cci[1])
+	}
+
+	// Window 10, 12, 14: mean 12 and mean deviation 4/3, so (14 - 12) / (0.015 * 4/3) = 100
+	if math.Abs(cci[2]-100) > 1e-9 {
+		t.Errorf("Expected CCI 100 at index 2, got %.4f", cci[2])
+	}
+
+	// Window 12, 14, 13: mean 13 and mean deviation 2/3, with the close at the mean
+	if math.Abs(cci[3]) > 1e-9 {
+		t.Errorf("Expected CCI 0 at index 3, got %.4f", cci[3])
+	}
+
+	flat := CalculateCCI(closeSeries(5, 5, 5), 3)
+	if flat[2] != 0 {
+		t.Errorf("Expected CCI 0 with no deviation, got %.4f", flat[2])
+	}
+}
@@ -0,0 +1,95 @@
+package indicators
+
+import (
+	"swing-trader/internal/types"
+)
+
+// CalculateConnorsRSI calculates Connors RSI, the average of a short RSI of the close,
+// an RSI of the up/down streak length and the percent rank of the one-day rate of change
+func CalculateConnorsRSI(data []types.StockData, rsiPeriod, streakPeriod, rankPeriod int) []float64 {
+	crsiValues := make([]float64, len(data))
+
+	// Start where all three components are valid
+	startIndex := rsiPeriod
+	if streakPeriod > startIndex {
+		startIndex = streakPeriod
+	}
+	if rankPeriod+1 > startIndex {
+		startIndex = rankPeriod + 1
+	}
+
+	if len(data) <= startIndex {
+		return crsiValues
+	}
+
+	closes := make([]float64, len(data))
+	for i, d := range data {
+		closes[i] = d.Close
+	}
+
+	priceRSI := calculateRSIValues(closes, rsiPeriod)
+	streakRSI := calculateRSIValues(calculateStreaks(closes), streakPeriod)
+	rocRank := calculatePercentRank(calculateROC(closes), rankPeriod)
+
+	for i := startIndex; i < len(data); i++ {
+		crsiValues[i] = (priceRSI[i] + streakRSI[i] + rocRank[i]) / 3
+	}
+
+	return crsiValues
+}
+
+// calculateStreaks returns the number of consecutive up (positive) or down (negative) closes
+// ending at each point, resetting to zero on an unchanged close
+func calculateStreaks(closes []float64) []float64 {
+	streaks := make([]float64, len(closes))
+
+	for i := 1; i < len(closes); i++ {
+		switch {
+		case closes[i] > closes[i-1]:
+			if streaks[i-1] > 0 {
+				streaks[i] = streaks[i-1] + 1
+			} else {
+				streaks[i] = 1
+			}
+		case closes[i] < closes[i-1]:
+			if streaks[i-1] < 0 {
+				streaks[i] = streaks[i-1] - 1
+			} else {
+				streaks[i] = -1
+			}
+		}
+	}
+
+	return streaks
+}
+
+// calculateROC returns the one-day percentage rate of change, zero for the first point
+func calculateROC(closes []float64) []float64 {
+	roc := make([]float64, len(closes))
+
+	for i := 1; i < len(closes); i++ {
+		if closes[i-1] != 0 {
+			roc[i] = (closes[i] - closes[i-1]) / closes[i-1] * 100
+		}
+	}
+
+	return roc
+}
+
+// calculatePercentRank returns the percentage of the previous period values that are
+// below the current value. The first valid point is period+1 since values[0] has no change.
+func calculatePercentRank(values []float64, period int) []float64 {
+	ranks := make([]float64, len(values))
+
+	for i := period + 1; i < len(values); i++ {
+		below := 0
+		for j := i - period; j < i; j++ {
+			if values[j] < values[i] {
+				below++
+			}
+		}
+		ranks[i] = float64(below) / float64(period) * 100
+	}
+
+	return ranks
+}
@@ -0,0 +1,78 @@
+package indicators
+
+import (
+	"swing-trader/internal/types"
+	"testing"
+	"time"
+)
+
+func TestCalculateStreaks(t *testing.T) {
+	closes := []float64{100, 101, 102, 103, 102, 101, 101, 102, 100}
+	expected := []float64{0, 1, 2, 3, -1, -2, 0, 1, -1}
+
+	streaks := calculateStreaks(closes)
+
+	if len(streaks) != len(expected) {
+		t.Fatalf("Expected %d streak values, got %d", len(expected), len(streaks))
+	}
+
+	for i := range expected {
+		if streaks[i] != expected[i] {
+			t.Errorf("Expected streak at index %d to be %.0f, got %.0f", i, expected[i], streaks[i])
+		}
+	}
+}
+
+func TestCalculateConnorsRSI(t *testing.T) {
+	closes := []float64{
+		100, 101, 103, 102, 104, 107, 105, 104, 106, 109,
+		108, 110, 113, 111, 112, 110, 109, 111, 114, 116,
+	}
+
+	testData := make([]types.StockData, len(closes))
+	for i, c := range closes {
+		testData[i] = types.StockData{
+			Date:  time.Date(2023, 1, 1+i, 0, 0, 0, 0, time.UTC),
+			Close: c,
+		}
+	}
+
+	rsiPeriod, streakPeriod, rankPeriod := 3, 2, 10
+	crsi := CalculateConnorsRSI(testData, rsiPeriod, streakPeriod, rankPeriod)
+
+	if len(crsi) != len(testData) {
+		t.Fatalf("Expected Connors RSI length %d, got %d", len(testData), len(crsi))
+	}
+
+	// The percent rank component is the last to become valid
+	startIndex := rankPeriod + 1
+	for i := 0; i < startIndex; i++ {
+		if crsi[i] != 0 {
+			t.Errorf("Expected zero during warm-up at index %d, got %.2f", i, crsi[i])
+		}
+	}
+
+	for i := startIndex; i < len(crsi); i++ {
+		if crsi[i] <= 0 || crsi[i] > 100 {
+			t.Errorf("Expected Connors RSI in (0, 100] at index %d, got %.2f", i, crsi[i])
+		}
+	}
+}
+
+func TestCalculateConnorsRSIInsufficientData(t *testing.T) {
+	testData := []types.StockData{
+		{Close: 100.0},
+		{Close: 101.0},
+	}
+
+	crsi := CalculateConnorsRSI(testData, 3, 2, 100)
+	if len(crsi) != len(testData) {
+		t.Errorf("Expected %d values, got %d", len(testData), len(crsi))
+	}
+
+	for i, v := range crsi {
+		if v != 0 {
+			t.Errorf("Expected zero value for insufficient data at index %d, got %.2f", i, v)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package indicators
+
+import (
+	"math"
+)
+
+// CrossOver reports whether series a crosses above series b at bar i: a was at or below b
+// on the previous bar and is strictly above it now. A bar merely touching b is not a cross.
+// It is false at the first bar, out of range, and wherever either bar of either series is
+// still warming up (zero or NaN), so indicator warm-up never produces a false cross.
+func CrossOver(a, b []float64, i int) bool {
+	if !crossValid(a, b, i) {
+		return false
+	}
+	return a[i-1] <= b[i-1] && a[i] > b[i]
+}
+
+// CrossUnder reports whether series a crosses below series b at bar i: a was at or above b
+// on the previous bar and is strictly below it now, with the same warm-up handling as CrossOver
+func CrossUnder(a, b []float64, i int) bool {
+	if !crossValid(a, b, i) {
+		return false
+	}
+	return a[i-1] >= b[i-1] && a[i] < b[i]
+}
+
+// crossValid reports whether bars i-1 and i hold valid values in both series
+func crossValid(a, b []float64, i int) bool {
+	if i < 1 || i >= len(a) || i >= len(b) {
+		return false
+	}
+
+	for _, v := range []float64{a[i-1], a[i], b[i-1], b[i]} {
+		if v == 0 || math.IsNaN(v) {
+			return false
+		}
+	}
+	return true
+}
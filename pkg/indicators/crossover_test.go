@@ -0,0 +1,58 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCrossOverAndCrossUnder(t *testing.T) {
+	tests := []struct {
+		name  string
+		a     []float64
+		b     []float64
+		over  bool
+		under bool
+	}{
+		{"clear cross above", []float64{9, 11}, []float64{10, 10}, true, false},
+		{"clear cross below", []float64{11, 9}, []float64{10, 10}, false, true},
+		{"touch from below", []float64{9, 10}, []float64{10, 10}, false, false},
+		{"touch from above", []float64{11, 10}, []float64{10, 10}, false, false},
+		{"leaves a touch upward", []float64{10, 11}, []float64{10, 10}, true, false},
+		{"leaves a touch downward", []float64{10, 9}, []float64{10, 10}, false, true},
+		{"stays above", []float64{11, 12}, []float64{10, 10}, false, false},
+		{"stays below", []float64{8, 9}, []float64{10, 10}, false, false},
+		{"previous bar warming up", []float64{0, 11}, []float64{10, 10}, false, false},
+		{"other series warming up", []float64{9, 11}, []float64{0, 10}, false, false},
+		{"NaN value", []float64{math.NaN(), 11}, []float64{10, 10}, false, false},
+	}
+
+	for _, tt := range tests {
+		if got := CrossOver(tt.a, tt.b, 1); got != tt.over {
+			t.Errorf("%s: expected CrossOver %v, got %v", tt.name, tt.over, got)
+		}
+		if got := CrossUnder(tt.a, tt.b, 1); got != tt.under {
+			t.Errorf("%s: expected CrossUnder %v, got %v", tt.name, tt.under, got)
+		}
+	}
+}
+
+func TestCrossOverBounds(t *testing.T) {
+	a := []float64{9, 11, 12}
+	b := []float64{10, 10}
+
+	// There is no previous bar at index 0, and index 2 is past the end of b
+	for _, i := range []int{-1, 0, 2, 3} {
+		if CrossOver(a, b, i) || CrossUnder(a, b, i) {
+			t.Errorf("Expected no cross at out-of-range index %d", i)
+		}
+	}
+
+	// The fast average is above the slow one on the slow average's first valid bar, but
+	// the zero warm-up value before it must not read as a cross from below
+	closes := []float64{10, 10, 12, 14}
+	fast := calculateSMA(closes, 2)
+	slow := calculateSMA(closes, 3)
+	if CrossOver(fast, slow, 2) {
+		t.Errorf("Expected no cross on the slow average's first valid bar, fast %v slow %v", fast, slow)
+	}
+}
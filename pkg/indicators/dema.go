@@ -0,0 +1,51 @@
+package indicators
+
+import (
+	"swing-trader/internal/types"
+)
+
+// CalculateDEMA calculates the Double Exponential Moving Average (2·EMA − EMA(EMA)) of the close
+func CalculateDEMA(data []types.StockData, period int) []float64 {
+	if period <= 0 || len(data) < period {
+		return make([]float64, len(data))
+	}
+
+	closes := make([]float64, len(data))
+	for i, d := range data {
+		closes[i] = d.Close
+	}
+
+	ema1 := calculateEMA(closes, 0, period)
+	ema2 := calculateEMA(ema1, period-1, period)
+
+	demaValues := make([]float64, len(data))
+	for i := 2 * (period - 1); i < len(data); i++ {
+		demaValues[i] = 2*ema1[i] - ema2[i]
+	}
+
+	return demaValues
+}
+
+// CalculateTEMA calculates the Triple Exponential Moving Average
+// (3·EMA − 3·EMA(EMA) + EMA(EMA(EMA))) of the close
+func CalculateTEMA(data []types.StockData, period int) []float64 {
+	if period <= 0 || len(data) < period {
+		return make([]float64, len(data))
+	}
+
+	closes := make([]float64, len(data))
+	for i, d := range data {
+		closes[i] = d.Close
+	}
+
+	ema1 := calculateEMA(closes, 0, period)
+	ema2 := calculateEMA(ema1, period-1, period)
+	ema3 := calculateEMA(ema2, 2*(period-1), period)
+
+	temaValues := make([]float64, len(data))
+	for i := 3 * (period - 1); i < len(data); i++ {
+		temaValues[i] = 3*ema1[i] - 3*ema2[i] + ema3[i]
+	}
+
+	return temaValues
+}
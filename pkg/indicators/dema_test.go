@@ -0,0 +1,107 @@
+package indicators
+
+import (
+	"math"
+	"swing-trader/internal/types"
+	"testing"
+	"time"
+)
+
+// stepSeries returns flat closes at 100 that step up to 110 at stepIndex
+func stepSeries(length, stepIndex int) []types.StockData {
+	data := make([]types.StockData, length)
+	for i := range data {
+		close := 100.0
+		if i >= stepIndex {
+			close = 110.0
+		}
+		data[i] = types.StockData{
+			Date:  time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i),
+			Close: close,
+		}
+	}
+	return data
+}
+
+func TestCalculateDEMAAndTEMALag(t *testing.T) {
+	period := 5
+	stepIndex := 20
+	data := stepSeries(40, stepIndex)
+
+	closes := make([]float64, len(data))
+	for i, d := range data {
+		closes[i] = d.Close
+	}
+
+	ema := calculateEMA(closes, 0, period)
+	dema := CalculateDEMA(data, period)
+	tema := CalculateTEMA(data, period)
+
+	if len(dema) != len(data) || len(tema) != len(data) {
+		t.Fatalf("Expected length-aligned results, got DEMA %d and TEMA %d for %d bars", len(dema), len(tema), len(data))
+	}
+
+	// On the flat section all averages equal the price
+	for _, values := range [][]float64{ema, dema, tema} {
+		if math.Abs(values[stepIndex-1]-100.0) > 1e-9 {
+			t.Errorf("Expected 100 before the step, got %f", values[stepIndex-1])
+		}
+	}
+
+	// On the bars right after the step the lower-lag averages should be closer to the new level
+	for i := stepIndex; i < stepIndex+2; i++ {
+		emaLag := math.Abs(110.0 - ema[i])
+		demaLag := math.Abs(110.0 - dema[i])
+		temaLag := math.Abs(110.0 - tema[i])
+
+		if demaLag >= emaLag {
+			t.Errorf("Expected DEMA lag %f below EMA lag %f at index %d", demaLag, emaLag, i)
+		}
+		if temaLag >= demaLag {
+			t.Errorf("Expected TEMA lag %f below DEMA lag %f at index %d", temaLag, demaLag, i)
+		}
+	}
+}
+
+func TestCalculateDEMAAndTEMAWarmUp(t *testing.T) {
+	period := 5
+	data := stepSeries(20, 10)
+
+	dema := CalculateDEMA(data, period)
+	tema := CalculateTEMA(data, period)
+
+	for i := 0; i < 2*(period-1); i++ {
+		if dema[i] != 0 {
+			t.Errorf("Expected zero DEMA during warm-up at index %d, got %f", i, dema[i])
+		}
+	}
+	if dema[2*(period-1)] == 0 {
+		t.Errorf("Expected first valid DEMA at index %d", 2*(period-1))
+	}
+
+	for i := 0; i < 3*(period-1); i++ {
+		if tema[i] != 0 {
+			t.Errorf("Expected zero TEMA during warm-up at index %d, got %f", i, tema[i])
+		}
+	}
+	if tema[3*(period-1)] == 0 {
+		t.Errorf("Expected first valid TEMA at index %d", 3*(period-1))
+	}
+}
+
+func TestCalculateDEMAAndTEMAInvalidPeriod(t *testing.T) {
+	data := stepSeries(10, 5)
+
+	for _, period := range []int{0, -1, 20} {
+		dema := CalculateDEMA(data, period)
+		tema := CalculateTEMA(data, period)
+		if len(dema) != len(data) || len(tema) != len(data) {
+			t.Fatalf("Expected %d values for period %d, got %d and %d", len(data), period, len(dema), len(tema))
+		}
+		for i := range data {
+			if dema[i] != 0 || tema[i] != 0 {
+				t.Errorf("Expected zeros for period %d at index %d, got %.2f and %.2f", period, i, dema[i], tema[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package indicators
+
+// CalculateEMA calculates an exponential moving average of values, returning a slice the
+// same length. The first valid point (period-1) is seeded with the simple average of the
+// first period values and later points apply the multiplier 2/(period+1). Points before the
+// seed are zero, as with the other indicators.
+func CalculateEMA(values []float64, period int) []float64 {
+	return calculateEMA(values, 0, period)
+}
+
+// calculateEMA calculates an exponential moving average of values, treating points before
+// start as invalid. The first valid point (start+period-1) is seeded with a simple average
+// and earlier points are zero.
+func calculateEMA(values []float64, start, period int) []float64 {
+	emaValues := make([]float64, len(values))
+
+	seedIndex := start + period - 1
+	if period <= 0 || start < 0 || seedIndex >= len(values) {
+		return emaValues
+	}
+
+	sum := 0.0
+	for i := start; i <= seedIndex; i++ {
+		sum += values[i]
+	}
+	emaValues[seedIndex] = sum / float64(period)
+
+	multiplier := 2.0 / float64(period+1)
+	for i := seedIndex + 1; i < len(values); i++ {
+		emaValues[i] = (values[i]-emaValues[i-1])*multiplier + emaValues[i-1]
+	}
+
+	return emaValues
+}
@@ -0,0 +1,46 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalculateEMA(t *testing.T) {
+	values := []float64{22, 24, 23, 25, 26, 28, 27, 29}
+	period := 5
+
+	ema := CalculateEMA(values, period)
+	if len(ema) != len(values) {
+		t.Fatalf("Expected EMA length %d, got %d", len(values), len(ema))
+	}
+
+	// Seeded with the average of the first five values, then weighted by 2/6 = 1/3
+	expected := make([]float64, len(values))
+	expected[4] = (22 + 24 + 23 + 25 + 26) / 5.0
+	for i := 5; i < len(values); i++ {
+		expected[i] = expected[i-1] + (values[i]-expected[i-1])/3
+	}
+
+	for i := range values {
+		if math.Abs(ema[i]-expected[i]) > 1e-9 {
+			t.Errorf("Expected EMA %.6f at index %d, got %.6f", expected[i], i, ema[i])
+		}
+	}
+
+	// 24 at the seed, then 25 1/3, 25 8/9 and 26 25/27
+	if math.Abs(ema[len(ema)-1]-(26+25.0/27)) > 1e-9 {
+		t.Errorf("Expected a final EMA of %.6f, got %.6f", 26+25.0/27, ema[len(ema)-1])
+	}
+}
+
+func TestCalculateEMAInsufficientData(t *testing.T) {
+	ema := CalculateEMA([]float64{1, 2, 3}, 5)
+	if len(ema) != 3 {
+		t.Fatalf("Expected 3 values, got %d", len(ema))
+	}
+	for i, v := range ema {
+		if v != 0 {
+			t.Errorf("Expected zero for insufficient data at index %d, got %f", i, v)
+		}
+	}
+}
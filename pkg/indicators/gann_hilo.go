@@ -0,0 +1,47 @@
+package indicators
+
+import (
+	"swing-trader/internal/types"
+)
+
+// CalculateGannHiLo calculates the Gann High-Low Activator. The trend turns up (1) when the
+// close rises above the previous SMA of highs and down (-1) when it falls below the previous
+// SMA of lows. The activator line follows the SMA of lows in an uptrend and the SMA of highs
+// in a downtrend. Points before index period are zero.
+func CalculateGannHiLo(data []types.StockData, period int) ([]float64, []int) {
+	activator := make([]float64, len(data))
+	trend := make([]int, len(data))
+	if period <= 0 || len(data) <= period {
+		return activator, trend
+	}
+
+	highs := make([]float64, len(data))
+	lows := make([]float64, len(data))
+	for i, d := range data {
+		highs[i] = d.High
+		lows[i] = d.Low
+	}
+
+	highSMA := calculateSMA(highs, period)
+	lowSMA := calculateSMA(lows, period)
+
+	for i := period; i < len(data); i++ {
+		switch {
+		case data[i].Close > highSMA[i-1]:
+			trend[i] = 1
+		case data[i].Close < lowSMA[i-1]:
+			trend[i] = -1
+		default:
+			trend[i] = trend[i-1]
+		}
+
+		switch trend[i] {
+		case 1:
+			activator[i] = lowSMA[i]
+		case -1:
+			activator[i] = highSMA[i]
+		}
+	}
+
+	return activator, trend
+}
@@ -0,0 +1,70 @@
+package indicators
+
+import (
+	"math"
+	"swing-trader/internal/types"
+	"testing"
+	"time"
+)
+
+func TestCalculateGannHiLoTrendFlip(t *testing.T) {
+	var closes []float64
+	for i := 0; i < 10; i++ {
+		closes = append(closes, 100.0+2*float64(i))
+	}
+	peak := len(closes) - 1
+	for i := 1; i <= 10; i++ {
+		closes = append(closes, closes[peak]-2*float64(i))
+	}
+
+	testData := make([]types.StockData, len(closes))
+	for i, c := range closes {
+		testData[i] = types.StockData{
+			Date:  time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i),
+			High:  c + 1,
+			Low:   c - 1,
+			Close: c,
+		}
+	}
+
+	period := 3
+	activator, trend := CalculateGannHiLo(testData, period)
+
+	if len(activator) != len(testData) || len(trend) != len(testData) {
+		t.Fatalf("Expected length-aligned results, got %d and %d for %d bars", len(activator), len(trend), len(testData))
+	}
+
+	lowSMA := func(i int) float64 {
+		return (testData[i].Low + testData[i-1].Low + testData[i-2].Low) / 3
+	}
+	highSMA := func(i int) float64 {
+		return (testData[i].High + testData[i-1].High + testData[i-2].High) / 3
+	}
+
+	// Rising section: trend up and the activator trails below on the SMA of lows
+	for i := period; i <= peak; i++ {
+		if trend[i] != 1 {
+			t.Errorf("Expected uptrend at index %d, got %d", i, trend[i])
+		}
+		if math.Abs(activator[i]-lowSMA(i)) > 1e-9 {
+			t.Errorf("Expected activator on the low SMA at index %d, got %f", i, activator[i])
+		}
+	}
+
+	// Once the fall breaks the low SMA the trend flips and the activator moves above on the SMA of highs
+	flipped := false
+	for i := peak + 1; i < len(closes); i++ {
+		if trend[i] == -1 {
+			flipped = true
+			if math.Abs(activator[i]-highSMA(i)) > 1e-9 {
+				t.Errorf("Expected activator on the high SMA at index %d, got %f", i, activator[i])
+			}
+		} else if flipped {
+			t.Errorf("Expected downtrend to persist at index %d, got %d", i, trend[i])
+		}
+	}
+
+	if !flipped || trend[len(trend)-1] != -1 {
+		t.Error("Expected the trend to flip down during the falling section")
+	}
+}
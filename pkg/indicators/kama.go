@@ -0,0 +1,41 @@
+package indicators
+
+import (
+	"math"
+	"swing-trader/internal/types"
+)
+
+// CalculateKAMA calculates Kaufman's Adaptive Moving Average. The efficiency ratio over
+// erPeriod scales the smoothing constant between the fast and slow EMA constants, so the
+// average follows trends closely and flattens out in choppy markets.
+func CalculateKAMA(data []types.StockData, erPeriod, fastPeriod, slowPeriod int) []float64 {
+	kamaValues := make([]float64, len(data))
+	if erPeriod <= 0 || len(data) <= erPeriod {
+		return kamaValues
+	}
+
+	fastSC := 2.0 / float64(fastPeriod+1)
+	slowSC := 2.0 / float64(slowPeriod+1)
+
+	// Seed with the close at the end of the first efficiency ratio window
+	kamaValues[erPeriod-1] = data[erPeriod-1].Close
+
+	for i := erPeriod; i < len(data); i++ {
+		change := math.Abs(data[i].Close - data[i-erPeriod].Close)
+
+		volatility := 0.0
+		for j := i - erPeriod + 1; j <= i; j++ {
+			volatility += math.Abs(data[j].Close - data[j-1].Close)
+		}
+
+		efficiencyRatio := 0.0
+		if volatility != 0 {
+			efficiencyRatio = change / volatility
+		}
+
+		sc := math.Pow(efficiencyRatio*(fastSC-slowSC)+slowSC, 2)
+		kamaValues[i] = kamaValues[i-1] + sc*(data[i].Close-kamaValues[i-1])
+	}
+
+	return kamaValues
+}
@@ -0,0 +1,73 @@
+package indicators
+
+import (
+	"math"
+	"swing-trader/internal/types"
+	"testing"
+	"time"
+)
+
+func TestCalculateKAMATrendThenChop(t *testing.T) {
+	var closes []float64
+
+	// Steady uptrend from 100 to 139
+	for i := 0; i < 40; i++ {
+		closes = append(closes, 100.0+float64(i))
+	}
+	trendEnd := len(closes)
+
+	// Choppy range oscillating around the last trend price
+	for i := 0; i < 40; i++ {
+		if i%2 == 0 {
+			closes = append(closes, 137.0)
+		} else {
+			closes = append(closes, 141.0)
+		}
+	}
+
+	testData := make([]types.StockData, len(closes))
+	for i, c := range closes {
+		testData[i] = types.StockData{
+			Date:  time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i),
+			Close: c,
+		}
+	}
+
+	kama := CalculateKAMA(testData, 10, 2, 30)
+
+	if len(kama) != len(testData) {
+		t.Fatalf("Expected KAMA length %d, got %d", len(testData), len(kama))
+	}
+
+	// In the trend the efficiency ratio is 1, so KAMA should stay close to price
+	for i := 20; i < trendEnd; i++ {
+		if gap := closes[i] - kama[i]; gap < 0 || gap > 2.0 {
+			t.Errorf("Expected KAMA to track the trend within 2.0 at index %d, got gap %.4f", i, gap)
+		}
+	}
+
+	// In the chop the average should barely move bar to bar while price swings by 4
+	for i := trendEnd + 10; i < len(closes); i++ {
+		if move := math.Abs(kama[i] - kama[i-1]); move > 0.1 {
+			t.Errorf("Expected KAMA to flatten in chop at index %d, moved %.4f", i, move)
+		}
+	}
+}
+
+func TestCalculateKAMAInsufficientData(t *testing.T) {
+	testData := []types.StockData{
+		{Close: 100.0},
+		{Close: 101.0},
+	}
+
+	kama := CalculateKAMA(testData, 10, 2, 30)
+	if len(kama) != len(testData) {
+		t.Errorf("Expected %d values, got %d", len(testData), len(kama))
+	}
+
+	for i, v := range kama {
+		if v != 0 {
+			t.Errorf("Expected zero value for insufficient data at index %d, got %.2f", i, v)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package indicators
+
+import (
+	"math"
+	"swing-trader/internal/types"
+)
+
+// CalculateLinRegChannel calculates a rolling linear regression channel of the close. Each
+// window of period bars is fitted by least squares and the middle line is the fit's value
+// at the window's last bar. The upper and lower lines sit stdDevMult standard deviations of
+// the window's residuals either side. Values are zero before index period-1.
+func CalculateLinRegChannel(data []types.StockData, period int, stdDevMult float64) (mid, upper, lower []float64) {
+	mid = make([]float64, len(data))
+	upper = make([]float64, len(data))
+	lower = make([]float64, len(data))
+
+	if period < 2 || len(data) < period {
+		return mid, upper, lower
+	}
+
+	for i := period - 1; i < len(data); i++ {
+		end, sumSquares := fitLine(data[i-period+1 : i+1])
+		stdDev := math.Sqrt(sumSquares / float64(period))
+
+		mid[i] = end
+		upper[i] = mid[i] + stdDevMult*stdDev
+		lower[i] = mid[i] - stdDevMult*stdDev
+	}
+
+	return mid, upper, lower
+}
+
+// fitLine fits the window's closes against x = 0..len-1 by least squares, returning the fit's
+// value at the last bar and the sum of squared residuals
+func fitLine(window []types.StockData) (end, sumSquares float64) {
+	n := float64(len(window))
+	meanX := (n - 1) / 2
+
+	var meanY float64
+	for _, d := range window {
+		meanY += d.Close
+	}
+	meanY /= n
+
+	var sxx, sxy float64
+	for x, d := range window {
+		sxx += (float64(x) - meanX) * (float64(x) - meanX)
+		sxy += (float64(x) - meanX) * (d.Close - meanY)
+	}
+	slope := sxy / sxx
+	intercept := meanY - slope*meanX
+
+	for x, d := range window {
+		residual := d.Close - (intercept + slope*float64(x))
+		sumSquares += residual * residual
+	}
+
+	return intercept + slope*(n-1), sumSquares
+}
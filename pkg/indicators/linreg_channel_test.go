@@ -0,0 +1,64 @@
+package indicators
+
+import (
+	"math"
+	"swing-trader/internal/types"
+	"testing"
+	"time"
+)
+
+// closeSeries builds daily bars with the given closes
+func closeSeries(closes ...float64) []types.StockData {
+	data := make([]types.StockData, len(closes))
+	for i, c := range closes {
+		data[i] = types.StockData{
+			Date:  time.Date(2023, 1, 1+i, 0, 0, 0, 0, time.UTC),
+			Close: c,
+		}
+	}
+	return data
+}
+
+func TestCalculateLinRegChannelLinearSeries(t *testing.T) {
+	closes := make([]float64, 30)
+	for i := range closes {
+		closes[i] = 50 + 1.5*float64(i)
+	}
+	data := closeSeries(closes...)
+
+	period := 10
+	mid, upper, lower := CalculateLinRegChannel(data, period, 2.0)
+
+	if len(mid) != len(data) || len(upper) != len(data) || len(lower) != len(data) {
+		t.Fatalf("Expected length-aligned results, got %d, %d and %d for %d bars", len(mid), len(upper), len(lower), len(data))
+	}
+
+	for i := 0; i < period-1; i++ {
+		if mid[i] != 0 || upper[i] != 0 || lower[i] != 0 {
+			t.Errorf("Expected zeros during warm-up at index %d", i)
+		}
+	}
+
+	// A perfect line is its own fit, so the channel collapses onto the closes
+	for i := period - 1; i < len(data); i++ {
+		if math.Abs(mid[i]-closes[i]) > 1e-9 {
+			t.Errorf("Expected the middle line at the close %.2f at index %d, got %.6f", closes[i], i, mid[i])
+		}
+		if width := upper[i] - lower[i]; width > 1e-9 {
+			t.Errorf("Expected zero channel width at index %d, got %.9f", i, width)
+		}
+	}
+}
+
+func TestCalculateLinRegChannelResiduals(t *testing.T) {
+	// The fit of 1, 3, 2 is 1.5 + 0.5x, ending at 2.5 with residuals -0.5, 1 and -0.5
+	mid, upper, lower := CalculateLinRegChannel(closeSeries(1, 3, 2), 3, 1.0)
+
+	stdDev := math.Sqrt(1.5 / 3)
+	if math.Abs(mid[2]-2.5) > 1e-9 {
+		t.Errorf("Expected the middle line at 2.5, got %.6f", mid[2])
+	}
+	if math.Abs(upper[2]-(2.5+stdDev)) > 1e-9 || math.Abs(lower[2]-(2.5-stdDev)) > 1e-9 {
+		t.Errorf("Expected the channel at 2.5 ± %.6f, got %.6f to %.6f", stdDev, lower[2], upper[2])
+	}
+}
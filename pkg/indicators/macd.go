@@ -0,0 +1,38 @@
+package indicators
+
+import (
+	"swing-trader/internal/types"
+)
+
+// CalculateMACD calculates the Moving Average Convergence Divergence of the close: the fast
+// EMA minus the slow EMA. The signal line is an EMA of the MACD line and the histogram is
+// the MACD line minus the signal line. The MACD line is valid from slowPeriod-1 and the
+// signal line and histogram from slowPeriod+signalPeriod-2, with zeros before.
+func CalculateMACD(data []types.StockData, fastPeriod, slowPeriod, signalPeriod int) ([]float64, []float64, []float64) {
+	macd := make([]float64, len(data))
+	signalLine := make([]float64, len(data))
+	hist := make([]float64, len(data))
+
+	if fastPeriod <= 0 || slowPeriod < fastPeriod || signalPeriod <= 0 || len(data) < slowPeriod {
+		return macd, signalLine, hist
+	}
+
+	closes := make([]float64, len(data))
+	for i, d := range data {
+		closes[i] = d.Close
+	}
+
+	fastEMA := calculateEMA(closes, 0, fastPeriod)
+	slowEMA := calculateEMA(closes, 0, slowPeriod)
+
+	for i := slowPeriod - 1; i < len(data); i++ {
+		macd[i] = fastEMA[i] - slowEMA[i]
+	}
+
+	signalLine = calculateEMA(macd, slowPeriod-1, signalPeriod)
+	for i := slowPeriod + signalPeriod - 2; i < len(data); i++ {
+		hist[i] = macd[i] - signalLine[i]
+	}
+
+	return macd, signalLine, hist
+}
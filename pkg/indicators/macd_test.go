@@ -0,0 +1,63 @@
+package indicators
+
+import (
+	"math"
+	"swing-trader/internal/types"
+	"testing"
+	"time"
+)
+
+func TestCalculateMACD(t *testing.T) {
+	closes := []float64{10, 11, 12, 13, 14, 13, 12}
+	testData := make([]types.StockData, len(closes))
+	for i, c := range closes {
+		testData[i] = types.StockData{
+			Date:  time.Date(2023, 1, 1+i, 0, 0, 0, 0, time.UTC),
+			Close: c,
+		}
+	}
+
+	macd, signalLine, hist := CalculateMACD(testData, 2, 3, 2)
+
+	if len(macd) != len(testData) || len(signalLine) != len(testData) || len(hist) != len(testData) {
+		t.Fatalf("Expected length-aligned results, got %d, %d and %d for %d bars",
+			len(macd), len(signalLine), len(hist), len(testData))
+	}
+
+	// By hand, the 2-bar EMA (alpha 2/3) runs 10.5, 11.5, 12.5, 13.5, 79/6, 223/18 from
+	// index 1 and the 3-bar EMA (alpha 1/2) runs 11, 12, 13, 13, 12.5 from index 2. The
+	// signal line, a 2-bar EMA of the MACD seeded at index 3, runs 0.5, 0.5, 5/18, 1/54.
+	expectedMACD := []float64{0, 0, 0.5, 0.5, 0.5, 1.0 / 6, -1.0 / 9}
+	expectedSignal := []float64{0, 0, 0, 0.5, 0.5, 5.0 / 18, 1.0 / 54}
+	expectedHist := []float64{0, 0, 0, 0, 0, -1.0 / 9, -7.0 / 54}
+
+	for i := range testData {
+		if math.Abs(macd[i]-expectedMACD[i]) > 1e-9 {
+			t.Errorf("Expected MACD %.6f at index %d, got %.6f", expectedMACD[i], i, macd[i])
+		}
+		if math.Abs(signalLine[i]-expectedSignal[i]) > 1e-9 {
+			t.Errorf("Expected signal %.6f at index %d, got %.6f", expectedSignal[i], i, signalLine[i])
+		}
+		if math.Abs(hist[i]-expectedHist[i]) > 1e-9 {
+			t.Errorf("Expected histogram %.6f at index %d, got %.6f", expectedHist[i], i, hist[i])
+		}
+	}
+}
+
+func TestCalculateMACDInsufficientData(t *testing.T) {
+	testData := []types.StockData{
+		{Close: 100.0},
+		{Close: 101.0},
+	}
+
+	macd, signalLine, hist := CalculateMACD(testData, 12, 26, 9)
+	if len(macd) != len(testData) || len(signalLine) != len(testData) || len(hist) != len(testData) {
+		t.Fatalf("Expected %d values for insufficient data", len(testData))
+	}
+
+	for i := range testData {
+		if macd[i] != 0 || signalLine[i] != 0 || hist[i] != 0 {
+			t.Errorf("Expected zero values for insufficient data at index %d", i)
+		}
+	}
+}
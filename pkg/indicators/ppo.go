@@ -0,0 +1,41 @@
+package indicators
+
+import (
+	"swing-trader/internal/types"
+)
+
+// CalculatePPO calculates the Percentage Price Oscillator of the close, the gap between the
+// fast and slow EMAs as a percentage of the slow EMA, so it compares across price levels.
+// The signal line is an EMA of the PPO and the histogram is the PPO minus the signal line.
+// The PPO is valid from slow-1 and the signal line and histogram from slow+signal-2, with
+// zeros before.
+func CalculatePPO(data []types.StockData, fast, slow, signal int) (ppo, signalLine, hist []float64) {
+	ppo = make([]float64, len(data))
+	signalLine = make([]float64, len(data))
+	hist = make([]float64, len(data))
+
+	if fast <= 0 || slow < fast || signal <= 0 || len(data) < slow {
+		return ppo, signalLine, hist
+	}
+
+	closes := make([]float64, len(data))
+	for i, d := range data {
+		closes[i] = d.Close
+	}
+
+	fastEMA := calculateEMA(closes, 0, fast)
+	slowEMA := calculateEMA(closes, 0, slow)
+
+	for i := slow - 1; i < len(data); i++ {
+		if slowEMA[i] != 0 {
+			ppo[i] = (fastEMA[i] - slowEMA[i]) / slowEMA[i] * 100
+		}
+	}
+
+	signalLine = calculateEMA(ppo, slow-1, signal)
+	for i := slow + signal - 2; i < len(data); i++ {
+		hist[i] = ppo[i] - signalLine[i]
+	}
+
+	return ppo, signalLine, hist
+}
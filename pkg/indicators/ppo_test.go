@@ -0,0 +1,57 @@
+package indicators
+
+import (
+	"math"
+	"swing-trader/internal/types"
+	"testing"
+	"time"
+)
+
+func TestCalculatePPO(t *testing.T) {
+	closes := []float64{10, 11, 12, 13, 14, 13, 12}
+	testData := make([]types.StockData, len(closes))
+	for i, c := range closes {
+		testData[i] = types.StockData{
+			Date:  time.Date(2023, 1, 1+i, 0, 0, 0, 0, time.UTC),
+			Close: c,
+		}
+	}
+
+	ppo, signalLine, hist := CalculatePPO(testData, 2, 3, 2)
+
+	if len(ppo) != len(testData) || len(signalLine) != len(testData) || len(hist) != len(testData) {
+		t.Fatalf("Expected length-aligned results, got %d, %d and %d for %d bars",
+			len(ppo), len(signalLine), len(hist), len(testData))
+	}
+
+	// At index 3 the 2-bar EMA is 12.5 and the 3-bar EMA is 12
+	if expected := 0.5 / 12 * 100; math.Abs(ppo[3]-expected) > 1e-9 {
+		t.Errorf("Expected PPO %.6f at index 3, got %.6f", expected, ppo[3])
+	}
+
+	// The signal line is seeded at index 3 with the mean of the first two PPO values,
+	// the first at index 2 from EMAs of 11.5 and 11
+	if expected := (0.5/11*100 + 0.5/12*100) / 2; math.Abs(signalLine[3]-expected) > 1e-9 {
+		t.Errorf("Expected signal %.6f at index 3, got %.6f", expected, signalLine[3])
+	}
+
+	for i := 0; i < 2; i++ {
+		if ppo[i] != 0 {
+			t.Errorf("Expected zero PPO during warm-up at index %d, got %f", i, ppo[i])
+		}
+	}
+	if signalLine[2] != 0 || hist[2] != 0 {
+		t.Errorf("Expected zero signal and histogram at index 2, got %f and %f", signalLine[2], hist[2])
+	}
+
+	for i := 3; i < len(testData); i++ {
+		if math.Abs(hist[i]-(ppo[i]-signalLine[i])) > 1e-12 {
+			t.Errorf("Expected histogram to equal PPO minus signal at index %d, got %f", i, hist[i])
+		}
+	}
+
+	// Falling closes turn the histogram negative
+	if hist[len(hist)-1] >= 0 {
+		t.Errorf("Expected a negative histogram after the decline, got %f", hist[len(hist)-1])
+	}
+}
@@ -0,0 +1,90 @@
+package indicators
+
+import (
+	"math"
+)
+
+// RollingStats holds rolling window statistics over a series. Each slice is aligned
+// with the input and is zero for the first period-1 points.
+type RollingStats struct {
+	Mean   []float64
+	StdDev []float64 // population standard deviation of the window
+	Min    []float64
+	Max    []float64
+}
+
+// CalculateRollingStats calculates the rolling mean, standard deviation, minimum and
+// maximum over windows of period values in a single pass. The mean and the sum of squared
+// deviations from it follow a sliding Welford update, adding the incoming value and
+// removing the outgoing one, since subtracting the squared mean from the mean square
+// cancels badly for large, nearly constant prices. The update runs on values shifted by
+// the first one so the rounding of a large mean does not build up over a long series. The
+// extremes come from monotonic queues of window indices.
+func CalculateRollingStats(values []float64, period int) RollingStats {
+	stats := RollingStats{
+		Mean:   make([]float64, len(values)),
+		StdDev: make([]float64, len(values)),
+		Min:    make([]float64, len(values)),
+		Max:    make([]float64, len(values)),
+	}
+	if period <= 0 {
+		return stats
+	}
+
+	// Mean of the shifted values seen so far, up to a full window, and their sum of squared
+	// deviations from it
+	var shift float64
+	if len(values) > 0 {
+		shift = values[0]
+	}
+	mean, m2 := 0.0, 0.0
+
+	// Indices of candidate extremes, oldest first, with values increasing (minQueue)
+	// or decreasing (maxQueue) so the front is always the window's extreme
+	var minQueue, maxQueue []int
+
+	for i, v := range values {
+		incoming := v - shift
+		if i < period {
+			delta := incoming - mean
+			mean += delta / float64(i+1)
+			m2 += delta * (incoming - mean)
+		} else {
+			// Swap the outgoing value for the incoming one in a full window
+			outgoing := values[i-period] - shift
+			previous := mean
+			mean += (incoming - outgoing) / float64(period)
+			m2 += (incoming - outgoing) * (incoming - mean + outgoing - previous)
+		}
+		if m2 < 0 {
+			m2 = 0
+		}
+
+		for len(minQueue) > 0 && values[minQueue[len(minQueue)-1]] >= v {
+			minQueue = minQueue[:len(minQueue)-1]
+		}
+		minQueue = append(minQueue, i)
+		if minQueue[0] <= i-period {
+			minQueue = minQueue[1:]
+		}
+
+		for len(maxQueue) > 0 && values[maxQueue[len(maxQueue)-1]] <= v {
+			maxQueue = maxQueue[:len(maxQueue)-1]
+		}
+		maxQueue = append(maxQueue, i)
+		if maxQueue[0] <= i-period {
+			maxQueue = maxQueue[1:]
+		}
+
+		if i < period-1 {
+			continue
+		}
+
+		stats.Mean[i] = mean + shift
+		stats.StdDev[i] = math.Sqrt(m2 / float64(period))
+		stats.Min[i] = values[minQueue[0]]
+		stats.Max[i] = values[maxQueue[0]]
+	}
+
+	return stats
+}
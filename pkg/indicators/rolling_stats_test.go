@@ -0,0 +1,117 @@
+package indicators
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCalculateRollingStatsMatchesNaive(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	values := make([]float64, 300)
+	for i := range values {
+		values[i] = 50 + rng.NormFloat64()*10
+	}
+
+	for _, period := range []int{1, 3, 10, 50} {
+		stats := CalculateRollingStats(values, period)
+
+		for i := range values {
+			if i < period-1 {
+				if stats.Mean[i] != 0 || stats.StdDev[i] != 0 || stats.Min[i] != 0 || stats.Max[i] != 0 {
+					t.Errorf("Period %d: expected zeros during warm-up at index %d", period, i)
+				}
+				continue
+			}
+
+			// Recompute the window from scratch
+			window := values[i-period+1 : i+1]
+			mean, minimum, maximum := 0.0, math.Inf(1), math.Inf(-1)
+			for _, v := range window {
+				mean += v
+				minimum = math.Min(minimum, v)
+				maximum = math.Max(maximum, v)
+			}
+			mean /= float64(period)
+
+			variance := 0.0
+			for _, v := range window {
+				variance += (v - mean) * (v - mean)
+			}
+			variance /= float64(period)
+
+			if math.Abs(stats.Mean[i]-mean) > 1e-9 {
+				t.Errorf("Period %d: mean at index %d is %f, expected %f", period, i, stats.Mean[i], mean)
+			}
+			// Compare variances, since the square root magnifies rounding near zero
+			if math.Abs(stats.StdDev[i]*stats.StdDev[i]-variance) > 1e-8 {
+				t.Errorf("Period %d: std dev at index %d is %f, expected %f", period, i, stats.StdDev[i], math.Sqrt(variance))
+			}
+			if stats.Min[i] != minimum {
+				t.Errorf("Period %d: min at index %d is %f, expected %f", period, i, stats.Min[i], minimum)
+			}
+			if stats.Max[i] != maximum {
+				t.Errorf("Period %d: max at index %d is %f, expected %f", period, i, stats.Max[i], maximum)
+			}
+		}
+	}
+}
+
+func TestCalculateRollingStatsFlatWindow(t *testing.T) {
+	// A constant window must not produce a NaN standard deviation from rounding
+	values := []float64{0.1, 0.1, 0.1, 0.1, 0.1, 0.1}
+
+	stats := CalculateRollingStats(values, 3)
+	for i := 2; i < len(values); i++ {
+		if math.IsNaN(stats.StdDev[i]) || stats.StdDev[i] > 1e-9 {
+			t.Errorf("Expected zero std dev for a flat window at index %d, got %v", i, stats.StdDev[i])
+		}
+	}
+}
+
+func TestCalculateRollingStatsLongSeriesPrecision(t *testing.T) {
+	// Prices near 1e8 moving by about 1 over a long series. The squares reach 1e16, where a
+	// float64 resolves only about 2, so a running sum of squares loses the variance entirely.
+	rng := rand.New(rand.NewSource(11))
+	values := make([]float64, 200000)
+	for i := range values {
+		values[i] = 1e8 + rng.NormFloat64()
+	}
+	period := 50
+
+	stats := CalculateRollingStats(values, period)
+
+	var naiveMisses int
+	for i := period - 1; i < len(values); i += 997 {
+		window := values[i-period+1 : i+1]
+		sum, sumSquares := 0.0, 0.0
+		for _, v := range window {
+			sum += v
+			sumSquares += v * v
+		}
+		mean := sum / float64(period)
+
+		variance := 0.0
+		for _, v := range window {
+			variance += (v - mean) * (v - mean)
+		}
+		variance /= float64(period)
+
+		if math.Abs(stats.StdDev[i]*stats.StdDev[i]-variance) > 1e-6*variance {
+			t.Errorf("Std dev at index %d is %f, expected %f", i, stats.StdDev[i], math.Sqrt(variance))
+		}
+		if math.Abs(stats.Mean[i]-mean) > 1e-6 {
+			t.Errorf("Mean at index %d is %f, expected %f", i, stats.Mean[i], mean)
+		}
+
+		naive := sumSquares/float64(period) - mean*mean
+		if math.Abs(naive-variance) > 1e-6*variance {
+			naiveMisses++
+		}
+	}
+
+	// The series must be one the naive sum of squares gets wrong for the check to mean anything
+	if naiveMisses == 0 {
+		t.Error("Expected the naive sum of squares to lose precision on this series")
+	}
+}
@@ -4,19 +4,44 @@ import (
 	"swing-trader/internal/types"
 )
 
-// CalculateRSI calculates the Relative Strength Index for given stock data
+// CalculateRSI calculates the Relative Strength Index for given stock data using
+// Wilder's smoothing
 func CalculateRSI(data []types.StockData, period int) []float64 {
-	if len(data) < period+1 {
-		return make([]float64, len(data))
+	return CalculateRSIWithSmoothing(data, period, "wilder")
+}
+
+// CalculateRSIWithSmoothing calculates the Relative Strength Index, averaging gains and
+// losses with the given smoothing: "wilder" (the default), "ema" or "sma". All three
+// seed with the simple average of the first period changes, so they agree on the first
+// valid point at index period and diverge afterwards.
+func CalculateRSIWithSmoothing(data []types.StockData, period int, smoothing string) []float64 {
+	closes := make([]float64, len(data))
+	for i, d := range data {
+		closes[i] = d.Close
+	}
+
+	return calculateSmoothedRSI(closes, period, smoothing)
+}
+
+// calculateRSIValues calculates the Relative Strength Index over an arbitrary series
+func calculateRSIValues(values []float64, period int) []float64 {
+	return calculateSmoothedRSI(values, period, "wilder")
+}
+
+// calculateSmoothedRSI calculates the Relative Strength Index over an arbitrary series
+// with the given smoothing of the average gain and loss
+func calculateSmoothedRSI(values []float64, period int, smoothing string) []float64 {
+	if period <= 0 || len(values) < period+1 {
+		return make([]float64, len(values))
 	}
 
-	rsiValues := make([]float64, len(data))
-	gains := make([]float64, len(data))
-	losses := make([]float64, len(data))
+	rsiValues := make([]float64, len(values))
+	gains := make([]float64, len(values))
+	losses := make([]float64, len(values))
 
 	// Calculate price changes
-	for i := 1; i < len(data); i++ {
-		change := data[i].Close - data[i-1].Close
+	for i := 1; i < len(values); i++ {
+		change := values[i] - values[i-1]
 		if change > 0 {
 			gains[i] = change
 			losses[i] = 0
@@ -36,25 +61,35 @@ func CalculateRSI(data []types.StockData, period int) []float64 {
 	avgLoss /= float64(period)
 
 	// Calculate RSI for the first valid point
-	if avgLoss == 0 {
-		rsiValues[period] = 100
-	} else {
-		rs := avgGain / avgLoss
-		rsiValues[period] = 100 - (100 / (1 + rs))
-	}
+	rsiValues[period] = rsiFromAverages(avgGain, avgLoss)
 
 	// Calculate RSI for subsequent points using smoothed averages
-	for i := period + 1; i < len(data); i++ {
-		avgGain = (avgGain*float64(period-1) + gains[i]) / float64(period)
-		avgLoss = (avgLoss*float64(period-1) + losses[i]) / float64(period)
-
-		if avgLoss == 0 {
-			rsiValues[i] = 100
-		} else {
-			rs := avgGain / avgLoss
-			rsiValues[i] = 100 - (100 / (1 + rs))
+	alpha := 2 / float64(period+1)
+	for i := period + 1; i < len(values); i++ {
+		switch smoothing {
+		case "ema":
+			avgGain += alpha * (gains[i] - avgGain)
+			avgLoss += alpha * (losses[i] - avgLoss)
+		case "sma":
+			// Slide the window of the last period changes
+			avgGain += (gains[i] - gains[i-period]) / float64(period)
+			avgLoss += (losses[i] - losses[i-period]) / float64(period)
+		default:
+			avgGain = (avgGain*float64(period-1) + gains[i]) / float64(period)
+			avgLoss = (avgLoss*float64(period-1) + losses[i]) / float64(period)
 		}
+
+		rsiValues[i] = rsiFromAverages(avgGain, avgLoss)
 	}
 
 	return rsiValues
 }
+
+// rsiFromAverages converts an average gain and loss into an RSI value
+func rsiFromAverages(avgGain, avgLoss float64) float64 {
+	if avgLoss <= 0 {
+		return 100
+	}
+	rs := avgGain / avgLoss
+	return 100 - (100 / (1 + rs))
+}
@@ -1,6 +1,7 @@
 package indicators
 
 import (
+	"math"
 	"swing-trader/internal/types"
 	"testing"
 	"time"
@@ -41,3 +42,49 @@ func TestCalculateRSI(t *testing.T) {
 		t.Errorf("Expected last RSI to be %.2f, got %.2f", expectedRSI, lastRSI)
 	}
 }
+
+func TestCalculateRSIWithSmoothing(t *testing.T) {
+	closes := []float64{10, 11, 10.5, 11.5, 12, 11}
+	testData := make([]types.StockData, len(closes))
+	for i, c := range closes {
+		testData[i] = types.StockData{
+			Date:  time.Date(2023, 1, 1+i, 0, 0, 0, 0, time.UTC),
+			Close: c,
+		}
+	}
+
+	// Gains 1, 0, 1, 0.5, 0 and losses 0, 0.5, 0, 0, 1 after the first close.
+	// All methods seed with averages of 2/3 and 1/6 (RS of 4, RSI of 80) at index 3.
+	// At index 4 (a 0.5 gain):
+	//   wilder: gain (2/3*2 + 0.5)/3 = 11/18, loss (1/6*2)/3 = 1/9, RS 5.5
+	//   ema:    alpha 0.5, gain 7/12, loss 1/12, RS 7
+	//   sma:    last three changes, gain 1.5/3, loss 0.5/3, RS 3
+	tests := []struct {
+		smoothing string
+		expected  float64
+	}{
+		{"wilder", 100 - 100/(1+5.5)},
+		{"ema", 100 - 100/(1+7.0)},
+		{"sma", 100 - 100/(1+3.0)},
+	}
+
+	for _, tt := range tests {
+		rsi := CalculateRSIWithSmoothing(testData, 3, tt.smoothing)
+
+		if math.Abs(rsi[3]-80) > 1e-9 {
+			t.Errorf("%s: expected the seeded RSI of 80 at index 3, got %f", tt.smoothing, rsi[3])
+		}
+		if math.Abs(rsi[4]-tt.expected) > 1e-9 {
+			t.Errorf("%s: expected RSI %f at index 4, got %f", tt.smoothing, tt.expected, rsi[4])
+		}
+	}
+
+	// Wilder remains the default
+	wilder := CalculateRSIWithSmoothing(testData, 3, "wilder")
+	standard := CalculateRSI(testData, 3)
+	for i := range wilder {
+		if wilder[i] != standard[i] {
+			t.Errorf("Expected CalculateRSI to use Wilder smoothing, mismatch at index %d", i)
+		}
+	}
+}
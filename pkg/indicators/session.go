@@ -0,0 +1,14 @@
+package indicators
+
+import (
+	"time"
+)
+
+// newSession reports whether cur starts a new trading session after prev. Sessions are
+// keyed off the calendar date in each timestamp's own location, so intraday bars from
+// consecutive days fall into separate sessions.
+func newSession(prev, cur time.Time) bool {
+	py, pm, pd := prev.Date()
+	cy, cm, cd := cur.Date()
+	return py != cy || pm != cm || pd != cd
+}
@@ -0,0 +1,38 @@
+package indicators
+
+import (
+	"swing-trader/internal/types"
+)
+
+// CalculateSMA calculates the simple moving average of the close over period bars,
+// returning zeros for the first period-1 points like the Bollinger middle band
+func CalculateSMA(data []types.StockData, period int) []float64 {
+	closes := make([]float64, len(data))
+	for i, d := range data {
+		closes[i] = d.Close
+	}
+
+	return calculateSMA(closes, period)
+}
+
+// calculateSMA calculates a simple moving average over an arbitrary series,
+// returning zeros for the first period-1 points
+func calculateSMA(values []float64, period int) []float64 {
+	smaValues := make([]float64, len(values))
+	if period <= 0 {
+		return smaValues
+	}
+
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			smaValues[i] = sum / float64(period)
+		}
+	}
+
+	return smaValues
+}
@@ -0,0 +1,29 @@
+package indicators
+
+import (
+	"testing"
+)
+
+func TestCalculateSMA(t *testing.T) {
+	sma := CalculateSMA(closeSeries(1, 2, 3, 4, 5, 6), 3)
+
+	expected := []float64{0, 0, 2, 3, 4, 5}
+	if len(sma) != len(expected) {
+		t.Fatalf("Expected SMA length %d, got %d", len(expected), len(sma))
+	}
+
+	for i := range expected {
+		if sma[i] != expected[i] {
+			t.Errorf("Expected SMA %.2f at index %d, got %.2f", expected[i], i, sma[i])
+		}
+	}
+}
+
+func TestCalculateSMAInsufficientData(t *testing.T) {
+	sma := CalculateSMA(closeSeries(10, 20), 5)
+	for i, v := range sma {
+		if v != 0 {
+			t.Errorf("Expected zero for insufficient data at index %d, got %.2f", i, v)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package indicators
+
+import (
+	"math"
+	"swing-trader/internal/types"
+)
+
+// CalculateStdErrorBands calculates Standard Error Bands around a rolling linear regression of
+// the close. The middle line is the fit's value at each window's last bar and the bands sit
+// mult standard errors of the regression either side, the residual spread with two degrees of
+// freedom taken by the slope and intercept. They hug a steady trend more tightly than Bollinger
+// Bands, which widen with the trend itself. Values are zero before index period-1.
+func CalculateStdErrorBands(data []types.StockData, period int, mult float64) (mid, upper, lower []float64) {
+	mid = make([]float64, len(data))
+	upper = make([]float64, len(data))
+	lower = make([]float64, len(data))
+
+	// The standard error needs more points than the two fitted parameters
+	if period < 3 || len(data) < period {
+		return mid, upper, lower
+	}
+
+	for i := period - 1; i < len(data); i++ {
+		end, sumSquares := fitLine(data[i-period+1 : i+1])
+		stdError := math.Sqrt(sumSquares / float64(period-2))
+
+		mid[i] = end
+		upper[i] = mid[i] + mult*stdError
+		lower[i] = mid[i] - mult*stdError
+	}
+
+	return mid, upper, lower
+}
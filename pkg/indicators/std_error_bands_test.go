@@ -0,0 +1,50 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalculateStdErrorBandsLinearSeries(t *testing.T) {
+	closes := make([]float64, 40)
+	for i := range closes {
+		closes[i] = 80 - 0.75*float64(i)
+	}
+	data := closeSeries(closes...)
+
+	period := 14
+	mid, upper, lower := CalculateStdErrorBands(data, period, 2.0)
+
+	if len(mid) != len(data) || len(upper) != len(data) || len(lower) != len(data) {
+		t.Fatalf("Expected length-aligned results, got %d, %d and %d for %d bars", len(mid), len(upper), len(lower), len(data))
+	}
+
+	for i := 0; i < period-1; i++ {
+		if mid[i] != 0 || upper[i] != 0 || lower[i] != 0 {
+			t.Errorf("Expected zeros during warm-up at index %d", i)
+		}
+	}
+
+	// A perfect line has no regression error, so the bands close onto the regression line
+	for i := period - 1; i < len(data); i++ {
+		if math.Abs(mid[i]-closes[i]) > 1e-9 {
+			t.Errorf("Expected the middle line at the close %.2f at index %d, got %.6f", closes[i], i, mid[i])
+		}
+		if upper[i]-mid[i] > 1e-9 || mid[i]-lower[i] > 1e-9 {
+			t.Errorf("Expected the bands on the regression line at index %d, got %.9f to %.9f", i, lower[i], upper[i])
+		}
+	}
+}
+
+func TestCalculateStdErrorBandsStandardError(t *testing.T) {
+	// The fit of 1, 3, 2 is 1.5 + 0.5x with squared residuals summing to 1.5 over one degree of freedom
+	mid, upper, lower := CalculateStdErrorBands(closeSeries(1, 3, 2), 3, 1.0)
+
+	stdError := math.Sqrt(1.5)
+	if math.Abs(mid[2]-2.5) > 1e-9 {
+		t.Errorf("Expected the middle line at 2.5, got %.6f", mid[2])
+	}
+	if math.Abs(upper[2]-(2.5+stdError)) > 1e-9 || math.Abs(lower[2]-(2.5-stdError)) > 1e-9 {
+		t.Errorf("Expected the bands at 2.5 ± %.6f, got %.6f to %.6f", stdError, lower[2], upper[2])
+	}
+}
@@ -0,0 +1,36 @@
+package indicators
+
+import (
+	"swing-trader/internal/types"
+)
+
+// CalculateStochastic calculates the stochastic oscillator %K, where the close sits within
+// the range from the lowest low to the highest high of the last period bars, from 0 at the
+// low to 100 at the high. A window with no range reads 50. Values are zero before index
+// period-1.
+func CalculateStochastic(data []types.StockData, period int) []float64 {
+	stochValues := make([]float64, len(data))
+	if period <= 0 || len(data) < period {
+		return stochValues
+	}
+
+	highs := make([]float64, len(data))
+	lows := make([]float64, len(data))
+	for i, d := range data {
+		highs[i] = d.High
+		lows[i] = d.Low
+	}
+
+	highest := CalculateRollingStats(highs, period).Max
+	lowest := CalculateRollingStats(lows, period).Min
+
+	for i := period - 1; i < len(data); i++ {
+		if span := highest[i] - lowest[i]; span > 0 {
+			stochValues[i] = (data[i].Close - lowest[i]) / span * 100
+		} else {
+			stochValues[i] = 50
+		}
+	}
+
+	return stochValues
+}
@@ -0,0 +1,43 @@
+package indicators
+
+import (
+	"math"
+	"swing-trader/internal/types"
+	"testing"
+	"time"
+)
+
+func TestCalculateStochastic(t *testing.T) {
+	bars := []struct{ high, low, close float64 }{
+		{11, 9, 10},
+		{12, 10, 11},
+		{13, 11, 12},
+		{12, 8, 9},
+		{10, 8, 10},
+	}
+	data := make([]types.StockData, len(bars))
+	for i, b := range bars {
+		data[i] = types.StockData{
+			Date:  time.Date(2023, 1, 1+i, 0, 0, 0, 0, time.UTC),
+			High:  b.high,
+			Low:   b.low,
+			Close: b.close,
+		}
+	}
+
+	stoch := CalculateStochastic(data, 3)
+
+	// Each window's close against its lowest low and highest high
+	expected := []float64{0, 0, 75, 20, 40}
+	for i := range expected {
+		if math.Abs(stoch[i]-expected[i]) > 1e-9 {
+			t.Errorf("Expected %%K %.2f at index %d, got %.2f", expected[i], i, stoch[i])
+		}
+	}
+
+	// A window with no range reads the midpoint
+	flat := CalculateStochastic(closeSeries(10, 10, 10), 3)
+	if flat[2] != 50 {
+		t.Errorf("Expected %%K 50 with no range, got %.2f", flat[2])
+	}
+}
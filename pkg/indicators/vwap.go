@@ -0,0 +1,32 @@
+package indicators
+
+import (
+	"swing-trader/internal/types"
+)
+
+// CalculateVWAP calculates the volume-weighted average of the typical price (high + low + close) / 3.
+// With sessionReset the running sums restart on the first bar of each date, giving the usual
+// intraday session VWAP; otherwise the average is cumulative over all data. Bars before any
+// volume has traded take their own typical price.
+func CalculateVWAP(data []types.StockData, sessionReset bool) []float64 {
+	vwapValues := make([]float64, len(data))
+
+	var priceVolume, volume float64
+	for i, d := range data {
+		if sessionReset && i > 0 && newSession(data[i-1].Date, d.Date) {
+			priceVolume, volume = 0, 0
+		}
+
+		typicalPrice := (d.High + d.Low + d.Close) / 3
+		priceVolume += typicalPrice * float64(d.Volume)
+		volume += float64(d.Volume)
+
+		if volume > 0 {
+			vwapValues[i] = priceVolume / volume
+		} else {
+			vwapValues[i] = typicalPrice
+		}
+	}
+
+	return vwapValues
+}
@@ -0,0 +1,52 @@
+package indicators
+
+import (
+	"math"
+	"swing-trader/internal/types"
+	"testing"
+	"time"
+)
+
+func TestCalculateVWAPSessionReset(t *testing.T) {
+	bar := func(day, hour int, price float64, volume int64) types.StockData {
+		return types.StockData{
+			Date:   time.Date(2023, 1, day, hour, 0, 0, 0, time.UTC),
+			High:   price,
+			Low:    price,
+			Close:  price,
+			Volume: volume,
+		}
+	}
+
+	// Two sessions of hourly bars
+	data := []types.StockData{
+		bar(2, 10, 100, 100),
+		bar(2, 11, 102, 300),
+		bar(2, 15, 104, 100),
+		bar(3, 10, 110, 200),
+		bar(3, 11, 112, 200),
+	}
+
+	vwap := CalculateVWAP(data, true)
+
+	expected := []float64{
+		100,
+		(100*100 + 102*300) / 400.0,
+		(100*100 + 102*300 + 104*100) / 500.0,
+		110, // first bar of the second day starts a new session
+		(110*200 + 112*200) / 400.0,
+	}
+
+	for i := range expected {
+		if math.Abs(vwap[i]-expected[i]) > 1e-9 {
+			t.Errorf("Expected session VWAP at index %d to be %.4f, got %.4f", i, expected[i], vwap[i])
+		}
+	}
+
+	// Without the reset the second day keeps accumulating the first day's volume
+	cumulative := CalculateVWAP(data, false)
+	expectedCumulative := (100*100 + 102*300 + 104*100 + 110*200) / 700.0
+	if math.Abs(cumulative[3]-expectedCumulative) > 1e-9 {
+		t.Errorf("Expected cumulative VWAP at index 3 to be %.4f, got %.4f", expectedCumulative, cumulative[3])
+	}
+}
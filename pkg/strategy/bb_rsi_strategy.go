@@ -1,6 +1,9 @@
 package strategy
 
 import (
+	"fmt"
+	"log/slog"
+	"math"
 	"swing-trader/internal/types"
 	"swing-trader/pkg/indicators"
 )
@@ -19,32 +22,137 @@ func NewBBRSIStrategy(config types.StrategyConfig) *BBRSIStrategy {
 
 // GenerateSignals generates buy/sell signals based on Bollinger Bands and RSI
 func (s *BBRSIStrategy) GenerateSignals(data []types.StockData) []types.Signal {
-	if len(data) < s.config.BBPeriod || len(data) < s.config.RSIPeriod {
+	if len(data) <= s.startIndex() {
 		return []types.Signal{}
 	}
 
 	// Calculate indicators
-	bollingerBands := indicators.CalculateBollingerBands(data, s.config.BBPeriod, s.config.BBStdDev)
-	rsiValues := indicators.CalculateRSI(data, s.config.RSIPeriod)
+	bollingerBands := cached(s.config.IndicatorCache, fmt.Sprintf("bb/%d/%g", s.config.BBPeriod, s.config.BBStdDev), data, func() []types.BollingerBands {
+		return indicators.CalculateBollingerBands(data, s.config.BBPeriod, s.config.BBStdDev)
+	})
+	rsiValues := cached(s.config.IndicatorCache, fmt.Sprintf("rsi/%d/%s", s.config.RSIPeriod, s.config.RSISmoothing), data, func() []float64 {
+		return indicators.CalculateRSIWithSmoothing(data, s.config.RSIPeriod, s.config.RSISmoothing)
+	})
 
-	var signals []types.Signal
-	
-	// Start from the maximum of the two periods to ensure both indicators are valid
-	startIndex := s.config.BBPeriod
-	if s.config.RSIPeriod > s.config.BBPeriod {
-		startIndex = s.config.RSIPeriod
+	var atrValues []float64
+	if s.config.StopMode == "atr" {
+		atrValues = cached(s.config.IndicatorCache, fmt.Sprintf("atr/%d", s.config.ATRPeriod), data, func() []float64 {
+			return indicators.CalculateATR(data, s.config.ATRPeriod)
+		})
+	}
+
+	var trendSMA []float64
+	if s.config.TrendFilterPeriod > 0 {
+		trendSMA = cached(s.config.IndicatorCache, fmt.Sprintf("sma/%d", s.config.TrendFilterPeriod), data, func() []float64 {
+			return indicators.CalculateSMA(data, s.config.TrendFilterPeriod)
+		})
 	}
 
-	for i := startIndex; i < len(data); i++ {
+	// The RSI exit compares against a flat line at the exit level
+	var exitLevels []float64
+	if s.config.RSIExitLevel > 0 {
+		exitLevels = make([]float64, len(data))
+		for i := range exitLevels {
+			exitLevels[i] = s.config.RSIExitLevel
+		}
+	}
+
+	var signals []types.Signal
+	buyStreak := 0
+	exitArmed := false
+
+	for i := s.startIndex(); i < len(data); i++ {
 		signal := s.evaluatePosition(data[i], bollingerBands[i], rsiValues[i])
+
+		// Only buy the dip while the longer-term trend is up
+		if signal.Type == "BUY" && trendSMA != nil && data[i].Close <= trendSMA[i] {
+			signal = types.Signal{Date: data[i].Date, Price: data[i].Close, Type: "HOLD"}
+		}
+
+		// After an entry, take the reversion at the opposite band with a limit order at it
+		if signal.Type == "HOLD" && exitArmed && s.config.ExitOnUpperBand && data[i].High >= bollingerBands[i].Upper {
+			signal.Type = "SELL"
+			signal.Reason = "Price reached upper BB"
+			signal.OrderType = "limit"
+			signal.LimitPrice = bollingerBands[i].Upper
+		}
+
+		// After an entry, exit early once RSI reverts up through the exit level
+		if signal.Type == "HOLD" && exitArmed && exitLevels != nil && indicators.CrossOver(rsiValues, exitLevels, i) {
+			signal.Type = "SELL"
+			signal.Reason = "RSI reverted to exit level"
+		}
+
+		// Only act on a BUY once the condition has persisted for the confirming bars
+		if signal.Type == "BUY" {
+			buyStreak++
+			if buyStreak <= s.config.ConfirmBars {
+				continue
+			}
+		} else {
+			buyStreak = 0
+		}
+
+		// Skip entries after a large overnight gap, which often fills against the position
+		if signal.Type == "BUY" && i > 0 && s.gapTooLarge(data[i-1], data[i]) {
+			continue
+		}
+
+		if signal.Type == "BUY" && atrValues != nil {
+			signal.StopDistance = atrValues[i] * s.config.ATRMultiplier
+		}
 		if signal.Type != "HOLD" {
 			signals = append(signals, signal)
+			exitArmed = signal.Type == "BUY"
 		}
 	}
 
 	return signals
 }
 
+// cached returns the indicator series from the cache, computing it on a miss, or computes
+// it directly without a cache
+func cached[T any](cache types.IndicatorCache, key string, data []types.StockData, compute func() T) T {
+	if cache == nil {
+		return compute()
+	}
+	return cache.Get(key, data, func() any { return compute() }).(T)
+}
+
+// gapTooLarge reports whether the bar opened further from the previous close than
+// MaxEntryGapPct allows, in either direction
+func (s *BBRSIStrategy) gapTooLarge(prev, bar types.StockData) bool {
+	if s.config.MaxEntryGapPct <= 0 || prev.Close <= 0 || bar.Open <= 0 {
+		return false
+	}
+	return math.Abs(bar.Open-prev.Close)/prev.Close > s.config.MaxEntryGapPct
+}
+
+// MinDataPoints returns the number of bars needed before the first signal can be evaluated
+func (s *BBRSIStrategy) MinDataPoints() int {
+	return s.startIndex() + 1
+}
+
+// startIndex returns the first bar index where every indicator used is valid.
+// Bollinger Bands and the trend filter SMA are valid from period-1, while RSI and
+// ATR need the previous close and are valid from period.
+func (s *BBRSIStrategy) startIndex() int {
+	startIndex := s.config.BBPeriod - 1
+	if s.config.RSIPeriod > startIndex {
+		startIndex = s.config.RSIPeriod
+	}
+	if s.config.StopMode == "atr" && s.config.ATRPeriod > startIndex {
+		startIndex = s.config.ATRPeriod
+	}
+	if s.config.TrendFilterPeriod-1 > startIndex {
+		startIndex = s.config.TrendFilterPeriod - 1
+	}
+	if startIndex < 0 {
+		startIndex = 0
+	}
+	return startIndex
+}
+
 // evaluatePosition evaluates whether to buy, sell, or hold based on current conditions
 func (s *BBRSIStrategy) evaluatePosition(stockData types.StockData, bb types.BollingerBands, rsi float64) types.Signal {
 	signal := types.Signal{
@@ -54,14 +162,33 @@ func (s *BBRSIStrategy) evaluatePosition(stockData types.StockData, bb types.Bol
 	}
 
 	// Buy signal: price is below lower Bollinger Band AND RSI is below buy threshold
-	if stockData.Close < bb.Lower && rsi < s.config.BuyThreshold {
+	buy := s.belowLowerBand(stockData, bb) && rsi < s.config.BuyThreshold
+
+	// Sell signal: RSI is above sell threshold (overbought)
+	sell := rsi > s.config.SellThreshold
+
+	if buy && sell {
+		priority := s.signalPriority()
+		s.logger().Warn("conflicting BUY and SELL conditions",
+			"date", stockData.Date.Format("2006-01-02"),
+			"rsi", rsi,
+			"priority", priority)
+		if priority == "sell" {
+			buy = false
+		} else {
+			sell = false
+		}
+	}
+
+	if buy {
 		signal.Type = "BUY"
 		signal.Reason = "Price below lower BB and RSI oversold"
+		signal.Tag = "bb_rsi"
+		signal.Strength = s.buyStrength(stockData, bb, rsi)
 		return signal
 	}
 
-	// Sell signal: RSI is above sell threshold (overbought)
-	if rsi > s.config.SellThreshold {
+	if sell {
 		signal.Type = "SELL"
 		signal.Reason = "RSI overbought"
 		return signal
@@ -70,13 +197,56 @@ func (s *BBRSIStrategy) evaluatePosition(stockData types.StockData, bb types.Bol
 	return signal
 }
 
-// CalculatePositionSize calculates the number of shares to buy based on available capital and risk management
-func (s *BBRSIStrategy) CalculatePositionSize(availableCapital, currentPrice float64, riskConfig types.RiskManagementConfig) int64 {
+// buyStrength rates a BUY setup from 0 to 1 as the average of how far RSI sits below the
+// buy threshold, relative to the threshold, and how far the close sits below the lower
+// band, relative to the distance between the lower band and the middle band
+func (s *BBRSIStrategy) buyStrength(stockData types.StockData, bb types.BollingerBands, rsi float64) float64 {
+	var rsiDepth, bandDepth float64
+	if s.config.BuyThreshold > 0 {
+		rsiDepth = (s.config.BuyThreshold - rsi) / s.config.BuyThreshold
+	}
+	if width := bb.Middle - bb.Lower; width > 0 {
+		bandDepth = (bb.Lower - stockData.Close) / width
+	}
+
+	clamp := func(v float64) float64 {
+		return math.Max(0, math.Min(1, v))
+	}
+	return (clamp(rsiDepth) + clamp(bandDepth)) / 2
+}
+
+// belowLowerBand reports whether the bar breaches the lower band, either by touching it
+// with the bar's low ("touch") or by closing below it ("close", the default)
+func (s *BBRSIStrategy) belowLowerBand(stockData types.StockData, bb types.BollingerBands) bool {
+	if s.config.EntryTrigger == "touch" {
+		return stockData.Low <= bb.Lower
+	}
+	return stockData.Close < bb.Lower
+}
+
+// logger returns the configured logger, or the default logger when none is set
+func (s *BBRSIStrategy) logger() *slog.Logger {
+	if s.config.Logger != nil {
+		return s.config.Logger
+	}
+	return slog.Default()
+}
+
+// signalPriority returns the signal that wins when both BUY and SELL conditions are met
+func (s *BBRSIStrategy) signalPriority() string {
+	if s.config.SignalPriority == "sell" {
+		return "sell"
+	}
+	return "buy"
+}
+
+// CalculatePositionSize calculates the number of shares to buy based on available capital and risk management.
+// The risk per share is the distance to the trade's actual stop price, whichever stop mode produced it.
+func (s *BBRSIStrategy) CalculatePositionSize(availableCapital, currentPrice, stopLossPrice float64, riskConfig types.RiskManagementConfig) int64 {
 	// Calculate position size based on risk percentage
 	riskAmount := availableCapital * riskConfig.PositionSize
 	
 	// Calculate shares based on stop loss risk
-	stopLossPrice := currentPrice * (1 - s.config.StopLoss)
 	riskPerShare := currentPrice - stopLossPrice
 	
 	if riskPerShare <= 0 {
@@ -90,6 +260,14 @@ func (s *BBRSIStrategy) CalculatePositionSize(availableCapital, currentPrice flo
 	if totalCost > availableCapital {
 		shares = int64(availableCapital / currentPrice)
 	}
+
+	// Clamp to the configured share range, zero leaves that side unbounded
+	if riskConfig.MinShares > 0 && shares < riskConfig.MinShares {
+		shares = riskConfig.MinShares
+	}
+	if riskConfig.MaxShares > 0 && shares > riskConfig.MaxShares {
+		shares = riskConfig.MaxShares
+	}
 	
 	return shares
 }
@@ -103,3 +281,36 @@ func (s *BBRSIStrategy) GetStopLossPrice(entryPrice float64) float64 {
 func (s *BBRSIStrategy) GetTakeProfitPrice(entryPrice float64) float64 {
 	return entryPrice * (1 + s.config.TakeProfit)
 }
+
+// GetTargetPrice calculates the final take profit for a trade, TargetR multiples of the
+// entry-to-stop risk above the entry when configured and the percentage target otherwise
+func (s *BBRSIStrategy) GetTargetPrice(entryPrice, stopLossPrice float64) float64 {
+	if s.config.TargetR > 0 {
+		return GetRMultiplePrice(entryPrice, stopLossPrice, s.config.TargetR)
+	}
+	return s.GetTakeProfitPrice(entryPrice)
+}
+
+// GetPartialTargetPrice calculates the price for taking a partial profit, or 0 when
+// partial profits are disabled
+func (s *BBRSIStrategy) GetPartialTargetPrice(entryPrice, stopLossPrice float64) float64 {
+	if s.config.PartialTargetR <= 0 || s.config.PartialFraction <= 0 {
+		return 0
+	}
+	return GetRMultiplePrice(entryPrice, stopLossPrice, s.config.PartialTargetR)
+}
+
+// GetLadderPrice calculates the price that fills the take-profit ladder level at step,
+// or 0 when the ladder has no such level
+func (s *BBRSIStrategy) GetLadderPrice(entryPrice float64, step int) float64 {
+	if step < 0 || step >= len(s.config.TakeProfitLadder) {
+		return 0
+	}
+	return entryPrice * (1 + s.config.TakeProfitLadder[step].Gain)
+}
+
+// GetRMultiplePrice returns the price r multiples of the initial risk, the distance from
+// entry to stop, above the entry
+func GetRMultiplePrice(entryPrice, stopLossPrice, r float64) float64 {
+	return entryPrice + r*(entryPrice-stopLossPrice)
+}
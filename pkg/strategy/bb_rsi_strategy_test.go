@@ -0,0 +1,478 @@
+package strategy
+
+import (
+	"bytes"
+	"log/slog"
+	"math"
+	"strings"
+	"swing-trader/internal/types"
+	"swing-trader/pkg/indicators"
+	"testing"
+	"time"
+)
+
+func TestEvaluatePositionSignalPriority(t *testing.T) {
+	// Thresholds overlap so an RSI of 50 is both oversold and overbought
+	config := types.StrategyConfig{
+		BuyThreshold:  60.0,
+		SellThreshold: 40.0,
+	}
+
+	bar := types.StockData{
+		Date:  time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		Close: 95.0,
+	}
+	bb := types.BollingerBands{Upper: 110.0, Middle: 100.0, Lower: 98.0}
+	rsi := 50.0
+
+	tests := []struct {
+		priority string
+		expected string
+	}{
+		{"", "BUY"},
+		{"buy", "BUY"},
+		{"sell", "SELL"},
+	}
+
+	for _, tt := range tests {
+		config.SignalPriority = tt.priority
+		s := NewBBRSIStrategy(config)
+
+		signal := s.evaluatePosition(bar, bb, rsi)
+		if signal.Type != tt.expected {
+			t.Errorf("Expected %s with priority %q, got %s", tt.expected, tt.priority, signal.Type)
+		}
+	}
+}
+
+func TestEvaluatePositionLogsConflict(t *testing.T) {
+	var buf bytes.Buffer
+	config := types.StrategyConfig{
+		BuyThreshold:  60.0,
+		SellThreshold: 40.0,
+		Logger:        slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})),
+	}
+
+	bar := types.StockData{Date: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), Close: 95.0}
+	bb := types.BollingerBands{Upper: 110.0, Middle: 100.0, Lower: 98.0}
+	NewBBRSIStrategy(config).evaluatePosition(bar, bb, 50.0)
+
+	if out := buf.String(); !strings.Contains(out, "conflicting BUY and SELL conditions") || !strings.Contains(out, "date=2023-01-02") {
+		t.Errorf("Expected the conflict warning on the configured logger, got %q", out)
+	}
+
+	// An error-level logger silences the warning
+	buf.Reset()
+	config.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelError}))
+	NewBBRSIStrategy(config).evaluatePosition(bar, bb, 50.0)
+	if buf.Len() != 0 {
+		t.Errorf("Expected no output at error level, got %q", buf.String())
+	}
+}
+
+func TestCalculatePositionSizeClamping(t *testing.T) {
+	s := NewBBRSIStrategy(types.StrategyConfig{StopLoss: 0.05})
+
+	// Risking 2% of 10000 with a $5 stop distance gives 40 shares
+	riskConfig := types.RiskManagementConfig{PositionSize: 0.02}
+	if shares := s.CalculatePositionSize(10000, 100, 95, riskConfig); shares != 40 {
+		t.Fatalf("Expected unclamped size of 40 shares, got %d", shares)
+	}
+
+	riskConfig.MinShares = 50
+	if shares := s.CalculatePositionSize(10000, 100, 95, riskConfig); shares != 50 {
+		t.Errorf("Expected size clamped up to 50 shares, got %d", shares)
+	}
+
+	riskConfig.MinShares = 0
+	riskConfig.MaxShares = 25
+	if shares := s.CalculatePositionSize(10000, 100, 95, riskConfig); shares != 25 {
+		t.Errorf("Expected size clamped down to 25 shares, got %d", shares)
+	}
+}
+
+func TestEvaluatePositionEntryTrigger(t *testing.T) {
+	config := types.StrategyConfig{
+		BuyThreshold:  30.0,
+		SellThreshold: 70.0,
+	}
+
+	// The bar wicks below the lower band but closes back above it
+	bar := types.StockData{
+		Date:  time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		Low:   96.0,
+		Close: 99.0,
+	}
+	bb := types.BollingerBands{Upper: 110.0, Middle: 103.0, Lower: 97.0}
+	rsi := 25.0
+
+	tests := []struct {
+		trigger  string
+		expected string
+	}{
+		{"", "HOLD"},
+		{"close", "HOLD"},
+		{"touch", "BUY"},
+	}
+
+	for _, tt := range tests {
+		config.EntryTrigger = tt.trigger
+		s := NewBBRSIStrategy(config)
+
+		signal := s.evaluatePosition(bar, bb, rsi)
+		if signal.Type != tt.expected {
+			t.Errorf("Expected %s with entry trigger %q, got %s", tt.expected, tt.trigger, signal.Type)
+		}
+	}
+}
+
+func TestStartIndexFirstBarHasValidIndicators(t *testing.T) {
+	closes := []float64{
+		100, 102, 101, 103, 100, 98, 99, 101, 104, 102,
+		105, 103, 101, 100, 102, 104, 103, 106, 105, 107,
+		104, 102, 103, 105, 108, 107, 106, 109, 111, 110,
+	}
+	data := make([]types.StockData, len(closes))
+	for i, c := range closes {
+		data[i] = types.StockData{
+			Date:  time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i),
+			Close: c,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		bbPeriod  int
+		rsiPeriod int
+		expected  int
+	}{
+		{"equal periods", 14, 14, 14},
+		{"RSI longer", 10, 14, 14},
+		{"BB longer", 20, 14, 19},
+		{"BB one longer than RSI", 15, 14, 14},
+	}
+
+	for _, tt := range tests {
+		s := NewBBRSIStrategy(types.StrategyConfig{BBPeriod: tt.bbPeriod, RSIPeriod: tt.rsiPeriod, BBStdDev: 2.0})
+
+		start := s.startIndex()
+		if start != tt.expected {
+			t.Errorf("%s: expected start index %d, got %d", tt.name, tt.expected, start)
+			continue
+		}
+
+		bands := indicators.CalculateBollingerBands(data, tt.bbPeriod, 2.0)
+		rsi := indicators.CalculateRSI(data, tt.rsiPeriod)
+
+		if bands[start].Middle == 0 || rsi[start] == 0 {
+			t.Errorf("%s: expected valid indicators at start index %d, got BB %v and RSI %f",
+				tt.name, start, bands[start], rsi[start])
+		}
+
+		// One bar earlier at least one indicator is still warming up
+		if bands[start-1].Middle != 0 && rsi[start-1] != 0 {
+			t.Errorf("%s: expected a warm-up value before start index %d", tt.name, start)
+		}
+	}
+}
+
+// closesToData builds daily bars with the given closes
+func closesToData(closes ...float64) []types.StockData {
+	data := make([]types.StockData, len(closes))
+	for i, c := range closes {
+		data[i] = types.StockData{
+			Date:  time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i),
+			Open:  c,
+			High:  c,
+			Low:   c,
+			Close: c,
+		}
+	}
+	return data
+}
+
+// buyDates returns the dates of the BUY signals
+func buyDates(signals []types.Signal) []time.Time {
+	var dates []time.Time
+	for _, signal := range signals {
+		if signal.Type == "BUY" {
+			dates = append(dates, signal.Date)
+		}
+	}
+	return dates
+}
+
+func TestGenerateSignalsConfirmBars(t *testing.T) {
+	config := types.StrategyConfig{
+		BuyThreshold:  30.0,
+		SellThreshold: 70.0,
+		RSIPeriod:     3,
+		BBPeriod:      5,
+		BBStdDev:      1.0,
+	}
+
+	// A one-bar drop below the band at index 7 that immediately recovers
+	blip := closesToData(100, 101, 100, 101, 100, 101, 100, 85, 95, 100, 105, 104)
+
+	// A sustained slide below the band from index 7 through index 10
+	slide := closesToData(100, 101, 100, 101, 100, 101, 100, 90, 78, 64, 48, 60)
+
+	// Without confirmation both act on the first oversold bar
+	s := NewBBRSIStrategy(config)
+	if dates := buyDates(s.GenerateSignals(blip)); len(dates) != 1 || !dates[0].Equal(blip[7].Date) {
+		t.Errorf("Expected an immediate BUY on the blip, got %v", dates)
+	}
+
+	config.ConfirmBars = 2
+	s = NewBBRSIStrategy(config)
+
+	if dates := buyDates(s.GenerateSignals(blip)); len(dates) != 0 {
+		t.Errorf("Expected the one-bar blip to be ignored, got BUYs on %v", dates)
+	}
+
+	dates := buyDates(s.GenerateSignals(slide))
+	if len(dates) == 0 || !dates[0].Equal(slide[9].Date) {
+		t.Errorf("Expected the first BUY after two confirming bars on %s, got %v", slide[9].Date.Format("2006-01-02"), dates)
+	}
+}
+
+func TestGetTargetPriceRMultiples(t *testing.T) {
+	config := types.StrategyConfig{
+		TakeProfit:      0.10,
+		TargetR:         2.0,
+		PartialTargetR:  1.0,
+		PartialFraction: 0.5,
+	}
+	s := NewBBRSIStrategy(config)
+
+	// $4 of risk between the entry and the stop
+	entry, stop := 100.0, 96.0
+
+	if partial := s.GetPartialTargetPrice(entry, stop); partial != 104.0 {
+		t.Errorf("Expected the +1R partial target at 104.00, got %.2f", partial)
+	}
+	if target := s.GetTargetPrice(entry, stop); target != 108.0 {
+		t.Errorf("Expected the +2R final target at 108.00, got %.2f", target)
+	}
+
+	// Without R multiples the percentage target applies and there is no partial
+	s = NewBBRSIStrategy(types.StrategyConfig{TakeProfit: 0.10})
+	if target := s.GetTargetPrice(entry, stop); math.Abs(target-110.0) > 1e-9 {
+		t.Errorf("Expected the 10%% target at 110.00, got %.2f", target)
+	}
+	if partial := s.GetPartialTargetPrice(entry, stop); partial != 0 {
+		t.Errorf("Expected no partial target, got %.2f", partial)
+	}
+}
+
+func TestGenerateSignalsMaxEntryGap(t *testing.T) {
+	config := types.StrategyConfig{
+		BuyThreshold:   30.0,
+		SellThreshold:  70.0,
+		RSIPeriod:      3,
+		BBPeriod:       5,
+		BBStdDev:       1.0,
+		MaxEntryGapPct: 0.05,
+	}
+	s := NewBBRSIStrategy(config)
+
+	// The oversold bar at index 7 opens 15% below the prior close of 100
+	gapped := closesToData(100, 101, 100, 101, 100, 101, 100, 85, 95, 100, 105, 104)
+	if dates := buyDates(s.GenerateSignals(gapped)); len(dates) != 0 {
+		t.Errorf("Expected the BUY after a 15%% gap to be skipped, got BUYs on %v", dates)
+	}
+
+	// The same bar opening 2% lower and sliding into the close is taken
+	drifted := closesToData(100, 101, 100, 101, 100, 101, 100, 85, 95, 100, 105, 104)
+	drifted[7].Open = 98
+	drifted[7].High = 98
+	if dates := buyDates(s.GenerateSignals(drifted)); len(dates) != 1 || !dates[0].Equal(drifted[7].Date) {
+		t.Errorf("Expected a BUY on %s after a 2%% gap, got %v", drifted[7].Date.Format("2006-01-02"), dates)
+	}
+}
+
+// firstSell returns the first SELL signal, or a HOLD signal if there is none
+func firstSell(signals []types.Signal) types.Signal {
+	for _, signal := range signals {
+		if signal.Type == "SELL" {
+			return signal
+		}
+	}
+	return types.Signal{Type: "HOLD"}
+}
+
+func TestGenerateSignalsRSIExitLevel(t *testing.T) {
+	config := types.StrategyConfig{
+		BuyThreshold:  30.0,
+		SellThreshold: 70.0,
+		RSIPeriod:     3,
+		BBPeriod:      5,
+		BBStdDev:      1.0,
+	}
+
+	// Oversold at index 7, RSI recovers through 50 at index 10 and turns overbought at index 12.
+	// RSI also crosses 50 before the entry, which must not count.
+	data := closesToData(100, 101, 100, 101, 100, 101, 100, 85, 88, 90, 93, 96, 99, 104)
+
+	s := NewBBRSIStrategy(config)
+	if sell := firstSell(s.GenerateSignals(data)); !sell.Date.Equal(data[12].Date) {
+		t.Errorf("Expected the first SELL at overbought on %s, got %s on %s",
+			data[12].Date.Format("2006-01-02"), sell.Type, sell.Date.Format("2006-01-02"))
+	}
+
+	config.RSIExitLevel = 50
+	s = NewBBRSIStrategy(config)
+
+	signals := s.GenerateSignals(data)
+	if len(buyDates(signals)) == 0 {
+		t.Fatal("Expected an oversold entry")
+	}
+
+	sell := firstSell(signals)
+	if !sell.Date.Equal(data[10].Date) || sell.Reason != "RSI reverted to exit level" {
+		t.Errorf("Expected the RSI exit on %s, got %s %q on %s",
+			data[10].Date.Format("2006-01-02"), sell.Type, sell.Reason, sell.Date.Format("2006-01-02"))
+	}
+}
+
+func TestGenerateSignalsExitOnUpperBand(t *testing.T) {
+	config := types.StrategyConfig{
+		BuyThreshold:    30.0,
+		SellThreshold:   70.0,
+		RSIPeriod:       3,
+		BBPeriod:        5,
+		BBStdDev:        1.0,
+		ExitOnUpperBand: true,
+	}
+
+	// Oversold at index 7, then the rally closes above the upper band at index 11, a bar
+	// before RSI turns overbought
+	data := closesToData(100, 101, 100, 101, 100, 101, 100, 85, 88, 90, 93, 96, 99, 104)
+	bands := indicators.CalculateBollingerBands(data, config.BBPeriod, config.BBStdDev)
+
+	signals := NewBBRSIStrategy(config).GenerateSignals(data)
+	if len(buyDates(signals)) == 0 {
+		t.Fatal("Expected an oversold entry")
+	}
+
+	sell := firstSell(signals)
+	if !sell.Date.Equal(data[11].Date) || sell.Reason != "Price reached upper BB" {
+		t.Fatalf("Expected the band exit on %s, got %s %q on %s",
+			data[11].Date.Format("2006-01-02"), sell.Type, sell.Reason, sell.Date.Format("2006-01-02"))
+	}
+	if sell.OrderType != "limit" || sell.LimitPrice != bands[11].Upper {
+		t.Errorf("Expected a limit order at the %.2f upper band, got %q at %.2f", bands[11].Upper, sell.OrderType, sell.LimitPrice)
+	}
+}
+
+func TestGenerateSignalsTrendFilter(t *testing.T) {
+	config := types.StrategyConfig{
+		BuyThreshold:      30.0,
+		SellThreshold:     70.0,
+		RSIPeriod:         3,
+		BBPeriod:          5,
+		BBStdDev:          1.5,
+		TrendFilterPeriod: 200,
+	}
+
+	// 180 bars at a base level, 20 bars oscillating around 100, then a drop to 85 that is
+	// oversold and below the lower band. The recent bars match, so only the SMA differs.
+	dipAfter := func(base float64) []types.StockData {
+		var closes []float64
+		for i := 0; i < 180; i++ {
+			closes = append(closes, base)
+		}
+		for i := 0; i < 20; i++ {
+			closes = append(closes, 100+float64(i%2))
+		}
+		return closesToData(append(closes, 85)...)
+	}
+	dipBar := 200
+
+	// Coming from 50 the 200-SMA is about 55, below the dip, so the trend is up
+	uptrend := dipAfter(50)
+	if dates := buyDates(NewBBRSIStrategy(config).GenerateSignals(uptrend)); len(dates) != 1 || !dates[0].Equal(uptrend[dipBar].Date) {
+		t.Errorf("Expected the dip above the 200-SMA to BUY on %s, got %v", uptrend[dipBar].Date.Format("2006-01-02"), dates)
+	}
+
+	// Coming from 150 the 200-SMA is about 145, above the dip, so the BUY is rejected
+	downtrend := dipAfter(150)
+	if dates := buyDates(NewBBRSIStrategy(config).GenerateSignals(downtrend)); len(dates) != 0 {
+		t.Errorf("Expected the dip below the 200-SMA to be rejected, got BUYs on %v", dates)
+	}
+
+	// Without the filter the same bar buys either way, as does the earlier fall from 150
+	config.TrendFilterPeriod = 0
+	if dates := buyDates(NewBBRSIStrategy(config).GenerateSignals(downtrend)); len(dates) == 0 || !dates[len(dates)-1].Equal(downtrend[dipBar].Date) {
+		t.Errorf("Expected the unfiltered dip to BUY on %s, got %v", downtrend[dipBar].Date.Format("2006-01-02"), dates)
+	}
+}
+
+func TestEvaluatePositionBuyStrength(t *testing.T) {
+	s := NewBBRSIStrategy(types.StrategyConfig{BuyThreshold: 30.0, SellThreshold: 70.0})
+	bb := types.BollingerBands{Upper: 110.0, Middle: 100.0, Lower: 90.0}
+	date := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	// Just below the band with RSI just under the threshold
+	weak := s.evaluatePosition(types.StockData{Date: date, Close: 89.0}, bb, 27.0)
+
+	// Half the band width below it with RSI halfway to zero
+	strong := s.evaluatePosition(types.StockData{Date: date, Close: 85.0}, bb, 15.0)
+
+	if weak.Type != "BUY" || strong.Type != "BUY" {
+		t.Fatalf("Expected both setups to BUY, got %s and %s", weak.Type, strong.Type)
+	}
+	if math.Abs(weak.Strength-0.1) > 1e-9 {
+		t.Errorf("Expected weak strength 0.10, got %.4f", weak.Strength)
+	}
+	if math.Abs(strong.Strength-0.5) > 1e-9 {
+		t.Errorf("Expected strong strength 0.50, got %.4f", strong.Strength)
+	}
+}
+
+func TestGenerateSignalsIndicatorCache(t *testing.T) {
+	closes := make([]float64, 120)
+	for i := range closes {
+		closes[i] = 100 + 10*math.Sin(float64(i)/5) + float64(i%7)
+	}
+	data := closesToData(closes...)
+
+	// A grid over thresholds that shares only two RSI periods and one Bollinger setting
+	var grid []types.StrategyConfig
+	for _, rsiPeriod := range []int{7, 14} {
+		for _, buy := range []float64{25, 30, 35} {
+			for _, sell := range []float64{65, 70} {
+				grid = append(grid, types.StrategyConfig{
+					BuyThreshold:  buy,
+					SellThreshold: sell,
+					RSIPeriod:     rsiPeriod,
+					BBPeriod:      20,
+					BBStdDev:      2.0,
+				})
+			}
+		}
+	}
+
+	cache := indicators.NewCache()
+	for _, config := range grid {
+		uncached := NewBBRSIStrategy(config).GenerateSignals(data)
+
+		config.IndicatorCache = cache
+		withCache := NewBBRSIStrategy(config).GenerateSignals(data)
+
+		if len(withCache) != len(uncached) {
+			t.Fatalf("Expected identical signals with the cache, got %d vs %d", len(withCache), len(uncached))
+		}
+		for i := range uncached {
+			if withCache[i] != uncached[i] {
+				t.Errorf("Expected identical signal %d with the cache, got %+v vs %+v", i, withCache[i], uncached[i])
+			}
+		}
+	}
+
+	// 12 runs of 2 indicators each compute just the 2 RSI series and 1 set of bands
+	hits, misses := cache.Stats()
+	if misses != 3 || hits != 2*len(grid)-3 {
+		t.Errorf("Expected 3 computations and %d cache hits, got %d and %d", 2*len(grid)-3, misses, hits)
+	}
+}
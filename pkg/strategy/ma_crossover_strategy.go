@@ -0,0 +1,89 @@
+package strategy
+
+import (
+	"fmt"
+	"swing-trader/internal/types"
+	"swing-trader/pkg/indicators"
+)
+
+// MACrossoverStrategy implements a moving-average crossover strategy, buying when the fast
+// SMA crosses above the slow SMA (a golden cross) and selling when it crosses back below
+// (a death cross). Stops, targets and position sizing come from the StrategyConfig as for
+// BBRSIStrategy.
+type MACrossoverStrategy struct {
+	*BBRSIStrategy
+}
+
+// NewMACrossoverStrategy creates a new moving-average crossover strategy
+func NewMACrossoverStrategy(config types.StrategyConfig) *MACrossoverStrategy {
+	return &MACrossoverStrategy{
+		BBRSIStrategy: NewBBRSIStrategy(config),
+	}
+}
+
+// GenerateSignals generates a BUY on every golden cross and a SELL on every death cross
+func (s *MACrossoverStrategy) GenerateSignals(data []types.StockData) []types.Signal {
+	var signals []types.Signal
+	if len(data) <= s.startIndex() {
+		return signals
+	}
+
+	fast := cached(s.config.IndicatorCache, fmt.Sprintf("sma/%d", s.config.FastMAPeriod), data, func() []float64 {
+		return indicators.CalculateSMA(data, s.config.FastMAPeriod)
+	})
+	slow := cached(s.config.IndicatorCache, fmt.Sprintf("sma/%d", s.config.SlowMAPeriod), data, func() []float64 {
+		return indicators.CalculateSMA(data, s.config.SlowMAPeriod)
+	})
+
+	var atrValues []float64
+	if s.config.StopMode == "atr" {
+		atrValues = cached(s.config.IndicatorCache, fmt.Sprintf("atr/%d", s.config.ATRPeriod), data, func() []float64 {
+			return indicators.CalculateATR(data, s.config.ATRPeriod)
+		})
+	}
+
+	// A cross compares against the previous bar, so it needs both averages valid there too
+	for i := s.startIndex(); i < len(data); i++ {
+		signal := types.Signal{
+			Date:  data[i].Date,
+			Price: data[i].Close,
+		}
+
+		switch {
+		case indicators.CrossOver(fast, slow, i):
+			signal.Type = "BUY"
+			signal.Reason = fmt.Sprintf("SMA(%d) crossed above SMA(%d)", s.config.FastMAPeriod, s.config.SlowMAPeriod)
+			if atrValues != nil {
+				signal.StopDistance = atrValues[i] * s.config.ATRMultiplier
+			}
+		case indicators.CrossUnder(fast, slow, i):
+			signal.Type = "SELL"
+			signal.Reason = fmt.Sprintf("SMA(%d) crossed below SMA(%d)", s.config.FastMAPeriod, s.config.SlowMAPeriod)
+		default:
+			continue
+		}
+
+		signals = append(signals, signal)
+	}
+
+	return signals
+}
+
+// MinDataPoints returns the number of bars needed before the first crossover can be evaluated
+func (s *MACrossoverStrategy) MinDataPoints() int {
+	return s.startIndex() + 1
+}
+
+// startIndex returns the first bar index where a crossover can be detected. An SMA is
+// valid from period-1 and a cross also needs the previous bar, so crosses start at the
+// slower period. ATR for the "atr" stop mode is valid from its period.
+func (s *MACrossoverStrategy) startIndex() int {
+	startIndex := s.config.FastMAPeriod
+	if s.config.SlowMAPeriod > startIndex {
+		startIndex = s.config.SlowMAPeriod
+	}
+	if s.config.StopMode == "atr" && s.config.ATRPeriod > startIndex {
+		startIndex = s.config.ATRPeriod
+	}
+	return startIndex
+}
@@ -0,0 +1,64 @@
+package strategy
+
+import (
+	"swing-trader/internal/types"
+	"testing"
+)
+
+func TestMACrossoverGenerateSignals(t *testing.T) {
+	config := types.StrategyConfig{
+		FastMAPeriod: 2,
+		SlowMAPeriod: 4,
+		StopLoss:     0.05,
+		TakeProfit:   0.10,
+	}
+
+	// The fast SMA starts below the slow one in the downtrend, crosses above it as the
+	// price turns up at index 7 and back below it as the price falls at index 13
+	data := closesToData(13, 12, 11, 10, 9, 8, 8, 10, 12, 14, 16, 16, 14, 12, 10, 8, 8)
+
+	signals := NewMACrossoverStrategy(config).GenerateSignals(data)
+	if len(signals) != 2 {
+		t.Fatalf("Expected one golden and one death cross, got %d signals: %+v", len(signals), signals)
+	}
+
+	if signals[0].Type != "BUY" || !signals[0].Date.Equal(data[7].Date) {
+		t.Errorf("Expected a BUY on %s, got %s on %s", data[7].Date.Format("2006-01-02"), signals[0].Type, signals[0].Date.Format("2006-01-02"))
+	}
+	if signals[0].Price != 10 {
+		t.Errorf("Expected the BUY at the 10 close, got %.2f", signals[0].Price)
+	}
+	if signals[1].Type != "SELL" || !signals[1].Date.Equal(data[13].Date) {
+		t.Errorf("Expected a SELL on %s, got %s on %s", data[13].Date.Format("2006-01-02"), signals[1].Type, signals[1].Date.Format("2006-01-02"))
+	}
+}
+
+func TestMACrossoverInsufficientData(t *testing.T) {
+	config := types.StrategyConfig{FastMAPeriod: 2, SlowMAPeriod: 4}
+	s := NewMACrossoverStrategy(config)
+
+	if s.MinDataPoints() != 5 {
+		t.Errorf("Expected 5 bars before the first cross can be evaluated, got %d", s.MinDataPoints())
+	}
+	if signals := s.GenerateSignals(closesToData(10, 11, 12, 13)); len(signals) != 0 {
+		t.Errorf("Expected no signals before the slow SMA has a previous value, got %d", len(signals))
+	}
+}
+
+func TestNewStrategy(t *testing.T) {
+	if _, ok := NewStrategy(types.StrategyConfig{Strategy: "ma_crossover"}).(*MACrossoverStrategy); !ok {
+		t.Error("Expected ma_crossover to create a MACrossoverStrategy")
+	}
+	if _, ok := NewStrategy(types.StrategyConfig{}).(*BBRSIStrategy); !ok {
+		t.Error("Expected the default to be the BBRSIStrategy")
+	}
+
+	// The crossover shares the config's stop and target pricing
+	s := NewStrategy(types.StrategyConfig{Strategy: "ma_crossover", StopLoss: 0.05, TakeProfit: 0.10})
+	if stop := s.GetStopLossPrice(100); stop != 95 {
+		t.Errorf("Expected a 95 stop, got %.2f", stop)
+	}
+	if _, ok := s.(TargetStrategy); !ok {
+		t.Error("Expected the crossover to price R-multiple and ladder targets")
+	}
+}
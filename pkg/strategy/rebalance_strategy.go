@@ -0,0 +1,96 @@
+package strategy
+
+import (
+	"math"
+	"swing-trader/internal/types"
+)
+
+// RebalanceConfig holds the configuration for a RebalanceStrategy
+type RebalanceConfig struct {
+	TargetWeight   float64 // fraction of equity held in the stock (e.g., 0.6 for 60%)
+	Frequency      int     // bars between rebalances (less than 1 uses 1)
+	InitialCapital float64 // cash the portfolio starts with, which the first bar invests up to the weight
+}
+
+// RebalanceStrategy holds a target weight of equity in the stock and trades back to
+// that weight on a fixed schedule, as a baseline for the signal-based strategies
+type RebalanceStrategy struct {
+	config RebalanceConfig
+}
+
+// NewRebalanceStrategy creates a rebalancing strategy that restores the target weight
+// every Frequency bars
+func NewRebalanceStrategy(config RebalanceConfig) *RebalanceStrategy {
+	if config.Frequency < 1 {
+		config.Frequency = 1
+	}
+
+	return &RebalanceStrategy{
+		config: config,
+	}
+}
+
+// GenerateSignals generates the trades that rebalance a portfolio starting with the
+// initial capital in cash. The first bar buys up to the target weight, then every
+// Frequency bars a BUY adds or a SELL trims the holding by the signal's Quantity.
+func (s *RebalanceStrategy) GenerateSignals(data []types.StockData) []types.Signal {
+	var signals []types.Signal
+
+	cash := s.config.InitialCapital
+	var shares int64
+
+	for i := 0; i < len(data); i += s.config.Frequency {
+		price := data[i].Close
+		if price <= 0 {
+			continue
+		}
+
+		equity := cash + float64(shares)*price
+		target := int64(equity * s.config.TargetWeight / price)
+		diff := target - shares
+		if diff == 0 {
+			continue
+		}
+
+		signal := types.Signal{
+			Date:   data[i].Date,
+			Price:  price,
+			Reason: "Rebalance to target weight",
+		}
+
+		if diff > 0 {
+			signal.Type = "BUY"
+			signal.Quantity = diff
+		} else {
+			signal.Type = "SELL"
+			signal.Quantity = -diff
+		}
+
+		shares = target
+		cash -= float64(diff) * price
+		signals = append(signals, signal)
+	}
+
+	return signals
+}
+
+// CalculatePositionSize returns the shares that put the target weight of the available
+// capital in the stock. Rebalancing signals carry their own quantity, so the engine only
+// sizes with this when a signal does not.
+func (s *RebalanceStrategy) CalculatePositionSize(availableCapital, currentPrice, stopLossPrice float64, riskConfig types.RiskManagementConfig) int64 {
+	if currentPrice <= 0 {
+		return 0
+	}
+	return int64(availableCapital * s.config.TargetWeight / currentPrice)
+}
+
+// GetStopLossPrice returns 0, as the holding is only changed by rebalancing
+func (s *RebalanceStrategy) GetStopLossPrice(entryPrice float64) float64 {
+	return 0
+}
+
+// GetTakeProfitPrice returns a target no price reaches, as the holding is only changed
+// by rebalancing
+func (s *RebalanceStrategy) GetTakeProfitPrice(entryPrice float64) float64 {
+	return math.MaxFloat64
+}
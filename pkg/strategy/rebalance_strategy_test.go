@@ -0,0 +1,75 @@
+package strategy
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRebalanceStrategyHoldsTargetWeight(t *testing.T) {
+	// Rises from 100 to 150, then falls back to 100
+	var closes []float64
+	for i := 0; i <= 50; i++ {
+		closes = append(closes, 100+float64(i))
+	}
+	for i := 1; i <= 50; i++ {
+		closes = append(closes, 150-float64(i))
+	}
+	data := closesToData(closes...)
+
+	targetWeight := 0.5
+	initialCapital := 10000.0
+	s := NewRebalanceStrategy(RebalanceConfig{TargetWeight: targetWeight, Frequency: 10, InitialCapital: initialCapital})
+
+	signals := s.GenerateSignals(data)
+	if len(signals) == 0 {
+		t.Fatal("Expected rebalancing signals")
+	}
+
+	if signals[0].Type != "BUY" || !signals[0].Date.Equal(data[0].Date) {
+		t.Errorf("Expected the first bar to buy up to the target weight, got %s on %s",
+			signals[0].Type, signals[0].Date.Format("2006-01-02"))
+	}
+
+	cash := initialCapital
+	var shares int64
+	var trims, adds int
+
+	for i, signal := range signals {
+		switch signal.Type {
+		case "BUY":
+			shares += signal.Quantity
+			cash -= float64(signal.Quantity) * signal.Price
+			if i > 0 {
+				adds++
+			}
+		case "SELL":
+			shares -= signal.Quantity
+			cash += float64(signal.Quantity) * signal.Price
+			trims++
+		}
+
+		if signal.Quantity <= 0 {
+			t.Errorf("Expected a positive quantity on %s, got %d", signal.Date.Format("2006-01-02"), signal.Quantity)
+		}
+
+		// Whole shares keep the weight within one share of the target
+		equity := cash + float64(shares)*signal.Price
+		weight := float64(shares) * signal.Price / equity
+		if math.Abs(weight-targetWeight) > signal.Price/equity {
+			t.Errorf("Expected weight near %.2f after rebalancing on %s, got %.4f",
+				targetWeight, signal.Date.Format("2006-01-02"), weight)
+		}
+
+		// Trims happen while the stock outperforms cash and adds while it lags
+		if signal.Date.After(data[0].Date) && signal.Date.Before(data[51].Date) && signal.Type != "SELL" {
+			t.Errorf("Expected a trim during the uptrend on %s, got %s", signal.Date.Format("2006-01-02"), signal.Type)
+		}
+		if signal.Date.After(data[50].Date) && signal.Type != "BUY" {
+			t.Errorf("Expected an add during the downtrend on %s, got %s", signal.Date.Format("2006-01-02"), signal.Type)
+		}
+	}
+
+	if trims == 0 || adds == 0 {
+		t.Errorf("Expected both trims and adds, got %d trims and %d adds", trims, adds)
+	}
+}
@@ -0,0 +1,43 @@
+package strategy
+
+import (
+	"swing-trader/internal/types"
+)
+
+// Strategy is a trading strategy the backtesting engine can run. It turns bars into
+// signals and prices the stop, take profit and size of each entry.
+type Strategy interface {
+	GenerateSignals(data []types.StockData) []types.Signal
+	CalculatePositionSize(availableCapital, currentPrice, stopLossPrice float64, riskConfig types.RiskManagementConfig) int64
+	GetStopLossPrice(entryPrice float64) float64
+	GetTakeProfitPrice(entryPrice float64) float64
+}
+
+// WarmUpStrategy is a Strategy whose indicators need leading bars before the first signal.
+// The engine treats a Strategy without it as able to signal from the first bar.
+type WarmUpStrategy interface {
+	Strategy
+	MinDataPoints() int
+}
+
+// TargetStrategy is a Strategy that prices R-multiple targets, partial profits and take-profit
+// ladder levels. The engine uses the percentage take profit, with no partial or ladder
+// exits, for a Strategy without it.
+type TargetStrategy interface {
+	Strategy
+	GetTargetPrice(entryPrice, stopLossPrice float64) float64
+	GetPartialTargetPrice(entryPrice, stopLossPrice float64) float64
+	GetLadderPrice(entryPrice float64, step int) float64
+}
+
+// NewStrategy creates the strategy named by the config, falling back to Bollinger Bands +
+// RSI for an empty or unknown name
+func NewStrategy(config types.StrategyConfig) Strategy {
+	switch config.Strategy {
+	case "ma_crossover":
+		return NewMACrossoverStrategy(config)
+	case "voting":
+		return NewVotingStrategy(config)
+	}
+	return NewBBRSIStrategy(config)
+}
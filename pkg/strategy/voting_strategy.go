@@ -0,0 +1,140 @@
+package strategy
+
+import (
+	"fmt"
+	"math"
+	"swing-trader/internal/types"
+	"swing-trader/pkg/indicators"
+)
+
+// VotingStrategy signals from a weighted vote across RSI, Bollinger %B, the stochastic
+// oscillator and CCI instead of requiring every condition at once. Each indicator's reading
+// is normalized to a vote from -1 (overbought) to 1 (oversold) and the score is the
+// weighted average of the votes. Stops, targets and position sizing come from the
+// StrategyConfig as for BBRSIStrategy.
+type VotingStrategy struct {
+	*BBRSIStrategy
+}
+
+// NewVotingStrategy creates a weighted voting strategy
+func NewVotingStrategy(config types.StrategyConfig) *VotingStrategy {
+	return &VotingStrategy{
+		BBRSIStrategy: NewBBRSIStrategy(config),
+	}
+}
+
+// GenerateSignals generates a BUY on every bar whose score reaches the threshold, with the
+// score as its strength, and a SELL on every bar whose score falls to the negative threshold
+func (s *VotingStrategy) GenerateSignals(data []types.StockData) []types.Signal {
+	var signals []types.Signal
+	if len(data) <= s.startIndex() {
+		return signals
+	}
+
+	scores := s.Scores(data)
+
+	var atrValues []float64
+	if s.config.StopMode == "atr" {
+		atrValues = cached(s.config.IndicatorCache, fmt.Sprintf("atr/%d", s.config.ATRPeriod), data, func() []float64 {
+			return indicators.CalculateATR(data, s.config.ATRPeriod)
+		})
+	}
+
+	for i := s.startIndex(); i < len(data); i++ {
+		signal := types.Signal{
+			Date:  data[i].Date,
+			Price: data[i].Close,
+			Tag:   "vote",
+		}
+
+		switch {
+		case scores[i] >= s.config.VoteThreshold:
+			signal.Type = "BUY"
+			signal.Reason = "Weighted indicator vote oversold"
+			signal.Strength = scores[i]
+			if atrValues != nil {
+				signal.StopDistance = atrValues[i] * s.config.ATRMultiplier
+			}
+		case scores[i] <= -s.config.VoteThreshold:
+			signal.Type = "SELL"
+			signal.Reason = "Weighted indicator vote overbought"
+		default:
+			continue
+		}
+
+		signals = append(signals, signal)
+	}
+
+	return signals
+}
+
+// Scores returns the weighted vote at each bar, from -1 when every weighted indicator is
+// fully overbought to 1 when every one is fully oversold. Bars before MinDataPoints and
+// configurations without weights score 0.
+func (s *VotingStrategy) Scores(data []types.StockData) []float64 {
+	scores := make([]float64, len(data))
+
+	weights := s.config.VoteWeights
+	total := math.Abs(weights.RSI) + math.Abs(weights.PercentB) + math.Abs(weights.Stochastic) + math.Abs(weights.CCI)
+	if total == 0 || len(data) <= s.startIndex() {
+		return scores
+	}
+
+	rsiValues := cached(s.config.IndicatorCache, fmt.Sprintf("rsi/%d/%s", s.config.RSIPeriod, s.config.RSISmoothing), data, func() []float64 {
+		return indicators.CalculateRSIWithSmoothing(data, s.config.RSIPeriod, s.config.RSISmoothing)
+	})
+	bands := cached(s.config.IndicatorCache, fmt.Sprintf("bb/%d/%g", s.config.BBPeriod, s.config.BBStdDev), data, func() []types.BollingerBands {
+		return indicators.CalculateBollingerBands(data, s.config.BBPeriod, s.config.BBStdDev)
+	})
+	stochValues := cached(s.config.IndicatorCache, fmt.Sprintf("stochastic/%d", s.config.StochasticPeriod), data, func() []float64 {
+		return indicators.CalculateStochastic(data, s.config.StochasticPeriod)
+	})
+	cciValues := cached(s.config.IndicatorCache, fmt.Sprintf("cci/%d", s.config.CCIPeriod), data, func() []float64 {
+		return indicators.CalculateCCI(data, s.config.CCIPeriod)
+	})
+
+	for i := s.startIndex(); i < len(data); i++ {
+		// RSI, %B and the stochastic vote fully at their extremes and CCI at ±100
+		var percentBVote float64
+		if width := bands[i].Upper - bands[i].Lower; width > 0 {
+			percentBVote = 1 - 2*(data[i].Close-bands[i].Lower)/width
+		}
+
+		score := weights.RSI*clampVote((50-rsiValues[i])/50) +
+			weights.PercentB*clampVote(percentBVote) +
+			weights.Stochastic*clampVote((50-stochValues[i])/50) +
+			weights.CCI*clampVote(-cciValues[i]/100)
+		scores[i] = score / total
+	}
+
+	return scores
+}
+
+// clampVote limits a vote to the range -1 to 1
+func clampVote(v float64) float64 {
+	return math.Max(-1, math.Min(1, v))
+}
+
+// MinDataPoints returns the number of bars needed before the first signal can be evaluated
+func (s *VotingStrategy) MinDataPoints() int {
+	return s.startIndex() + 1
+}
+
+// startIndex returns the first bar index where every indicator is valid. RSI needs the
+// previous close and is valid from its period, the others from period-1. ATR for the "atr"
+// stop mode is valid from its period.
+func (s *VotingStrategy) startIndex() int {
+	startIndex := s.config.RSIPeriod
+	for _, period := range []int{s.config.BBPeriod, s.config.StochasticPeriod, s.config.CCIPeriod} {
+		if period-1 > startIndex {
+			startIndex = period - 1
+		}
+	}
+	if s.config.StopMode == "atr" && s.config.ATRPeriod > startIndex {
+		startIndex = s.config.ATRPeriod
+	}
+	if startIndex < 0 {
+		startIndex = 0
+	}
+	return startIndex
+}
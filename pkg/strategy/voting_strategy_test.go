@@ -0,0 +1,63 @@
+package strategy
+
+import (
+	"math"
+	"swing-trader/internal/types"
+	"swing-trader/pkg/indicators"
+	"testing"
+)
+
+func TestVotingStrategyWeightsFlipBuy(t *testing.T) {
+	// A long decline leaves RSI oversold, then a sharp bounce puts the close near the top of
+	// the short stochastic range
+	var closes []float64
+	for i := 0; i < 30; i++ {
+		closes = append(closes, 130-float64(i))
+	}
+	closes = append(closes, 104)
+	data := closesToData(closes...)
+	for i := range data {
+		data[i].High = data[i].Close + 0.5
+		data[i].Low = data[i].Close - 0.5
+	}
+	last := len(data) - 1
+
+	config := types.StrategyConfig{
+		Strategy:         "voting",
+		VoteThreshold:    0.5,
+		RSIPeriod:        14,
+		BBPeriod:         20,
+		BBStdDev:         2.0,
+		StochasticPeriod: 5,
+		CCIPeriod:        20,
+	}
+
+	buysOnLastBar := func(weights types.VoteWeights) bool {
+		config.VoteWeights = weights
+		for _, signal := range NewVotingStrategy(config).GenerateSignals(data) {
+			if signal.Date.Equal(data[last].Date) {
+				return signal.Type == "BUY"
+			}
+		}
+		return false
+	}
+
+	// RSI alone votes oversold strongly enough to buy
+	if !buysOnLastBar(types.VoteWeights{RSI: 1}) {
+		t.Error("Expected a BUY on the last bar with RSI weighted alone")
+	}
+
+	// Weighting the overbought stochastic outvotes it
+	if buysOnLastBar(types.VoteWeights{RSI: 1, Stochastic: 2}) {
+		t.Error("Expected no BUY on the last bar with the stochastic weighted twice RSI")
+	}
+
+	// The score is the weighted average of the clamped votes
+	config.VoteWeights = types.VoteWeights{RSI: 1, Stochastic: 2}
+	rsi := indicators.CalculateRSI(data, config.RSIPeriod)[last]
+	stoch := indicators.CalculateStochastic(data, config.StochasticPeriod)[last]
+	expected := ((50-rsi)/50 + 2*(50-stoch)/50) / 3
+	if score := NewVotingStrategy(config).Scores(data)[last]; math.Abs(score-expected) > 1e-9 {
+		t.Errorf("Expected a score of %.4f, got %.4f", expected, score)
+	}
+}
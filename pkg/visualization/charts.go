@@ -2,7 +2,9 @@ package visualization
 
 import (
 	"fmt"
+	"math"
 	"os"
+	"sort"
 	stockTypes "swing-trader/internal/types"
 
 	"github.com/go-echarts/go-echarts/v2/charts"
@@ -17,8 +19,23 @@ type TradeMarker struct {
 	ID    string
 }
 
-// GenerateKLineChartWithTrades creates a candlestick chart with trade markers
-func GenerateKLineChartWithTrades(stockData []stockTypes.StockData, trades []stockTypes.Trade, title, filePath string) error {
+// waterfallSegment is one trade's step in the equity waterfall. The bar spans Base to
+// Base+Height, with Cumulative the equity after the trade.
+type waterfallSegment struct {
+	Label      string
+	Base       float64
+	Height     float64
+	ProfitLoss float64
+	Cumulative float64
+}
+
+// warmUpLabel names the shaded region of bars before the indicators are valid
+const warmUpLabel = "no-signal warm-up"
+
+// GenerateKLineChartWithTrades creates a candlestick chart with trade markers. The first
+// warmUpBars bars, where the indicators are still warming up and no signals can fire, are
+// shaded and labelled.
+func GenerateKLineChartWithTrades(stockData []stockTypes.StockData, trades []stockTypes.Trade, warmUpBars int, title, filePath string) error {
 	// Prepare data for candlestick chart
 	dates := make([]string, len(stockData))
 	klineData := make([]opts.KlineData, len(stockData))
@@ -40,6 +57,18 @@ func GenerateKLineChartWithTrades(stockData []stockTypes.StockData, trades []sto
 
 	kline.SetXAxis(dates).AddSeries("Stock Price", klineData)
 
+	if warmUpBars > len(dates) {
+		warmUpBars = len(dates)
+	}
+	if warmUpBars > 0 {
+		kline.SetSeriesOptions(
+			withXAxisMarkArea(warmUpLabel, dates[0], dates[warmUpBars-1]),
+			charts.WithMarkAreaStyleOpts(opts.MarkAreaStyle{
+				ItemStyle: &opts.ItemStyle{Color: "rgba(128, 128, 128, 0.2)"},
+			}),
+		)
+	}
+
 	// Save the chart
 	f, err := os.Create(filePath)
 	if err != nil {
@@ -50,10 +79,17 @@ func GenerateKLineChartWithTrades(stockData []stockTypes.StockData, trades []sto
 	return kline.Render(f)
 }
 
-// GenerateAccountBalanceChart creates a line chart showing account balance over time
-func GenerateAccountBalanceChart(stockData []stockTypes.StockData, trades []stockTypes.Trade, initialCapital float64, title, filePath string) error {
-	// Calculate account balance over time
-	dates, balances := calculateAccountBalance(stockData, trades, initialCapital)
+// GenerateAccountBalanceChart creates a line chart showing account balance over time from
+// the backtest's mark-to-market equity curve, one point per bar of stockData
+func GenerateAccountBalanceChart(stockData []stockTypes.StockData, equityCurve []float64, title, filePath string) error {
+	if len(equityCurve) != len(stockData) {
+		return fmt.Errorf("equity curve has %d points for %d bars", len(equityCurve), len(stockData))
+	}
+
+	dates := make([]string, len(stockData))
+	for i, data := range stockData {
+		dates[i] = data.Date.Format("2006-01-02")
+	}
 
 	// Create line chart
 	line := charts.NewLine()
@@ -63,8 +99,8 @@ func GenerateAccountBalanceChart(stockData []stockTypes.StockData, trades []stoc
 		}),
 	)
 
-	lineItems := make([]opts.LineData, len(balances))
-	for i, balance := range balances {
+	lineItems := make([]opts.LineData, len(equityCurve))
+	for i, balance := range equityCurve {
 		lineItems[i] = opts.LineData{Value: balance}
 	}
 
@@ -80,6 +116,167 @@ func GenerateAccountBalanceChart(stockData []stockTypes.StockData, trades []stoc
 	return line.Render(f)
 }
 
+// GenerateReturnHistogramChart creates a bar chart of the per-trade return distribution
+func GenerateReturnHistogramChart(dist stockTypes.ReturnDistribution, title, filePath string) error {
+	labels := make([]string, len(dist.Bins))
+	barItems := make([]opts.BarData, len(dist.Bins))
+	for i, bin := range dist.Bins {
+		labels[i] = fmt.Sprintf("%.1f%% to %.1f%%", bin.Lower, bin.Upper)
+		barItems[i] = opts.BarData{Value: bin.Count}
+	}
+
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    fmt.Sprintf("%s - Trade Return Distribution", title),
+			Subtitle: fmt.Sprintf("Mean %.2f%% | Median %.2f%% | Skew %.2f | Kurtosis %.2f", dist.Mean, dist.Median, dist.Skew, dist.Kurtosis),
+		}),
+	)
+
+	bar.SetXAxis(labels).AddSeries("Trades", barItems)
+
+	// Save the chart
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	defer f.Close()
+
+	return bar.Render(f)
+}
+
+// GenerateCapacityChart creates a line chart of the total return at each initial capital
+// of a capacity analysis
+func GenerateCapacityChart(points []stockTypes.CapacityPoint, title, filePath string) error {
+	labels := make([]string, len(points))
+	lineItems := make([]opts.LineData, len(points))
+	for i, point := range points {
+		labels[i] = fmt.Sprintf("$%.0f", point.InitialCapital)
+		lineItems[i] = opts.LineData{Value: point.TotalReturn}
+	}
+
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: fmt.Sprintf("%s - Return vs Capital", title),
+		}),
+	)
+
+	line.SetXAxis(labels).AddSeries("Total Return %", lineItems)
+
+	// Save the chart
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	defer f.Close()
+
+	return line.Render(f)
+}
+
+// GenerateEquityWaterfallChart creates a waterfall chart of each closed trade's contribution
+// to equity in order of exit, with wins in green and losses in red, followed by the
+// deposits, withdrawals and performance fees that take it to the final capital
+func GenerateEquityWaterfallChart(result *stockTypes.BacktestResult, title, filePath string) error {
+	segments := calculateWaterfall(result)
+
+	labels := make([]string, len(segments))
+	baseItems := make([]opts.BarData, len(segments))
+	stepItems := make([]opts.BarData, len(segments))
+	for i, segment := range segments {
+		labels[i] = segment.Label
+
+		// An invisible base lifts each step to the equity before the trade
+		baseItems[i] = opts.BarData{
+			Value:     segment.Base,
+			ItemStyle: &opts.ItemStyle{Color: "transparent"},
+		}
+
+		color := "#26a69a"
+		if segment.ProfitLoss < 0 {
+			color = "#ef5350"
+		}
+		stepItems[i] = opts.BarData{
+			Name:      fmt.Sprintf("%s: %.2f (equity %.2f)", segment.Label, segment.ProfitLoss, segment.Cumulative),
+			Value:     segment.Height,
+			ItemStyle: &opts.ItemStyle{Color: color},
+		}
+	}
+
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    fmt.Sprintf("%s - Equity Contribution by Trade", title),
+			Subtitle: fmt.Sprintf("Initial %.2f | Final %.2f", result.InitialCapital, result.FinalCapital),
+		}),
+		charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true)}),
+	)
+
+	bar.SetXAxis(labels).
+		AddSeries("Equity Before", baseItems, charts.WithBarChartOpts(opts.BarChart{Stack: "equity"})).
+		AddSeries("Equity Change", stepItems, charts.WithBarChartOpts(opts.BarChart{Stack: "equity"}))
+
+	// Save the chart
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	defer f.Close()
+
+	return bar.Render(f)
+}
+
+// calculateWaterfall returns the waterfall steps of the closed trades in order of exit,
+// starting from the initial capital, then a step each for the net cash flows, the
+// performance fees and any remaining difference to the final capital, such as rounding
+func calculateWaterfall(result *stockTypes.BacktestResult) []waterfallSegment {
+	var closed []stockTypes.Trade
+	for _, trade := range result.Trades {
+		if trade.ExitDate != nil {
+			closed = append(closed, trade)
+		}
+	}
+	sort.SliceStable(closed, func(i, j int) bool {
+		return closed[i].ExitDate.Before(*closed[j].ExitDate)
+	})
+
+	var segments []waterfallSegment
+	equity := result.InitialCapital
+	step := func(label string, amount float64) {
+		segment := waterfallSegment{
+			Label:      label,
+			Base:       equity,
+			Height:     amount,
+			ProfitLoss: amount,
+		}
+
+		// A loss steps down, so its bar starts at the lower equity
+		if amount < 0 {
+			segment.Base = equity + amount
+			segment.Height = -amount
+		}
+
+		equity += amount
+		segment.Cumulative = equity
+		segments = append(segments, segment)
+	}
+
+	for _, trade := range closed {
+		step(fmt.Sprintf("%s %s", trade.ID, trade.ExitDate.Format("2006-01-02")), trade.ProfitLoss)
+	}
+	if result.NetCashFlows != 0 {
+		step("Cash flows", result.NetCashFlows)
+	}
+	if result.PerformanceFees != 0 {
+		step("Performance fees", -result.PerformanceFees)
+	}
+	if other := result.FinalCapital - equity; math.Abs(other) > 1e-6 {
+		step("Other", other)
+	}
+
+	return segments
+}
+
 // generateTradeMarkers creates scatter plot data for trade entry and exit points
 func generateTradeMarkers(stockData []stockTypes.StockData, trades []stockTypes.Trade) ([]opts.ScatterData, []opts.ScatterData) {
 	// Create a map for quick date lookup
@@ -118,33 +315,15 @@ func generateTradeMarkers(stockData []stockTypes.StockData, trades []stockTypes.
 	return buyMarkers, sellMarkers
 }
 
-// calculateAccountBalance computes the account balance over time
-func calculateAccountBalance(stockData []stockTypes.StockData, trades []stockTypes.Trade, initialCapital float64) ([]string, []float64) {
-	dates := make([]string, len(stockData))
-	balances := make([]float64, len(stockData))
-
-	// Create trade events map
-	tradeEvents := make(map[string]float64) // date -> P&L
-	for _, trade := range trades {
-		if trade.ExitDate != nil {
-			exitDate := trade.ExitDate.Format("2006-01-02")
-			tradeEvents[exitDate] += trade.ProfitLoss
-		}
-	}
-
-	currentBalance := initialCapital
-	for i, data := range stockData {
-		dateStr := data.Date.Format("2006-01-02")
-		dates[i] = dateStr
-
-		// Check if there's a trade completion on this date
-		if pnl, exists := tradeEvents[dateStr]; exists {
-			currentBalance += pnl
+// withXAxisMarkArea shades the x axis between the start and end categories, inclusive
+func withXAxisMarkArea(name, start, end string) charts.SeriesOpts {
+	return func(s *charts.SingleSeries) {
+		if s.MarkAreas == nil {
+			s.MarkAreas = &opts.MarkAreas{}
 		}
-
-		balances[i] = currentBalance
+		s.MarkAreas.Data = append(s.MarkAreas.Data, []opts.MarkAreaNameXAxisItem{
+			{Name: name, XAxis: start},
+			{XAxis: end},
+		})
 	}
-
-	return dates, balances
 }
-
@@ -0,0 +1,192 @@
+package visualization
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	stockTypes "swing-trader/internal/types"
+	"swing-trader/pkg/backtesting"
+	"testing"
+	"time"
+)
+
+func TestGenerateKLineChartWarmUpArea(t *testing.T) {
+	stockData := make([]stockTypes.StockData, 30)
+	for i := range stockData {
+		stockData[i] = stockTypes.StockData{
+			Date:  time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i),
+			Open:  100,
+			High:  101,
+			Low:   99,
+			Close: 100,
+		}
+	}
+
+	filePath := filepath.Join(t.TempDir(), "kline.html")
+	if err := GenerateKLineChartWithTrades(stockData, nil, 19, "TEST", filePath); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to read chart: %v", err)
+	}
+	html := string(content)
+
+	// The area spans the first bar through the last warm-up bar at index 18
+	expected := `"markArea":{"data":[[{"name":"` + warmUpLabel + `","xAxis":"2023-01-02"},{"xAxis":"2023-01-20"}]]`
+	if !strings.Contains(html, expected) {
+		t.Errorf("Expected the chart to contain the warm-up area %s", expected)
+	}
+}
+
+func TestGenerateKLineChartWithoutWarmUp(t *testing.T) {
+	stockData := []stockTypes.StockData{
+		{Date: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), Open: 100, High: 101, Low: 99, Close: 100},
+	}
+
+	filePath := filepath.Join(t.TempDir(), "kline.html")
+	if err := GenerateKLineChartWithTrades(stockData, nil, 0, "TEST", filePath); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to read chart: %v", err)
+	}
+	if strings.Contains(string(content), warmUpLabel) {
+		t.Error("Expected no warm-up area without warm-up bars")
+	}
+}
+
+func TestCalculateWaterfallMatchesFinalCapital(t *testing.T) {
+	exit := func(day int) *time.Time {
+		d := time.Date(2023, 1, day, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+
+	// Listed out of exit order, with one trade still open
+	trades := []stockTypes.Trade{
+		{ID: "T2", ExitDate: exit(9), ProfitLoss: -80},
+		{ID: "T1", ExitDate: exit(5), ProfitLoss: 150},
+		{ID: "T3", ExitDate: exit(12), ProfitLoss: 40},
+		{ID: "T4", ProfitLoss: 0},
+	}
+	result := stockTypes.BacktestResult{Trades: trades, InitialCapital: 10000, FinalCapital: 10110}
+
+	segments := calculateWaterfall(&result)
+
+	if len(segments) != 3 {
+		t.Fatalf("Expected a segment per closed trade (3), got %d", len(segments))
+	}
+	if final := segments[len(segments)-1].Cumulative; final != result.FinalCapital {
+		t.Errorf("Expected the waterfall to end at the final capital %.2f, got %.2f", result.FinalCapital, final)
+	}
+
+	expected := []struct {
+		label  string
+		base   float64
+		height float64
+	}{
+		{"T1 2023-01-05", 10000, 150},
+		{"T2 2023-01-09", 10070, 80},
+		{"T3 2023-01-12", 10070, 40},
+	}
+	for i, e := range expected {
+		s := segments[i]
+		if s.Label != e.label || s.Base != e.base || s.Height != e.height {
+			t.Errorf("Expected segment %d as %s from %.0f up %.0f, got %s from %.0f up %.0f",
+				i, e.label, e.base, e.height, s.Label, s.Base, s.Height)
+		}
+	}
+
+	filePath := filepath.Join(t.TempDir(), "waterfall.html")
+	if err := GenerateEquityWaterfallChart(&result, "TEST", filePath); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestCalculateWaterfallIncludesCashFlowsAndFees(t *testing.T) {
+	// A flat series with a sharp drop and a rally, giving one round trip
+	closes := []float64{100, 101, 100, 101, 100, 101, 100, 85, 95, 100, 105, 104}
+	data := make([]stockTypes.StockData, len(closes))
+	for i, c := range closes {
+		data[i] = stockTypes.StockData{
+			Date:  time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i),
+			Open:  c,
+			High:  c,
+			Low:   c,
+			Close: c,
+		}
+	}
+
+	config := stockTypes.BacktestConfig{
+		InitialCapital: 10000,
+		TradeFee:       0.001,
+		CashFlows: []stockTypes.CashFlow{
+			{Date: data[3].Date, Amount: 2500},
+			{Date: data[10].Date, Amount: -1000},
+		},
+		StrategyConfig: stockTypes.StrategyConfig{
+			BuyThreshold:   30,
+			SellThreshold:  70,
+			StopLoss:       0.05,
+			TakeProfit:     0.10,
+			InitialCapital: 10000,
+			RSIPeriod:      3,
+			BBPeriod:       5,
+			BBStdDev:       1.5,
+		},
+		RiskManagementConfig: stockTypes.RiskManagementConfig{
+			MaxDrawdown:  0.20,
+			PositionSize: 0.02,
+		},
+	}
+	result, err := backtesting.NewEngine(config).Run(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Trades) == 0 {
+		t.Fatal("Expected the run to trade")
+	}
+
+	segments := calculateWaterfall(result)
+
+	if final := segments[len(segments)-1].Cumulative; math.Abs(final-result.FinalCapital) > 1e-9 {
+		t.Errorf("Expected the waterfall to end at the final capital %.2f, got %.2f", result.FinalCapital, final)
+	}
+
+	cashFlows := segments[len(segments)-1]
+	if cashFlows.Label != "Cash flows" || cashFlows.ProfitLoss != 1500 {
+		t.Errorf("Expected a closing cash flow step of 1500, got %s of %.2f", cashFlows.Label, cashFlows.ProfitLoss)
+	}
+}
+
+func TestGenerateAccountBalanceChartPlotsEquityCurve(t *testing.T) {
+	stockData := make([]stockTypes.StockData, 3)
+	for i := range stockData {
+		stockData[i] = stockTypes.StockData{Date: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i), Close: 100}
+	}
+
+	filePath := filepath.Join(t.TempDir(), "balance.html")
+	if err := GenerateAccountBalanceChart(stockData, []float64{10000, 10250.5, 9875.25}, "TEST", filePath); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to read chart: %v", err)
+	}
+
+	// Open positions are marked to market on every bar, not only when trades close
+	for _, expected := range []string{`{"value":10250.5}`, `{"value":9875.25}`} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected the chart to plot %s", expected)
+		}
+	}
+
+	if err := GenerateAccountBalanceChart(stockData, []float64{10000}, "TEST", filePath); err == nil {
+		t.Error("Expected an error for an equity curve that does not match the bars")
+	}
+}
@@ -0,0 +1,42 @@
+package visualization
+
+import (
+	stockTypes "swing-trader/internal/types"
+)
+
+// DownsampleOHLC reduces the bars to at most maxPoints for charting by merging runs of
+// consecutive bars into one candle: the first bar's date and open, the last bar's close,
+// the highest high, the lowest low and the summed volume. Merging keeps the extremes of
+// the full series visible, unlike keeping every Nth bar. It also returns the number of
+// bars merged into each candle, 1 when the data already fits or maxPoints is 0.
+func DownsampleOHLC(data []stockTypes.StockData, maxPoints int) ([]stockTypes.StockData, int) {
+	if maxPoints <= 0 || len(data) <= maxPoints {
+		return data, 1
+	}
+
+	bucketSize := (len(data) + maxPoints - 1) / maxPoints
+	sampled := make([]stockTypes.StockData, 0, (len(data)+bucketSize-1)/bucketSize)
+
+	for start := 0; start < len(data); start += bucketSize {
+		end := start + bucketSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		candle := data[start]
+		candle.Close = data[end-1].Close
+		for _, bar := range data[start+1 : end] {
+			if bar.High > candle.High {
+				candle.High = bar.High
+			}
+			if bar.Low < candle.Low {
+				candle.Low = bar.Low
+			}
+			candle.Volume += bar.Volume
+		}
+
+		sampled = append(sampled, candle)
+	}
+
+	return sampled, bucketSize
+}
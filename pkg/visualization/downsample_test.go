@@ -0,0 +1,69 @@
+package visualization
+
+import (
+	"math"
+	stockTypes "swing-trader/internal/types"
+	"testing"
+	"time"
+)
+
+func TestDownsampleOHLCPreservesExtremes(t *testing.T) {
+	// Twenty years of daily bars with a one-bar spike and crash that every-Nth-bar sampling would miss
+	data := make([]stockTypes.StockData, 5000)
+	for i := range data {
+		price := 100 + 20*math.Sin(float64(i)/50)
+		data[i] = stockTypes.StockData{
+			Date:   time.Date(2005, 1, 3, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i),
+			Open:   price,
+			High:   price + 1,
+			Low:    price - 1,
+			Close:  price,
+			Volume: 1000,
+		}
+	}
+	data[1234].High = 500
+	data[3717].Low = 5
+
+	sampled, bucketSize := DownsampleOHLC(data, 300)
+	if len(sampled) > 300 {
+		t.Fatalf("Expected at most 300 points, got %d", len(sampled))
+	}
+	if bucketSize != 17 {
+		t.Errorf("Expected 17 bars per point, got %d", bucketSize)
+	}
+
+	high, low := math.Inf(-1), math.Inf(1)
+	var volume int64
+	for _, bar := range sampled {
+		high = math.Max(high, bar.High)
+		low = math.Min(low, bar.Low)
+		volume += bar.Volume
+	}
+
+	if high != 500 || low != 5 {
+		t.Errorf("Expected the global high 500.00 and low 5.00 to survive, got %.2f and %.2f", high, low)
+	}
+	if volume != 5000*1000 {
+		t.Errorf("Expected the total volume to be kept, got %d", volume)
+	}
+
+	// Each point opens with its first bar and closes with its last
+	if !sampled[1].Date.Equal(data[17].Date) || sampled[1].Open != data[17].Open || sampled[1].Close != data[33].Close {
+		t.Errorf("Expected the second point to span bars 17 to 33, got %s open %.2f close %.2f",
+			sampled[1].Date.Format("2006-01-02"), sampled[1].Open, sampled[1].Close)
+	}
+	if last := sampled[len(sampled)-1]; last.Close != data[len(data)-1].Close {
+		t.Errorf("Expected the last point to close with the last bar, got %.2f", last.Close)
+	}
+}
+
+func TestDownsampleOHLCFits(t *testing.T) {
+	data := make([]stockTypes.StockData, 10)
+
+	if sampled, bucketSize := DownsampleOHLC(data, 0); len(sampled) != 10 || bucketSize != 1 {
+		t.Errorf("Expected no downsampling when disabled, got %d points of %d bars", len(sampled), bucketSize)
+	}
+	if sampled, bucketSize := DownsampleOHLC(data, 10); len(sampled) != 10 || bucketSize != 1 {
+		t.Errorf("Expected no downsampling when the data fits, got %d points of %d bars", len(sampled), bucketSize)
+	}
+}